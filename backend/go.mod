@@ -3,6 +3,7 @@ module github.com/unrealsolutions/bugit
 go 1.24.0
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.8.0
 	modernc.org/sqlite v1.44.3
 )
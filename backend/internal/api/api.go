@@ -2,20 +2,23 @@
 package api
 
 import (
-	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ids"
 	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/jobs"
 	"github.com/unrealsolutions/bugit/internal/models"
 	"github.com/unrealsolutions/bugit/internal/storage"
 )
@@ -25,6 +28,7 @@ type Server struct {
 	db       *db.DB
 	storage  *storage.Storage
 	ingester *ingest.Ingester
+	queue    *jobs.Queue
 	version  string
 	logger   *slog.Logger
 }
@@ -48,6 +52,17 @@ func NewServer(database *db.DB, store *storage.Storage, version string) *Server
 	}
 }
 
+// Ingester returns the ingester used for uploads.
+func (s *Server) Ingester() *ingest.Ingester {
+	return s.ingester
+}
+
+// SetQueue enables asynchronous ingestion. Without a queue, async
+// requests are processed synchronously.
+func (s *Server) SetQueue(q *jobs.Queue) {
+	s.queue = q
+}
+
 // Handler returns the HTTP handler.
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
@@ -61,9 +76,35 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("DELETE /api/repro-bundles", s.handlePurgeAll)
 	mux.HandleFunc("GET /api/repro-bundles/{bundle_id}", s.handleGetBundle)
 	mux.HandleFunc("GET /api/repro-bundles/{bundle_id}/artifacts/{artifact_id}", s.handleGetArtifact)
+	mux.HandleFunc("GET /api/repro-bundles/{bundle_id}/artifacts/{artifact_id}/analysis", s.handleGetArtifactAnalysis)
+	mux.HandleFunc("GET /api/repro-bundles/{bundle_id}/{view}", s.handleGetBundleView)
 	mux.HandleFunc("POST /api/repro-bundles/{bundle_id}/tags", s.handleAddTags)
 	mux.HandleFunc("POST /api/repro-bundles/{bundle_id}/notes", s.handleAddNote)
 
+	// Resumable uploads
+	mux.HandleFunc("POST /api/repro-bundles/uploads", s.handleCreateUpload)
+	mux.HandleFunc("GET /api/repro-bundles/uploads/{upload_id}", s.handleGetUpload)
+	mux.HandleFunc("PATCH /api/repro-bundles/uploads/{upload_id}", s.handleAppendUpload)
+	mux.HandleFunc("POST /api/repro-bundles/uploads/{upload_id}/complete", s.handleCompleteUpload)
+	mux.HandleFunc("DELETE /api/repro-bundles/uploads/{upload_id}", s.handleAbortUpload)
+
+	// Ingest jobs
+	mux.HandleFunc("GET /api/ingest-jobs/{job_id}", s.handleGetIngestJob)
+
+	// Quarantined uploads
+	mux.HandleFunc("GET /api/quarantine", s.handleListQuarantine)
+	mux.HandleFunc("GET /api/quarantine/{quarantine_id}", s.handleGetQuarantine)
+	mux.HandleFunc("GET /api/quarantine/{quarantine_id}/download", s.handleDownloadQuarantine)
+	mux.HandleFunc("POST /api/quarantine/{quarantine_id}/reingest", s.handleReingestQuarantine)
+	mux.HandleFunc("DELETE /api/quarantine/{quarantine_id}", s.handleDeleteQuarantine)
+
+	// Crash issues
+	mux.HandleFunc("GET /api/issues", s.handleListIssues)
+	mux.HandleFunc("GET /api/issues/{issue_id}", s.handleGetIssue)
+	mux.HandleFunc("GET /api/issues/{issue_id}/bundles", s.handleListIssueBundles)
+	mux.HandleFunc("POST /api/issues/{issue_id}/merge", s.handleMergeIssues)
+	mux.HandleFunc("POST /api/issues/{issue_id}/split", s.handleSplitIssue)
+
 	// Wrap with middleware
 	return s.loggingMiddleware(mux)
 }
@@ -104,6 +145,8 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		Version:  s.version,
 		Database: "ok",
 		Storage:  "ok",
+
+		StorageBackend: s.storage.Backend().Name(),
 	}
 
 	if err := s.db.CheckHealth(); err != nil {
@@ -116,19 +159,37 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		status.Storage = "error"
 	}
 
+	// Uploads are refused once free space drops to the reserve
+	if usage, err := s.storage.DiskUsage(); err == nil {
+		status.Disk = usage
+		if usage.HeadroomBytes == 0 && status.Storage == "ok" {
+			status.Status = "degraded"
+			status.Storage = "full"
+		}
+	}
+
 	s.writeJSON(w, http.StatusOK, status)
 }
 
 // handleIngestBundle handles POST /api/repro-bundles
+//
+// With ?async=true or "Prefer: respond-async" the upload is staged and
+// queued, and 202 is returned with the ingest job.
 func (s *Server) handleIngestBundle(w http.ResponseWriter, r *http.Request) {
+	if err := s.ingester.CheckSpace(stagingNeed(r.ContentLength)); err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
 	contentType := r.Header.Get("Content-Type")
 
-	var result *ingest.IngestResult
+	var staged *ingest.StagedUpload
 	var err error
 
 	if strings.HasPrefix(contentType, "multipart/form-data") {
-		// Handle multipart upload
-		if err := r.ParseMultipartForm(500 << 20); err != nil { // 500MB max
+		// Stream multipart parts straight to the staging directory
+		mr, mpErr := r.MultipartReader()
+		if mpErr != nil {
 			s.writeError(w, http.StatusBadRequest, &models.APIError{
 				Code:    models.ErrCodeInvalidZip,
 				Message: "failed to parse multipart form",
@@ -136,64 +197,71 @@ func (s *Server) handleIngestBundle(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Try ZIP file first (field name "file")
-		file, _, zipErr := r.FormFile("file")
-		if zipErr == nil {
-			defer file.Close()
-			result, err = s.ingester.IngestFromReader(file, r.ContentLength)
-		} else {
-			// No "file" field - try direct multipart files
-			// This supports Unreal Engine uploads with individual files
-			files := make(map[string][]byte)
-			
-			for name, headers := range r.MultipartForm.File {
-				if len(headers) == 0 {
-					continue
-				}
-				f, openErr := headers[0].Open()
-				if openErr != nil {
-					continue
-				}
-				data, readErr := io.ReadAll(f)
-				f.Close()
-				if readErr != nil {
-					continue
-				}
-				// Use the form field name as the filename
-				files[name] = data
-			}
-			
-			if len(files) == 0 {
-				s.writeError(w, http.StatusBadRequest, &models.APIError{
-					Code:    models.ErrCodeInvalidZip,
-					Message: "no files found in multipart form",
-				})
-				return
-			}
-			
-			result, err = s.ingester.IngestFromFiles(files)
-		}
+		staged, err = s.ingester.StageFromMultipart(mr)
 	} else {
-		// Handle raw ZIP upload
-		result, err = s.ingester.IngestFromReader(r.Body, r.ContentLength)
+		// Handle raw archive upload (ZIP or tar)
+		staged, err = s.ingester.StageFromReader(r.Body)
 	}
 
 	if err != nil {
-		if apiErr, ok := err.(*models.APIError); ok {
-			status := http.StatusBadRequest
-			if apiErr.Code == models.ErrCodeStorageError || apiErr.Code == models.ErrCodeDatabaseError {
-				status = http.StatusInternalServerError
-			}
-			s.writeError(w, status, apiErr)
-		} else {
-			s.writeError(w, http.StatusInternalServerError, &models.APIError{
-				Code:    models.ErrCodeStorageError,
-				Message: err.Error(),
-			})
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.ingestStaged(w, r, staged)
+}
+
+// ingestStaged ingests a staged upload, or queues it if the client asked
+// for asynchronous processing.
+func (s *Server) ingestStaged(w http.ResponseWriter, r *http.Request, staged *ingest.StagedUpload) {
+	staged.Headers = uploadHeaders(r)
+
+	if s.queue != nil && wantsAsync(r) {
+		job, err := s.queue.Submit(staged)
+		if err != nil {
+			s.writeIngestError(w, err)
+			return
 		}
+
+		w.Header().Set("Location", "/api/ingest-jobs/"+job.JobID)
+		s.writeJSON(w, http.StatusAccepted, job)
 		return
 	}
 
+	result, err := s.ingester.IngestStaged(staged, nil)
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.writeIngestResult(w, result)
+}
+
+// wantsAsync reports whether the client asked for asynchronous ingestion.
+func wantsAsync(r *http.Request) bool {
+	if v, err := strconv.ParseBool(r.URL.Query().Get("async")); err == nil {
+		return v
+	}
+	for _, pref := range strings.Split(r.Header.Get("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+			return true
+		}
+	}
+	return false
+}
+
+// stagingNeed is the disk space an upload of size bytes takes up while it is
+// ingested: the archive as received plus its extracted contents. An unknown
+// size needs nothing beyond the reserve.
+func stagingNeed(size int64) int64 {
+	if size <= 0 {
+		return 0
+	}
+	return 2 * size
+}
+
+// writeIngestResult writes 201 for a new bundle or 200 for a duplicate.
+func (s *Server) writeIngestResult(w http.ResponseWriter, result *ingest.IngestResult) {
 	status := http.StatusCreated
 	if result.Status == "already_exists" {
 		status = http.StatusOK
@@ -202,6 +270,183 @@ func (s *Server) handleIngestBundle(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, status, result)
 }
 
+// writeIngestError maps an ingestion error to an HTTP error response.
+func (s *Server) writeIngestError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*models.APIError)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch apiErr.Code {
+	case models.ErrCodeStorageError, models.ErrCodeDatabaseError:
+		status = http.StatusInternalServerError
+	case models.ErrCodeUploadNotFound, models.ErrCodeQuarantineNotFound:
+		status = http.StatusNotFound
+	case models.ErrCodeUploadOffsetMismatch, models.ErrCodeUploadIncomplete, models.ErrCodeBundleIDConflict:
+		status = http.StatusConflict
+	case models.ErrCodeUploadTooLarge, models.ErrCodeArchiveLimitExceeded, models.ErrCodeQuotaExceeded:
+		status = http.StatusRequestEntityTooLarge
+	case models.ErrCodeQueueFull:
+		status = http.StatusServiceUnavailable
+	case models.ErrCodeInsufficientStorage:
+		status = http.StatusInsufficientStorage
+	case models.ErrCodeValidationFailed, models.ErrCodeInvalidMinidump, models.ErrCodeAnalysisUnsupported:
+		status = http.StatusUnprocessableEntity
+	}
+	s.writeError(w, status, apiErr)
+}
+
+// handleCreateUpload handles POST /api/repro-bundles/uploads
+//
+// The total size may be declared via the Upload-Length header so the server
+// can reject overlong chunks and refuse to finalize a short upload.
+func (s *Server) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	var size int64
+	if v := r.Header.Get("Upload-Length"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			s.writeError(w, http.StatusBadRequest, &models.APIError{
+				Code:    "INVALID_REQUEST",
+				Message: "invalid Upload-Length header",
+			})
+			return
+		}
+		size = n
+	}
+
+	if err := s.ingester.CheckSpace(stagingNeed(size)); err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	session, err := s.ingester.CreateUpload(size)
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/repro-bundles/uploads/"+session.UploadID)
+	s.writeUploadSession(w, http.StatusCreated, session)
+}
+
+// handleGetUpload handles GET and HEAD /api/repro-bundles/uploads/{upload_id}
+func (s *Server) handleGetUpload(w http.ResponseWriter, r *http.Request) {
+	session, err := s.ingester.GetUpload(r.PathValue("upload_id"))
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.writeUploadSession(w, http.StatusOK, session)
+}
+
+// handleAppendUpload handles PATCH /api/repro-bundles/uploads/{upload_id}
+//
+// The Upload-Offset header must match the server's current offset.
+func (s *Server) handleAppendUpload(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		s.writeError(w, http.StatusBadRequest, &models.APIError{
+			Code:    "INVALID_REQUEST",
+			Message: "missing or invalid Upload-Offset header",
+		})
+		return
+	}
+
+	// Room for extraction was checked when the upload was created
+	if err := s.ingester.CheckSpace(max(r.ContentLength, 0)); err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	uploadID := r.PathValue("upload_id")
+	session, err := s.ingester.AppendUpload(uploadID, offset, r.Body)
+	if err != nil {
+		// Tell the client where to resume from
+		if current, getErr := s.ingester.GetUpload(uploadID); getErr == nil {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(current.Offset, 10))
+		}
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.writeUploadSession(w, http.StatusOK, session)
+}
+
+// handleCompleteUpload handles POST /api/repro-bundles/uploads/{upload_id}/complete
+//
+// Supports asynchronous ingestion like handleIngestBundle.
+func (s *Server) handleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	uploadID := r.PathValue("upload_id")
+
+	// The received archive is already on disk; extraction needs as much again
+	session, err := s.ingester.GetUpload(uploadID)
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+	if err := s.ingester.CheckSpace(session.Offset); err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	staged, err := s.ingester.StageUpload(uploadID)
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.ingestStaged(w, r, staged)
+}
+
+// handleAbortUpload handles DELETE /api/repro-bundles/uploads/{upload_id}
+func (s *Server) handleAbortUpload(w http.ResponseWriter, r *http.Request) {
+	if err := s.ingester.AbortUpload(r.PathValue("upload_id")); err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeUploadSession writes session state as JSON with resumable upload headers.
+func (s *Server) writeUploadSession(w http.ResponseWriter, status int, session *models.UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	if session.Size > 0 {
+		w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+	}
+	w.Header().Set("Upload-Expires", session.ExpiresAt.Format(http.TimeFormat))
+	s.writeJSON(w, status, session)
+}
+
+// handleGetIngestJob handles GET /api/ingest-jobs/{job_id}
+func (s *Server) handleGetIngestJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("job_id")
+
+	job, err := s.db.GetIngestJob(jobID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		})
+		return
+	}
+	if job == nil {
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeJobNotFound,
+			Message: "ingest job not found: " + jobID,
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, job)
+}
+
 // handlePurgeAll handles DELETE /api/repro-bundles
 func (s *Server) handlePurgeAll(w http.ResponseWriter, r *http.Request) {
 	// Delete from database first
@@ -233,6 +478,10 @@ func (s *Server) handleListBundles(w http.ResponseWriter, r *http.Request) {
 		BuildID:  r.URL.Query().Get("build_id"),
 		MapName:  r.URL.Query().Get("map_name"),
 		Platform: r.URL.Query().Get("platform"),
+
+		ExceptionCode: r.URL.Query().Get("exception_code"),
+		CrashModule:   r.URL.Query().Get("crash_module"),
+		IssueID:       r.URL.Query().Get("issue_id"),
 	}
 
 	if since := r.URL.Query().Get("since"); since != "" {
@@ -250,6 +499,41 @@ func (s *Server) handleListBundles(w http.ResponseWriter, r *http.Request) {
 		query.Offset, _ = strconv.Atoi(offset)
 	}
 
+	if valid := r.URL.Query().Get("valid"); valid != "" {
+		v, err := strconv.ParseBool(valid)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, &models.APIError{
+				Code:    "INVALID_REQUEST",
+				Message: "valid must be true or false",
+			})
+			return
+		}
+		query.Valid = &v
+	}
+
+	if hasCrash := r.URL.Query().Get("has_crash"); hasCrash != "" {
+		v, err := strconv.ParseBool(hasCrash)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, &models.APIError{
+				Code:    "INVALID_REQUEST",
+				Message: "has_crash must be true or false",
+			})
+			return
+		}
+		query.HasCrash = &v
+	}
+
+	switch status := r.URL.Query().Get("validation_status"); status {
+	case "", models.ValidationPassed, models.ValidationFailed, models.ValidationQuarantined, models.ValidationUnvalidated:
+		query.ValidationStatus = status
+	default:
+		s.writeError(w, http.StatusBadRequest, &models.APIError{
+			Code:    "INVALID_REQUEST",
+			Message: "validation_status must be passed, failed, quarantined or unvalidated",
+		})
+		return
+	}
+
 	result, err := s.db.ListBundles(query)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, &models.APIError{
@@ -293,6 +577,52 @@ func (s *Server) handleGetBundle(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, bundle)
 }
 
+// handleGetBundleView handles GET /api/repro-bundles/{bundle_id}/{view}
+//
+// Per-bundle views share one pattern because a literal segment such as
+// {bundle_id}/validation would conflict with uploads/{upload_id} in ServeMux.
+func (s *Server) handleGetBundleView(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("view") {
+	case "validation":
+		s.handleGetValidation(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleGetValidation handles GET /api/repro-bundles/{bundle_id}/validation
+func (s *Server) handleGetValidation(w http.ResponseWriter, r *http.Request) {
+	bundleID := r.PathValue("bundle_id")
+
+	validation, err := s.db.GetBundleValidation(bundleID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if validation == nil {
+		// Distinguish an unknown bundle from one ingested before validation
+		bundle, err := s.db.GetBundle(bundleID)
+		if err == nil && bundle == nil {
+			s.writeError(w, http.StatusNotFound, &models.APIError{
+				Code:    models.ErrCodeBundleNotFound,
+				Message: "bundle not found: " + bundleID,
+			})
+			return
+		}
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeValidationNotFound,
+			Message: "no validation result recorded for bundle: " + bundleID,
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, validation)
+}
+
 // handleGetArtifact handles GET /api/repro-bundles/{bundle_id}/artifacts/{artifact_id}
 func (s *Server) handleGetArtifact(w http.ResponseWriter, r *http.Request) {
 	bundleID := r.PathValue("bundle_id")
@@ -327,21 +657,17 @@ func (s *Server) handleGetArtifact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get file path
-	filePath := s.storage.ArtifactPath(bundle.StoragePath, artifact.StoragePath)
-	s.logger.Info("serving artifact", "path", filePath, "bundle_storage", bundle.StoragePath, "artifact_storage", artifact.StoragePath)
-
-	// Open file
-	f, err := os.Open(filePath)
+	// Stat first so a vanished file is reported before any header is sent
+	name := storage.ArtifactName(bundle.StoragePath, artifact.StoragePath)
+	info, err := s.storage.StatArtifact(bundle.StoragePath, artifact.StoragePath)
 	if err != nil {
-		s.logger.Error("failed to open artifact", "path", filePath, "error", err)
+		s.logger.Error("failed to open artifact", "name", name, "backend", s.storage.Backend().Name(), "error", err)
 		s.writeError(w, http.StatusInternalServerError, &models.APIError{
 			Code:    models.ErrCodeStorageError,
-			Message: fmt.Sprintf("failed to open artifact file: %v (path: %s)", err, filePath),
+			Message: fmt.Sprintf("failed to open artifact file: %v (path: %s)", err, name),
 		})
 		return
 	}
-	defer f.Close()
 
 	// Set content type
 	contentType := artifact.MimeType
@@ -349,10 +675,132 @@ func (s *Server) handleGetArtifact(w http.ResponseWriter, r *http.Request) {
 		contentType = getMimeType(artifact.Filename)
 	}
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.Filename))
-	w.Header().Set("Content-Length", strconv.FormatInt(artifact.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(artifact.Filename)))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	// Expose the ingest-time SHA-256 so clients can prove byte identity
+	etag, digest, hasChecksum := checksumHeaders(artifact.Checksum)
+	if hasChecksum {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Digest", digest)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
 
-	io.Copy(w, f)
+	// Serve a single byte range if asked, unless If-Range names other content
+	offset, length, status := int64(0), info.Size, http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && (r.Header.Get("If-Range") == "" || r.Header.Get("If-Range") == etag) {
+		start, n, ok := parseByteRange(rangeHeader, info.Size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			s.writeError(w, http.StatusRequestedRangeNotSatisfiable, &models.APIError{
+				Code:    models.ErrCodeInvalidRange,
+				Message: "range not satisfiable: " + rangeHeader,
+			})
+			return
+		}
+		if n >= 0 {
+			offset, length, status = start, n, http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+			w.Header().Del("Digest") // Digest covers the whole file
+		}
+	}
+
+	f, err := s.storage.OpenArtifact(bundle.StoragePath, artifact.StoragePath, offset, length)
+	if err != nil {
+		s.logger.Error("failed to open artifact", "name", name, "backend", s.storage.Backend().Name(), "error", err)
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("failed to open artifact file: %v (path: %s)", err, name),
+		})
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		io.Copy(w, f)
+	}
+}
+
+// parseByteRange parses a Range header against a file of the given size.
+// Only single ranges are served; for anything else, such as multiple
+// ranges, length is -1 and the whole file should be sent. ok is false for
+// a range that lies entirely beyond the end of the file.
+func parseByteRange(header string, size int64) (offset, length int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, -1, true
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, -1, true
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, -1, true
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false
+		}
+		n = min(n, size)
+		return size - n, n, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, -1, true
+	}
+	if start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, -1, true
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true
+}
+
+// handleGetArtifactAnalysis handles GET /api/repro-bundles/{bundle_id}/artifacts/{artifact_id}/analysis
+func (s *Server) handleGetArtifactAnalysis(w http.ResponseWriter, r *http.Request) {
+	bundleID := r.PathValue("bundle_id")
+	artifactID := r.PathValue("artifact_id")
+
+	bundle, err := s.db.GetBundle(bundleID)
+	if err != nil || bundle == nil {
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeBundleNotFound,
+			Message: "bundle not found: " + bundleID,
+		})
+		return
+	}
+
+	artifact, err := s.db.GetArtifact(artifactID)
+	if err != nil || artifact == nil || artifact.BundleID != bundleID {
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeArtifactNotFound,
+			Message: "artifact not found in bundle: " + artifactID,
+		})
+		return
+	}
+
+	analysis, err := s.ingester.AnalyzeArtifact(bundle, artifact)
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, analysis)
 }
 
 // handleAddTags handles POST /api/repro-bundles/{bundle_id}/tags
@@ -424,7 +872,7 @@ func (s *Server) handleAddNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	note := &models.QANote{
-		NoteID:  "note_" + generateID(8),
+		NoteID:  "note_" + ids.Generate(8),
 		Author:  req.Author,
 		Content: req.Content,
 	}
@@ -454,6 +902,20 @@ func (s *Server) writeError(w http.ResponseWriter, status int, err *models.APIEr
 	json.NewEncoder(w).Encode(map[string]interface{}{"error": err})
 }
 
+// checksumHeaders converts a stored "sha256:<hex>" checksum into an ETag and
+// an RFC 3230 Digest header value.
+func checksumHeaders(checksum string) (etag, digest string, ok bool) {
+	hexSum, found := strings.CutPrefix(checksum, "sha256:")
+	if !found {
+		return "", "", false
+	}
+	raw, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return "", "", false
+	}
+	return `"` + hexSum + `"`, "sha-256=" + base64.StdEncoding.EncodeToString(raw), true
+}
+
 // getMimeType returns MIME type based on file extension.
 func getMimeType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -478,13 +940,3 @@ func getMimeType(filename string) string {
 		return "application/octet-stream"
 	}
 }
-
-// generateID generates a random hex ID.
-func generateID(length int) string {
-	b := make([]byte, length)
-	if _, err := io.ReadFull(rand.Reader, b); err != nil {
-		// Fallback to timestamp-based ID
-		return fmt.Sprintf("%x", time.Now().UnixNano())[:length]
-	}
-	return fmt.Sprintf("%x", b)[:length]
-}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// handleListIssues handles GET /api/issues
+func (s *Server) handleListIssues(w http.ResponseWriter, r *http.Request) {
+	query := &models.IssueListQuery{
+		BuildID:  r.URL.Query().Get("build_id"),
+		Platform: r.URL.Query().Get("platform"),
+		Sort:     r.URL.Query().Get("sort"),
+	}
+
+	switch query.Sort {
+	case "", "last_seen", "first_seen", "count":
+	default:
+		s.writeError(w, http.StatusBadRequest, &models.APIError{
+			Code:    "INVALID_REQUEST",
+			Message: "sort must be last_seen, first_seen or count",
+		})
+		return
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		query.Limit, _ = strconv.Atoi(limit)
+	}
+	if offset := r.URL.Query().Get("offset"); offset != "" {
+		query.Offset, _ = strconv.Atoi(offset)
+	}
+
+	result, err := s.db.ListIssues(query)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetIssue handles GET /api/issues/{issue_id}
+func (s *Server) handleGetIssue(w http.ResponseWriter, r *http.Request) {
+	issueID := r.PathValue("issue_id")
+
+	issue, err := s.db.GetIssue(issueID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		})
+		return
+	}
+	if issue == nil {
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeIssueNotFound,
+			Message: "issue not found: " + issueID,
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, issue)
+}
+
+// handleListIssueBundles handles GET /api/issues/{issue_id}/bundles
+//
+// It takes the query parameters of GET /api/repro-bundles.
+func (s *Server) handleListIssueBundles(w http.ResponseWriter, r *http.Request) {
+	issueID := r.PathValue("issue_id")
+
+	issue, err := s.db.GetIssue(issueID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		})
+		return
+	}
+	if issue == nil {
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeIssueNotFound,
+			Message: "issue not found: " + issueID,
+		})
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("issue_id", issueID)
+	r.URL.RawQuery = q.Encode()
+	s.handleListBundles(w, r)
+}
+
+// handleMergeIssues handles POST /api/issues/{issue_id}/merge
+func (s *Server) handleMergeIssues(w http.ResponseWriter, r *http.Request) {
+	issueID := r.PathValue("issue_id")
+
+	var req struct {
+		IssueIDs []string `json:"issue_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.IssueIDs) == 0 {
+		s.writeError(w, http.StatusBadRequest, &models.APIError{
+			Code:    "INVALID_REQUEST",
+			Message: "body must be a JSON object with a non-empty issue_ids array",
+		})
+		return
+	}
+	for _, id := range req.IssueIDs {
+		if id == issueID {
+			s.writeError(w, http.StatusBadRequest, &models.APIError{
+				Code:    "INVALID_REQUEST",
+				Message: "an issue cannot be merged into itself: " + id,
+			})
+			return
+		}
+	}
+
+	if err := s.db.MergeIssues(issueID, req.IssueIDs); err != nil {
+		s.writeIssueError(w, err)
+		return
+	}
+
+	s.logger.Info("merged issues", "issue_id", issueID, "merged", req.IssueIDs)
+	s.writeIssue(w, http.StatusOK, issueID)
+}
+
+// handleSplitIssue handles POST /api/issues/{issue_id}/split
+func (s *Server) handleSplitIssue(w http.ResponseWriter, r *http.Request) {
+	issueID := r.PathValue("issue_id")
+
+	var req struct {
+		Signatures []string `json:"signatures"`
+		BundleIDs  []string `json:"bundle_ids"`
+		Title      string   `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Signatures)+len(req.BundleIDs) == 0 {
+		s.writeError(w, http.StatusBadRequest, &models.APIError{
+			Code:    "INVALID_REQUEST",
+			Message: "body must be a JSON object with signatures or bundle_ids to split off",
+		})
+		return
+	}
+
+	newID, err := s.db.SplitIssue(issueID, req.Signatures, req.BundleIDs, req.Title)
+	if err != nil {
+		s.writeIssueError(w, err)
+		return
+	}
+
+	s.logger.Info("split issue", "issue_id", issueID, "new_issue_id", newID)
+	s.writeIssue(w, http.StatusCreated, newID)
+}
+
+// writeIssueError maps a MergeIssues or SplitIssue error to a response.
+func (s *Server) writeIssueError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrIssueNotFound):
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeIssueNotFound,
+			Message: err.Error(),
+		})
+	case errors.Is(err, db.ErrNotIssueMember):
+		s.writeError(w, http.StatusBadRequest, &models.APIError{
+			Code:    "INVALID_REQUEST",
+			Message: err.Error(),
+		})
+	default:
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		})
+	}
+}
+
+// writeIssue responds with the current state of an issue.
+func (s *Server) writeIssue(w http.ResponseWriter, status int, issueID string) {
+	issue, err := s.db.GetIssue(issueID)
+	if err != nil || issue == nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: "reload issue " + issueID,
+		})
+		return
+	}
+	s.writeJSON(w, status, issue)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// redactedHeaders are never stored with a quarantined upload.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// uploadHeaders returns the request headers to keep with a quarantined
+// upload. Credentials are redacted.
+func uploadHeaders(r *http.Request) map[string]string {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if redactedHeaders[name] || strings.Contains(lower, "token") || strings.Contains(lower, "api-key") {
+			headers[name] = "[redacted]"
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// handleListQuarantine handles GET /api/quarantine
+func (s *Server) handleListQuarantine(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.storage.ListQuarantine()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
+
+// handleGetQuarantine handles GET /api/quarantine/{quarantine_id}
+func (s *Server) handleGetQuarantine(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.loadQuarantine(w, r)
+	if !ok {
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, entry)
+}
+
+// handleDownloadQuarantine handles GET /api/quarantine/{quarantine_id}/download
+func (s *Server) handleDownloadQuarantine(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.loadQuarantine(w, r)
+	if !ok {
+		return
+	}
+
+	name := ingest.QuarantineFileName(entry)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if err := s.storage.WriteQuarantineArchive(entry, w); err != nil {
+		s.logger.Error("failed to send quarantined upload", "quarantine_id", entry.QuarantineID, "error", err)
+	}
+}
+
+// handleReingestQuarantine handles POST /api/quarantine/{quarantine_id}/reingest
+func (s *Server) handleReingestQuarantine(w http.ResponseWriter, r *http.Request) {
+	result, err := s.ingester.ReingestQuarantined(r.PathValue("quarantine_id"))
+	if err != nil {
+		s.writeIngestError(w, err)
+		return
+	}
+
+	s.writeIngestResult(w, result)
+}
+
+// handleDeleteQuarantine handles DELETE /api/quarantine/{quarantine_id}
+func (s *Server) handleDeleteQuarantine(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.loadQuarantine(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.RemoveQuarantine(entry.QuarantineID); err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadQuarantine loads the entry named in the path, writing 404 if there is
+// none.
+func (s *Server) loadQuarantine(w http.ResponseWriter, r *http.Request) (*models.QuarantineEntry, bool) {
+	quarantineID := r.PathValue("quarantine_id")
+
+	entry, err := s.storage.LoadQuarantine(quarantineID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	if entry == nil {
+		s.writeError(w, http.StatusNotFound, &models.APIError{
+			Code:    models.ErrCodeQuarantineNotFound,
+			Message: "quarantined upload not found: " + quarantineID,
+		})
+		return nil, false
+	}
+
+	return entry, true
+}
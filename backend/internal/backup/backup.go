@@ -0,0 +1,360 @@
+// Package backup takes consistent backups of a data directory while the
+// server runs, and restores them.
+//
+// A backup directory holds database snapshots and one shared copy of each
+// bundle directory:
+//
+//	<backup>/
+//	├── backup.json                              # Manifest
+//	├── snapshots/
+//	│   └── bugit-20260121T020000.000000000Z.db  # One per run
+//	└── bundles/
+//	    └── rb_a1b2c3d4/                         # Copied once
+//
+// Each run snapshots the database with VACUUM INTO and then copies the
+// bundle directories the snapshot references that earlier runs have not
+// copied yet. Bundles are immutable once ingested, so a bundle copied once
+// stays valid; bundles ingested after the snapshot was taken are left for
+// the next run. The manifest records every snapshot with its checksum and
+// every bundle copied, and is rewritten atomically at the end of a run.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// ManifestName is the manifest file in a backup directory.
+const ManifestName = "backup.json"
+
+// manifestVersion is the format version of the manifest.
+const manifestVersion = 1
+
+// DefaultKeep is how many snapshots a backup directory keeps by default.
+const DefaultKeep = 7
+
+// Manifest describes the contents of a backup directory.
+type Manifest struct {
+	Version   int                       `json:"version"`
+	Snapshots []Snapshot                `json:"snapshots"` // Oldest first
+	Bundles   map[string]BackedUpBundle `json:"bundles"`   // Keyed by directory name
+}
+
+// Snapshot is one database snapshot.
+type Snapshot struct {
+	Name      string    `json:"name"` // File name in snapshots/
+	CreatedAt time.Time `json:"created_at"`
+	SizeBytes int64     `json:"size_bytes"`
+	Checksum  string    `json:"checksum"` // sha256: of the file
+	Bundles   int       `json:"bundles"`  // Bundle rows in the snapshot
+}
+
+// BackedUpBundle is a bundle directory copied into the backup.
+type BackedUpBundle struct {
+	BundleID   string    `json:"bundle_id"`
+	Files      int       `json:"files"`
+	SizeBytes  int64     `json:"size_bytes"`
+	BackedUpAt time.Time `json:"backed_up_at"`
+}
+
+// Failure is a bundle that could not be copied.
+type Failure struct {
+	BundleID string `json:"bundle_id"`
+	Error    string `json:"error"`
+}
+
+// Report summarizes a backup run.
+type Report struct {
+	Snapshot      Snapshot  `json:"snapshot"`
+	Copied        []string  `json:"copied"` // Bundles copied by this run
+	CopiedBytes   int64     `json:"copied_bytes"`
+	Unchanged     int       `json:"unchanged"`         // Bundles copied by earlier runs
+	Missing       []string  `json:"missing,omitempty"` // Bundles deleted since the snapshot was taken
+	Failed        []Failure `json:"failed,omitempty"`
+	Pruned        []string  `json:"pruned,omitempty"` // Snapshots removed beyond Keep
+	PrunedBundles int       `json:"pruned_bundles"`   // Bundles no kept snapshot references
+}
+
+// Backup copies a database and its bundle storage into a backup directory.
+type Backup struct {
+	db      *db.DB
+	storage *storage.Storage
+	dir     string
+	keep    int
+	logger  *slog.Logger
+}
+
+// New creates a backup into dir that keeps the keep most recent snapshots
+// (0 keeps all).
+func New(database *db.DB, store *storage.Storage, dir string, keep int) *Backup {
+	return &Backup{
+		db:      database,
+		storage: store,
+		dir:     dir,
+		keep:    keep,
+		logger:  slog.Default(),
+	}
+}
+
+// Run takes a snapshot and copies the bundles it references that are not
+// in the backup yet. It is safe to run against a live server.
+func (b *Backup) Run() (*Report, error) {
+	for _, sub := range []string{"snapshots", "bundles"} {
+		if err := os.MkdirAll(filepath.Join(b.dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("create backup dir: %w", err)
+		}
+	}
+
+	manifest, err := LoadManifest(b.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		manifest = &Manifest{Version: manifestVersion, Bundles: map[string]BackedUpBundle{}}
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshot, paths, err := b.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Snapshot: *snapshot, Copied: []string{}}
+
+	bundleIDs := make([]string, 0, len(paths))
+	for bundleID := range paths {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+
+	for _, bundleID := range bundleIDs {
+		storagePath := filepath.ToSlash(paths[bundleID])
+		if storagePath == "" {
+			continue
+		}
+		name := path.Base(storagePath)
+		if _, ok := manifest.Bundles[name]; ok && dirExists(filepath.Join(b.dir, "bundles", name)) {
+			report.Unchanged++
+			continue
+		}
+
+		copied, err := b.copyBundle(bundleID, storagePath, name)
+		switch {
+		case err != nil:
+			b.logger.Warn("failed to back up bundle", "bundle_id", bundleID, "error", err)
+			report.Failed = append(report.Failed, Failure{BundleID: bundleID, Error: err.Error()})
+		case copied == nil:
+			report.Missing = append(report.Missing, bundleID)
+		default:
+			manifest.Bundles[name] = *copied
+			report.Copied = append(report.Copied, bundleID)
+			report.CopiedBytes += copied.SizeBytes
+		}
+	}
+
+	manifest.Snapshots = append(manifest.Snapshots, *snapshot)
+	if err := b.prune(manifest, report); err != nil {
+		return nil, err
+	}
+
+	if err := writeManifest(b.dir, manifest); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// snapshot writes and verifies a new database snapshot and returns it with
+// the storage paths of the bundles it references.
+func (b *Backup) snapshot() (*Snapshot, map[string]string, error) {
+	// Nanoseconds keep runs started within the same second apart
+	now := time.Now().UTC()
+	snapshot := &Snapshot{
+		Name:      "bugit-" + now.Format("20060102T150405.000000000Z") + ".db",
+		CreatedAt: now,
+	}
+	final := filepath.Join(b.dir, "snapshots", snapshot.Name)
+	if _, err := os.Stat(final); err == nil {
+		return nil, nil, fmt.Errorf("snapshot %s already exists", snapshot.Name)
+	}
+
+	tmp := final + ".tmp"
+	os.Remove(tmp)
+	if err := b.db.Snapshot(tmp); err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(tmp)
+
+	paths, err := readSnapshot(tmp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("verify snapshot: %w", err)
+	}
+	snapshot.Bundles = len(paths)
+
+	if snapshot.SizeBytes, err = storage.FileSize(tmp); err != nil {
+		return nil, nil, err
+	}
+	if snapshot.Checksum, err = storage.HashFile(tmp); err != nil {
+		return nil, nil, fmt.Errorf("hash snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		return nil, nil, fmt.Errorf("save snapshot: %w", err)
+	}
+	return snapshot, paths, nil
+}
+
+// copyBundle copies a bundle directory into the backup under name. It
+// returns nil without error if the bundle no longer has any files.
+func (b *Backup) copyBundle(bundleID, storagePath, name string) (*BackedUpBundle, error) {
+	if !b.storage.BundleExists(storagePath) {
+		return nil, nil
+	}
+
+	// Copied next to its final place, so an interrupted run never leaves
+	// a partial directory under its real name
+	dest := filepath.Join(b.dir, "bundles", name)
+	partial := filepath.Join(b.dir, "bundles", "."+name+".partial")
+	os.RemoveAll(partial)
+	if err := os.MkdirAll(partial, 0755); err != nil {
+		return nil, err
+	}
+	if err := b.storage.FetchBundle(storagePath, partial); err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+
+	copied := &BackedUpBundle{BundleID: bundleID, BackedUpAt: time.Now().UTC().Truncate(time.Second)}
+	err := filepath.WalkDir(partial, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		copied.Files++
+		copied.SizeBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+
+	// A directory left by a run that did not finish its manifest
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+	return copied, nil
+}
+
+// prune removes the snapshots beyond b.keep, and then the bundles that no
+// kept snapshot references.
+func (b *Backup) prune(manifest *Manifest, report *Report) error {
+	if b.keep <= 0 || len(manifest.Snapshots) <= b.keep {
+		return nil
+	}
+
+	drop := manifest.Snapshots[:len(manifest.Snapshots)-b.keep]
+	manifest.Snapshots = slices.Clone(manifest.Snapshots[len(drop):])
+	for _, s := range drop {
+		if err := os.Remove(filepath.Join(b.dir, "snapshots", s.Name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove snapshot %s: %w", s.Name, err)
+		}
+		report.Pruned = append(report.Pruned, s.Name)
+	}
+
+	referenced := make(map[string]bool)
+	for _, s := range manifest.Snapshots {
+		paths, err := readSnapshot(filepath.Join(b.dir, "snapshots", s.Name))
+		if err != nil {
+			// Keep every bundle rather than lose one a snapshot needs
+			b.logger.Warn("cannot read snapshot, not pruning bundles", "snapshot", s.Name, "error", err)
+			return nil
+		}
+		for _, storagePath := range paths {
+			if storagePath != "" {
+				referenced[path.Base(filepath.ToSlash(storagePath))] = true
+			}
+		}
+	}
+
+	for name := range manifest.Bundles {
+		if referenced[name] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(b.dir, "bundles", name)); err != nil {
+			return fmt.Errorf("remove bundle %s: %w", name, err)
+		}
+		delete(manifest.Bundles, name)
+		report.PrunedBundles++
+	}
+	return nil
+}
+
+// LoadManifest reads the manifest of a backup directory. A directory
+// without one yields an error matching fs.ErrNotExist.
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestName, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported backup manifest version %d", manifest.Version)
+	}
+	if manifest.Bundles == nil {
+		manifest.Bundles = map[string]BackedUpBundle{}
+	}
+	return &manifest, nil
+}
+
+// writeManifest replaces the manifest of a backup directory atomically.
+func writeManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, ManifestName+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, ManifestName)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// readSnapshot verifies a snapshot and returns the storage paths of its
+// bundles, keyed by bundle ID.
+func readSnapshot(file string) (map[string]string, error) {
+	snapshot, err := db.OpenSnapshot(file)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+	return snapshot.ListBundleStoragePaths()
+}
+
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// RestoreReport summarizes a restore.
+type RestoreReport struct {
+	Snapshot      Snapshot  `json:"snapshot"`
+	Restored      []string  `json:"restored"` // Bundles copied into storage
+	RestoredBytes int64     `json:"restored_bytes"`
+	Existing      int       `json:"existing"`          // Bundles already in storage
+	Missing       []string  `json:"missing,omitempty"` // Bundles the backup has no copy of
+	Failed        []Failure `json:"failed,omitempty"`
+}
+
+// FindSnapshot returns the snapshot named name in the manifest, or the
+// latest if name is empty.
+func (m *Manifest) FindSnapshot(name string) (*Snapshot, error) {
+	if len(m.Snapshots) == 0 {
+		return nil, errors.New("backup has no snapshots")
+	}
+	if name == "" {
+		return &m.Snapshots[len(m.Snapshots)-1], nil
+	}
+	for n := range m.Snapshots {
+		if m.Snapshots[n].Name == name {
+			return &m.Snapshots[n], nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot %s not found", name)
+}
+
+// Restore rehydrates the data directory of store from the backup in dir,
+// using the snapshot named snapshotName or the latest if it is empty. The
+// snapshot's checksum and integrity are verified before anything is
+// written.
+//
+// Bundles already in storage are left alone, which makes a restore that
+// was interrupted safe to run again and lets object storage that outlived
+// the data directory be reused. The database is written last, and the data
+// directory must not have one yet: restore into a new directory while the
+// old server keeps running, then switch over.
+func Restore(dir, snapshotName string, store *storage.Storage) (*RestoreReport, error) {
+	dbPath := store.DBPath()
+	if _, err := os.Stat(dbPath); err == nil {
+		return nil, fmt.Errorf("%s already exists; restore into a new data directory or move it aside", dbPath)
+	}
+
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("load backup manifest: %w", err)
+	}
+	snapshot, err := manifest.FindSnapshot(snapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(dir, "snapshots", snapshot.Name)
+	checksum, err := storage.HashFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("hash snapshot: %w", err)
+	}
+	if checksum != snapshot.Checksum {
+		return nil, fmt.Errorf("snapshot %s is corrupt: checksum %s, expected %s", snapshot.Name, checksum, snapshot.Checksum)
+	}
+	paths, err := readSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("verify snapshot %s: %w", snapshot.Name, err)
+	}
+
+	report := &RestoreReport{Snapshot: *snapshot, Restored: []string{}}
+
+	bundleIDs := make([]string, 0, len(paths))
+	for bundleID := range paths {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+
+	for _, bundleID := range bundleIDs {
+		storagePath := filepath.ToSlash(paths[bundleID])
+		if storagePath == "" {
+			continue
+		}
+		if store.BundleExists(storagePath) {
+			report.Existing++
+			continue
+		}
+
+		src := filepath.Join(dir, "bundles", path.Base(storagePath))
+		if !dirExists(src) {
+			report.Missing = append(report.Missing, bundleID)
+			continue
+		}
+
+		size, err := restoreBundle(store, src, storagePath)
+		if err != nil {
+			report.Failed = append(report.Failed, Failure{BundleID: bundleID, Error: err.Error()})
+			continue
+		}
+		report.Restored = append(report.Restored, bundleID)
+		report.RestoredBytes += size
+	}
+
+	// Stale WAL files would be replayed into the restored database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	tmp := dbPath + ".restore"
+	if _, err := copyFile(file, tmp); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("copy snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("copy snapshot: %w", err)
+	}
+
+	return report, nil
+}
+
+// restoreBundle stages a copy of a backed-up bundle directory and commits
+// it to storage at storagePath. It returns the bytes copied.
+func restoreBundle(store *storage.Storage, src, storagePath string) (int64, error) {
+	staging, err := store.CreateTempDir("restore_" + ids.Generate(8))
+	if err != nil {
+		return 0, err
+	}
+	defer store.RemoveTempDir(staging)
+
+	var size int64
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(staging, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		n, err := copyFile(p, dest)
+		size += n
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := store.Backend().Commit(staging, storagePath); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func copyFile(src, dest string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// AnnotationsCmd returns the annotations command group.
+func AnnotationsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "annotations",
+		Short: "Export and import bundle tags and notes",
+		Long: `Tags and notes live only in the database. Export them regularly so
+that they can be restored after 'bugit reindex' rebuilds a lost database.`,
+	}
+
+	cmd.AddCommand(annotationsExportCmd(), annotationsImportCmd())
+	return cmd
+}
+
+func annotationsExportCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write all tags and notes as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			annotations, err := database.ExportAnnotations()
+			if err != nil {
+				return fmt.Errorf("export annotations: %w", err)
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(annotations); err != nil {
+				return err
+			}
+
+			if output != "" {
+				fmt.Printf("Exported annotations of %d bundles to %s\n", len(annotations.Bundles), output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to this file instead of stdout")
+
+	return cmd
+}
+
+func annotationsImportCmd() *cobra.Command {
+	var outputJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Restore tags and notes from an export",
+		Long: `Adds the tags and notes in an annotations export to the bundles they
+belong to. Tags and notes already present are kept, so importing the same
+export twice is harmless. Bundles that are not in the database are listed
+and skipped.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			annotations, err := readAnnotations(args[0])
+			if err != nil {
+				return err
+			}
+
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			result, err := database.ImportAnnotations(annotations)
+			if err != nil {
+				return fmt.Errorf("import annotations: %w", err)
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(result)
+			}
+			printAnnotationsImport(result)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+// readAnnotations reads an annotations export from path.
+func readAnnotations(path string) (*models.Annotations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations models.Annotations
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &annotations, nil
+}
+
+func printAnnotationsImport(result *models.AnnotationsImport) {
+	for _, bundleID := range result.Missing {
+		fmt.Printf("Skipped %s: not in the database\n", bundleID)
+	}
+	fmt.Printf("Imported %d tags and %d notes, %d bundles skipped.\n",
+		result.Tags, result.Notes, len(result.Missing))
+}
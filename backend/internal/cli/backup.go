@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/backup"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/fsck"
+)
+
+// BackupCmd returns the backup command.
+func BackupCmd() *cobra.Command {
+	var (
+		keep       int
+		outputJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "backup <backup-dir>",
+		Short: "Back up the database and bundles while the server runs",
+		Long: `Takes a consistent snapshot of the database with VACUUM INTO and copies
+the bundle directories it references into <backup-dir>. Only bundles that
+earlier backups into the same directory have not copied are copied, as
+recorded in <backup-dir>/backup.json.
+
+Safe to run while bugit serve is running: bundles ingested after the
+snapshot was taken are picked up by the next backup. Snapshots beyond
+--keep are removed, together with the bundles none of the remaining
+snapshots reference.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			report, err := backup.New(database, store, args[0], keep).Run()
+			if err != nil {
+				return err
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printBackupReport(report)
+			}
+
+			if len(report.Failed) > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d bundles could not be backed up", len(report.Failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&keep, "keep", backup.DefaultKeep, "Number of snapshots to keep (0 = all)")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func printBackupReport(report *backup.Report) {
+	for _, bundleID := range report.Missing {
+		fmt.Printf("Skipped %s: deleted since the snapshot was taken\n", bundleID)
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("FAILED %s: %s\n", f.BundleID, f.Error)
+	}
+	for _, name := range report.Pruned {
+		fmt.Printf("Removed snapshot %s\n", name)
+	}
+
+	fmt.Printf("Snapshot %s (%d bundles, %s).\n",
+		report.Snapshot.Name, report.Snapshot.Bundles, formatBytes(report.Snapshot.SizeBytes))
+	fmt.Printf("Copied %d bundles (%s), %d already backed up, %d pruned.\n",
+		len(report.Copied), formatBytes(report.CopiedBytes), report.Unchanged, report.PrunedBundles)
+}
+
+// RestoreCmd returns the restore command.
+func RestoreCmd() *cobra.Command {
+	var (
+		snapshot   string
+		list       bool
+		checksums  bool
+		outputJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore <backup-dir>",
+		Short: "Restore a data directory from a backup",
+		Long: `Verifies a snapshot in <backup-dir> against its checksum and with
+PRAGMA integrity_check, copies the bundles it references into storage and
+writes it as the database of --data-dir. The latest snapshot is used unless
+--snapshot names another; --list shows them.
+
+The data directory must not have a database yet. Restore into a new
+directory while the old server keeps running, then point bugit serve at
+it. Bundles already in storage are kept, so an interrupted restore can be
+run again. Afterwards the restored data directory is checked as by
+bugit fsck.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return listSnapshots(args[0], outputJSON)
+			}
+
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			report, err := backup.Restore(args[0], snapshot, store)
+			if err != nil {
+				return err
+			}
+
+			// Migrates snapshots taken by older releases
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open restored database: %w", err)
+			}
+			defer database.Close()
+
+			check, err := fsck.New(database, store).Check(fsck.Options{Checksums: checksums})
+			if err != nil {
+				return fmt.Errorf("check restored data: %w", err)
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(struct {
+					*backup.RestoreReport
+					Check *fsck.Report `json:"check"`
+				}{report, check}); err != nil {
+					return err
+				}
+			} else {
+				printRestoreReport(report)
+				printFsckReport(check)
+			}
+
+			cmd.SilenceUsage = true
+			if len(report.Missing) > 0 || len(report.Failed) > 0 {
+				return fmt.Errorf("%d bundles could not be restored", len(report.Missing)+len(report.Failed))
+			}
+			if n := check.Unrepaired(); n > 0 {
+				return fmt.Errorf("restored data has %d problems", n)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&snapshot, "snapshot", "", "Snapshot to restore (default latest)")
+	cmd.Flags().BoolVar(&list, "list", false, "List the snapshots in the backup instead of restoring")
+	cmd.Flags().BoolVar(&checksums, "checksums", true, "Hash every restored file when checking")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func listSnapshots(dir string, outputJSON bool) error {
+	manifest, err := backup.LoadManifest(dir)
+	if err != nil {
+		return fmt.Errorf("load backup manifest: %w", err)
+	}
+
+	if outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(manifest.Snapshots)
+	}
+	if len(manifest.Snapshots) == 0 {
+		fmt.Println("No snapshots in backup.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tCREATED\tBUNDLES\tSIZE")
+	fmt.Fprintln(w, "--------\t-------\t-------\t----")
+	for _, s := range manifest.Snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+			s.Name,
+			s.CreatedAt.Format("2006-01-02 15:04"),
+			s.Bundles,
+			formatBytes(s.SizeBytes),
+		)
+	}
+	return w.Flush()
+}
+
+func printRestoreReport(report *backup.RestoreReport) {
+	for _, bundleID := range report.Missing {
+		fmt.Printf("MISSING %s: not in the backup\n", bundleID)
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("FAILED %s: %s\n", f.BundleID, f.Error)
+	}
+
+	fmt.Printf("Restored snapshot %s: %d bundles copied (%s), %d already in storage.\n",
+		report.Snapshot.Name, len(report.Restored), formatBytes(report.RestoredBytes), report.Existing)
+}
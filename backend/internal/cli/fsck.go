@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/fsck"
+)
+
+// FsckCmd returns the fsck command.
+func FsckCmd() *cobra.Command {
+	var (
+		opts       fsck.Options
+		outputJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Check that stored bundle files match the database",
+		Long: `Compares every bundle and artifact in the database with the files in
+bundle storage and reports missing bundles and files, size and checksum
+mismatches, files without an artifact row, bundle directories without a
+bundle row and abandoned uploads in tmp/.
+
+With --repair, safe fixes are applied: unlisted files are registered as
+undeclared artifacts, bundles with missing or altered files are marked
+damaged, and abandoned uploads are removed. Orphan bundle directories are
+only reported.
+
+Exits with an error if any problem is left unrepaired, so it can gate CI.
+Safe to run while bugit serve is running.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			report, err := fsck.New(database, store).Check(opts)
+			if err != nil {
+				return err
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printFsckReport(report)
+			}
+
+			cmd.SilenceUsage = true
+			if n := report.Unrepaired(); n > 0 {
+				return fmt.Errorf("%d problems left unrepaired", n)
+			}
+			if len(report.Errors) > 0 {
+				return fmt.Errorf("%d checks or repairs failed", len(report.Errors))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.Checksums, "checksums", true, "Hash every file instead of only comparing sizes")
+	cmd.Flags().BoolVar(&opts.Repair, "repair", false, "Apply safe fixes")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func printFsckReport(report *fsck.Report) {
+	if len(report.Problems) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROBLEM\tBUNDLE ID\tPATH\tDETAIL\tREPAIR")
+		fmt.Fprintln(w, "-------\t---------\t----\t------\t------")
+		for _, p := range report.Problems {
+			detail := ""
+			if p.Expected != "" {
+				detail = fmt.Sprintf("expected %s, got %s", p.Expected, p.Actual)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				p.Kind,
+				orDash(p.BundleID),
+				p.Path,
+				orDash(detail),
+				orDash(p.Repair),
+			)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	for _, bundleID := range report.Undamaged {
+		fmt.Printf("UNDAMAGED %s: files check out again\n", bundleID)
+	}
+	for _, e := range report.Errors {
+		fmt.Printf("FAILED %s\n", e)
+	}
+
+	fmt.Printf("Checked %d bundles and %d artifacts: %d problems, %d unrepaired.\n",
+		report.Bundles, report.Artifacts, len(report.Problems), report.Unrepaired())
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
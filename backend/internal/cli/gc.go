@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/retention"
+)
+
+// GCCmd returns the gc command.
+func GCCmd() *cobra.Command {
+	var (
+		policy     retention.Policy
+		maxTotalMB int64
+		dryRun     bool
+		history    int
+		outputJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete bundles according to retention rules",
+		Long: `Deletes bundles selected by the retention rules: those beyond the
+--keep-per-build most recent of their build, those older than --max-age, and
+then the oldest until the rest fit in --max-total-mb. Bundles tagged with
+one of --pinned-tags are never deleted.
+
+Each deletion removes the bundle's database rows and its directory and is
+recorded in the retention_audit table. Use --dry-run to see what would be
+deleted, and --history to show past deletions.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			if history > 0 {
+				entries, err := database.ListRetentionAudit(history)
+				if err != nil {
+					return fmt.Errorf("list retention audit: %w", err)
+				}
+				if outputJSON {
+					enc := json.NewEncoder(os.Stdout)
+					enc.SetIndent("", "  ")
+					return enc.Encode(entries)
+				}
+				if len(entries) == 0 {
+					fmt.Println("No bundles have been deleted by retention.")
+					return nil
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "DELETED\tBUNDLE ID\tBUILD\tSIZE\tREASON")
+				fmt.Fprintln(w, "-------\t---------\t-----\t----\t------")
+				for _, e := range entries {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+						e.DeletedAt.Format("2006-01-02 15:04"),
+						e.BundleID,
+						e.BuildID,
+						formatBytes(e.SizeBytes),
+						e.Reason,
+					)
+				}
+				return w.Flush()
+			}
+
+			policy.MaxTotalBytes = maxTotalMB << 20
+			if !policy.Enabled() {
+				return fmt.Errorf("no retention rule set (use --max-age, --max-total-mb or --keep-per-build)")
+			}
+
+			report, err := retention.New(database, store, policy).Collect(dryRun)
+			if err != nil {
+				return err
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printRetentionReport(report)
+			}
+
+			if len(report.Failed) > 0 {
+				return fmt.Errorf("%d bundles could not be deleted", len(report.Failed))
+			}
+			return nil
+		},
+	}
+
+	addRetentionFlags(cmd, "", &policy, &maxTotalMB)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report what would be deleted")
+	cmd.Flags().IntVar(&history, "history", 0, "Show the last N retention deletions instead of collecting")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+// addRetentionFlags registers the retention rule flags, each name prefixed
+// with prefix.
+func addRetentionFlags(cmd *cobra.Command, prefix string, policy *retention.Policy, maxTotalMB *int64) {
+	cmd.Flags().DurationVar(&policy.MaxAge, prefix+"max-age", 0, "Delete bundles ingested longer ago than this, e.g. 720h (0 = off)")
+	cmd.Flags().Int64Var(maxTotalMB, prefix+"max-total-mb", 0, "Delete the oldest bundles while all bundles exceed this size in MB (0 = off)")
+	cmd.Flags().IntVar(&policy.KeepPerBuild, prefix+"keep-per-build", 0, "Delete bundles beyond the N most recent of each build (0 = off)")
+	cmd.Flags().StringSliceVar(&policy.PinnedTags, prefix+"pinned-tags", retention.DefaultPinnedTags, "Bundles with any of these tags are never deleted")
+}
+
+func printRetentionReport(report *retention.Report) {
+	verb := "Deleted"
+	if report.DryRun {
+		verb = "Would delete"
+	}
+
+	if len(report.Deleted) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BUNDLE ID\tBUILD\tCREATED\tSIZE\tREASON")
+		fmt.Fprintln(w, "---------\t-----\t-------\t----\t------")
+		for _, e := range report.Deleted {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				e.BundleID,
+				e.BuildID,
+				e.BundleCreatedAt.Format("2006-01-02 15:04"),
+				formatBytes(e.SizeBytes),
+				e.Reason,
+			)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	for _, f := range report.Failed {
+		fmt.Printf("FAILED %s: %s\n", f.BundleID, f.Error)
+	}
+
+	fmt.Printf("%s %d bundles (%s). Kept %d, pinned %d, %s remaining.\n",
+		verb, len(report.Deleted), formatBytes(report.FreedBytes),
+		report.Kept, report.Pinned, formatBytes(report.TotalBytes))
+}
@@ -3,36 +3,78 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/unrealsolutions/bugit/internal/db"
 	"github.com/unrealsolutions/bugit/internal/ingest"
-	"github.com/unrealsolutions/bugit/internal/storage"
+	"github.com/unrealsolutions/bugit/internal/models"
 )
 
+// DefaultIngestWorkers is the default number of parallel CLI ingest workers.
+const DefaultIngestWorkers = 4
+
+// fileIngestResult is the outcome of ingesting one file.
+type fileIngestResult struct {
+	Path          string           `json:"path"`
+	Status        string           `json:"status"` // ingested, already_exists, failed
+	BundleID      string           `json:"bundle_id,omitempty"`
+	ArtifactCount int              `json:"artifact_count,omitempty"`
+	Error         *models.APIError `json:"error,omitempty"`
+	DurationMs    int64            `json:"duration_ms"`
+	index         int
+}
+
+// ingestSummary aggregates the results of a bulk ingest.
+type ingestSummary struct {
+	Total         int
+	Ingested      int
+	AlreadyExists int
+	Failed        int
+	DurationMs    int64
+}
+
 // IngestCmd returns the ingest command.
 func IngestCmd() *cobra.Command {
-	var outputJSON bool
+	var (
+		outputJSON bool
+		workers    int
+		recursive  bool
+		policy     string
+		hardlink   bool
+	)
 
 	cmd := &cobra.Command{
-		Use:   "ingest <path-to-zip>",
-		Short: "Ingest a repro bundle from a ZIP file",
-		Long:  "Reads a repro bundle ZIP file and ingests it into the BugIt database.",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			zipPath := args[0]
-			dataDir, _ := cmd.Flags().GetString("data-dir")
+		Use:   "ingest <path>...",
+		Short: "Ingest repro bundles from archive files or bundle directories",
+		Long: `Reads repro bundle archives (zip, tar, tar.gz or tar.zst) and ingests
+them into the BugIt database.
 
-			// Verify file exists
-			if _, err := os.Stat(zipPath); err != nil {
-				return fmt.Errorf("file not found: %s", zipPath)
+Each argument may be an archive, an unpacked bundle directory (one with a
+manifest.json), a directory of archives or a glob pattern. Bundle
+directories are ingested in place: their files are copied into the data
+directory, or hardlinked with --link. Files are ingested in parallel.
+With more than one file, a result table and summary are printed, or one
+JSON object per file (NDJSON) with --json. The command exits non-zero if
+any file failed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, missing := expandIngestPaths(args, recursive)
+			if len(paths) == 0 && len(missing) == 1 && len(args) == 1 {
+				return fmt.Errorf("file not found: %s", args[0])
 			}
 
 			// Initialize storage
-			store, err := storage.New(dataDir)
+			store, err := openStorage(cmd)
 			if err != nil {
-				return fmt.Errorf("init storage: %w", err)
+				return err
 			}
 
 			// Initialize database
@@ -42,29 +84,289 @@ func IngestCmd() *cobra.Command {
 			}
 			defer database.Close()
 
-			// Run ingestion
 			ingester := ingest.New(database, store)
-			result, err := ingester.IngestZipFile(zipPath)
-			if err != nil {
-				return fmt.Errorf("ingest failed: %w", err)
+			if err := ingester.SetValidationPolicy(policy); err != nil {
+				return err
+			}
+
+			// A single plain file keeps the original output format
+			if len(args) == 1 && len(paths) == 1 && paths[0] == args[0] {
+				return ingestSingle(ingester, paths[0], hardlink, outputJSON)
+			}
+
+			start := time.Now()
+			results := make([]*fileIngestResult, 0, len(paths)+len(missing))
+			for _, arg := range missing {
+				results = append(results, &fileIngestResult{
+					Path:   arg,
+					Status: "failed",
+					Error: &models.APIError{
+						Code:    "FILE_NOT_FOUND",
+						Message: "no files matched",
+					},
+					index: -1,
+				})
 			}
 
-			// Output result
+			var out *json.Encoder
 			if outputJSON {
-				enc := json.NewEncoder(os.Stdout)
-				enc.SetIndent("", "  ")
-				return enc.Encode(result)
+				out = json.NewEncoder(os.Stdout)
+				for _, r := range results {
+					out.Encode(r)
+				}
 			}
 
-			fmt.Printf("Bundle ID: %s\n", result.BundleID)
-			fmt.Printf("Status: %s\n", result.Status)
-			fmt.Printf("Artifacts: %d\n", result.ArtifactCount)
+			// NDJSON lines are written as files finish; the table is
+			// printed in input order once everything is done
+			results = append(results, ingestParallel(ingester, paths, workers, hardlink, func(r *fileIngestResult) {
+				if out != nil {
+					out.Encode(r)
+				}
+			})...)
 
+			summary := summarizeIngest(results, time.Since(start))
+			if outputJSON {
+				// Keep stdout pure NDJSON
+				fmt.Fprintf(os.Stderr, "Total: %d, ingested: %d, already exists: %d, failed: %d (%dms)\n",
+					summary.Total, summary.Ingested, summary.AlreadyExists, summary.Failed, summary.DurationMs)
+			} else {
+				printIngestTable(os.Stdout, results)
+				fmt.Printf("\nTotal: %d, ingested: %d, already exists: %d, failed: %d (%dms)\n",
+					summary.Total, summary.Ingested, summary.AlreadyExists, summary.Failed, summary.DurationMs)
+			}
+
+			if summary.Failed > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d bundles failed to ingest", summary.Failed, summary.Total)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON (one object per line for multiple files)")
+	cmd.Flags().IntVarP(&workers, "workers", "w", DefaultIngestWorkers, "Number of files to ingest in parallel")
+	cmd.Flags().StringVar(&policy, "validation-policy", ingest.ValidationPolicyFlag, "What to do with bundles that fail validation: flag, quarantine or reject")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Include archives and bundle directories in subdirectories")
+	cmd.Flags().BoolVar(&hardlink, "link", false, "Hardlink files of bundle directories instead of copying them (same filesystem only)")
 
 	return cmd
 }
+
+// ingestSingle ingests one file or bundle directory with the original
+// single-file output.
+func ingestSingle(ingester *ingest.Ingester, path string, hardlink, outputJSON bool) error {
+	result, err := ingestPath(ingester, path, hardlink)
+	if err != nil {
+		return fmt.Errorf("ingest failed: %w", err)
+	}
+
+	// Output result
+	if outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	fmt.Printf("Bundle ID: %s\n", result.BundleID)
+	fmt.Printf("Status: %s\n", result.Status)
+	fmt.Printf("Artifacts: %d\n", result.ArtifactCount)
+
+	return nil
+}
+
+// expandIngestPaths resolves files, directories and glob patterns into a
+// de-duplicated list of archives and bundle directories. Arguments that
+// match nothing are returned separately so they can be reported as
+// failures.
+func expandIngestPaths(args []string, recursive bool) (paths, missing []string) {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		key := path
+		if abs, err := filepath.Abs(path); err == nil {
+			key = abs
+		}
+		if !seen[key] {
+			seen[key] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, _ = filepath.Glob(arg)
+		}
+
+		found := false
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() || ingest.IsBundleDir(match) {
+				add(match)
+				found = true
+				continue
+			}
+			for _, file := range findArchiveFiles(match, recursive) {
+				add(file)
+				found = true
+			}
+		}
+
+		if !found {
+			missing = append(missing, arg)
+		}
+	}
+
+	return paths, missing
+}
+
+// findArchiveFiles lists bundle archives in dir in lexical order. When
+// recursive, bundle directories below dir are listed too, without
+// descending into them.
+func findArchiveFiles(dir string, recursive bool) []string {
+	var files []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if !recursive {
+				return filepath.SkipDir
+			}
+			if ingest.IsBundleDir(path) {
+				files = append(files, path)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && ingest.IsArchiveName(d.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// ingestParallel ingests paths with a fixed number of workers. onResult is
+// called from a single goroutine as each file finishes. Results are
+// returned in input order.
+func ingestParallel(ingester *ingest.Ingester, paths []string, workers int, hardlink bool, onResult func(*fileIngestResult)) []*fileIngestResult {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	work := make(chan int)
+	done := make(chan *fileIngestResult)
+
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				done <- ingestFile(ingester, paths[idx], idx, hardlink)
+			}
+		}()
+	}
+
+	go func() {
+		for idx := range paths {
+			work <- idx
+		}
+		close(work)
+		wg.Wait()
+		close(done)
+	}()
+
+	results := make([]*fileIngestResult, 0, len(paths))
+	for r := range done {
+		onResult(r)
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(a, b int) bool { return results[a].index < results[b].index })
+	return results
+}
+
+// ingestFile ingests one archive or bundle directory, converting errors into
+// a failed result.
+func ingestFile(ingester *ingest.Ingester, path string, idx int, hardlink bool) *fileIngestResult {
+	start := time.Now()
+	r := &fileIngestResult{Path: path, index: idx}
+
+	result, err := ingestPath(ingester, path, hardlink)
+	r.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		r.Status = "failed"
+		if apiErr, ok := err.(*models.APIError); ok {
+			r.Error = apiErr
+		} else {
+			r.Error = &models.APIError{
+				Code:    models.ErrCodeStorageError,
+				Message: err.Error(),
+			}
+		}
+		return r
+	}
+
+	r.Status = result.Status
+	r.BundleID = result.BundleID
+	r.ArtifactCount = result.ArtifactCount
+	return r
+}
+
+// ingestPath ingests a bundle directory in place or an archive.
+func ingestPath(ingester *ingest.Ingester, path string, hardlink bool) (*ingest.IngestResult, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return ingester.IngestDir(path, hardlink)
+	}
+	return ingester.IngestZipFile(path)
+}
+
+// summarizeIngest counts results by status.
+func summarizeIngest(results []*fileIngestResult, elapsed time.Duration) ingestSummary {
+	summary := ingestSummary{
+		Total:      len(results),
+		DurationMs: elapsed.Milliseconds(),
+	}
+	for _, r := range results {
+		switch r.Status {
+		case "ingested":
+			summary.Ingested++
+		case "already_exists":
+			summary.AlreadyExists++
+		default:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// printIngestTable writes one row per file.
+func printIngestTable(out io.Writer, results []*fileIngestResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSTATUS\tBUNDLE ID\tARTIFACTS\tERROR")
+	fmt.Fprintln(w, "----\t------\t---------\t---------\t-----")
+
+	for _, r := range results {
+		bundleID, artifacts, errMsg := "-", "-", "-"
+		if r.BundleID != "" {
+			bundleID = r.BundleID
+			artifacts = fmt.Sprintf("%d", r.ArtifactCount)
+		}
+		if r.Error != nil {
+			errMsg = r.Error.Code + ": " + r.Error.Message
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Path, r.Status, bundleID, artifacts, errMsg)
+	}
+
+	w.Flush()
+}
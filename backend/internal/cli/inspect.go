@@ -9,7 +9,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/unrealsolutions/bugit/internal/db"
-	"github.com/unrealsolutions/bugit/internal/storage"
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/models"
 )
 
 // InspectCmd returns the inspect command.
@@ -23,12 +24,11 @@ func InspectCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			bundleID := args[0]
-			dataDir, _ := cmd.Flags().GetString("data-dir")
 
 			// Initialize storage
-			store, err := storage.New(dataDir)
+			store, err := openStorage(cmd)
 			if err != nil {
-				return fmt.Errorf("init storage: %w", err)
+				return err
 			}
 
 			// Initialize database
@@ -48,16 +48,39 @@ func InspectCmd() *cobra.Command {
 				return fmt.Errorf("bundle not found: %s", bundleID)
 			}
 
+			// Analyze crash dumps, storing analyses missing from older bundles
+			ingester := ingest.New(database, store)
+			var minidumps []models.Minidump
+			var dumpErrors []string
+			for i := range bundle.Artifacts {
+				a := &bundle.Artifacts[i]
+				if a.ArtifactType != "crash_dump" {
+					continue
+				}
+				m, err := ingester.AnalyzeArtifact(bundle, a)
+				if err != nil {
+					dumpErrors = append(dumpErrors, fmt.Sprintf("%s: not analyzed: %v", a.Filename, err))
+					continue
+				}
+				minidumps = append(minidumps, *m)
+			}
+
 			// Output
 			if outputJSON {
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "  ")
-				return enc.Encode(bundle)
+				return enc.Encode(struct {
+					*models.ReproBundle
+					Minidumps []models.Minidump `json:"minidumps,omitempty"`
+				}{bundle, minidumps})
 			}
 
 			// Human-readable output
 			fmt.Printf("Bundle: %s\n", bundle.BundleID)
 			fmt.Printf("  Content Hash: %s\n", bundle.ContentHash)
+			if bundle.CanonicalHash != "" {
+				fmt.Printf("  Canonical:    %s\n", bundle.CanonicalHash)
+			}
 			fmt.Printf("  Build ID:     %s\n", bundle.BuildID)
 			fmt.Printf("  Map Name:     %s\n", bundle.MapName)
 			fmt.Printf("  Platform:     %s\n", bundle.Platform)
@@ -70,6 +93,9 @@ func InspectCmd() *cobra.Command {
 			if len(bundle.Tags) > 0 {
 				fmt.Printf("  Tags:         %s\n", strings.Join(bundle.Tags, ", "))
 			}
+			if bundle.IssueID != "" && bundle.Signature != nil {
+				fmt.Printf("  Issue:        %s (signature %s from %s)\n", bundle.IssueID, bundle.Signature.Signature, bundle.Signature.Source)
+			}
 
 			// Artifacts
 			fmt.Printf("\nArtifacts (%d):\n", len(bundle.Artifacts))
@@ -77,16 +103,64 @@ func InspectCmd() *cobra.Command {
 				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 				fmt.Fprintln(w, "  ID\tFILENAME\tTYPE\tSIZE")
 				for _, a := range bundle.Artifacts {
+					artifactType := a.ArtifactType
+					if a.Role != "" && a.Role != a.ArtifactType {
+						artifactType += " (" + a.Role + ")"
+					}
+					if a.Undeclared {
+						artifactType += " (undeclared)"
+					}
 					fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n",
 						a.ArtifactID,
 						a.Filename,
-						a.ArtifactType,
+						artifactType,
 						formatBytes(a.SizeBytes),
 					)
 				}
 				w.Flush()
 			}
 
+			// Crashes
+			for _, c := range bundle.Crashes {
+				fmt.Printf("\nCrash %s (%s):\n", c.CrashID, c.Source)
+				fmt.Printf("  Type:         %s\n", c.CrashType)
+				if c.ErrorMessage != "" {
+					fmt.Printf("  Message:      %s\n", truncate(c.ErrorMessage, 200))
+				}
+				if c.CrashGUID != "" {
+					fmt.Printf("  Crash GUID:   %s\n", c.CrashGUID)
+				}
+				if c.EngineVersion != "" {
+					fmt.Printf("  Engine:       %s\n", c.EngineVersion)
+				}
+				fmt.Printf("  Modules:      %d\n", len(c.Modules))
+				fmt.Printf("  Callstack (%d frames):\n", len(c.Callstack))
+				for i, f := range c.Callstack {
+					if i == 10 {
+						fmt.Printf("    ... %d more\n", len(c.Callstack)-i)
+						break
+					}
+					frame := f.Module
+					if f.Function != "" {
+						frame += "!" + f.Function
+					}
+					if f.File != "" {
+						frame += fmt.Sprintf(" [%s:%d]", f.File, f.Line)
+					} else if f.Address != "" {
+						frame += " " + f.Address
+					}
+					fmt.Printf("    %s\n", frame)
+				}
+			}
+
+			// Minidumps
+			for _, m := range minidumps {
+				printMinidump(&m)
+			}
+			for _, msg := range dumpErrors {
+				fmt.Printf("\nMinidump %s\n", msg)
+			}
+
 			// Notes
 			if len(bundle.Notes) > 0 {
 				fmt.Printf("\nQA Notes (%d):\n", len(bundle.Notes))
@@ -105,6 +179,45 @@ func InspectCmd() *cobra.Command {
 	return cmd
 }
 
+// printMinidump prints a minidump analysis with the crashing thread's frames.
+func printMinidump(m *models.Minidump) {
+	fmt.Printf("\nMinidump %s (%s):\n", m.ArtifactID, m.Source)
+	if e := m.Exception; e != nil {
+		exception := e.Code
+		if e.Name != "" {
+			exception += " " + e.Name
+		}
+		exception += " at " + e.Address
+		if e.Module != "" {
+			exception += fmt.Sprintf(" (%s+%s)", e.Module, e.ModuleOffset)
+		}
+		fmt.Printf("  Exception:    %s\n", exception)
+		if e.AccessType != "" {
+			fmt.Printf("  Access:       %s of %s\n", e.AccessType, e.AccessAddress)
+		}
+	}
+	fmt.Printf("  System:       %s %s, %s, %d CPUs\n", m.System.OS, m.System.OSVersion, m.System.CPUArch, m.System.ProcessorCount)
+	if m.DumpTime != nil {
+		fmt.Printf("  Written:      %s\n", m.DumpTime.Format("2006-01-02 15:04:05 MST"))
+	}
+	fmt.Printf("  Modules:      %d\n", len(m.Modules))
+	fmt.Printf("  Threads:      %d\n", len(m.Threads))
+
+	for _, t := range m.Threads {
+		if !t.Crashed {
+			continue
+		}
+		fmt.Printf("  Crashed thread %d (%d frames, not unwound):\n", t.ThreadID, len(t.Frames))
+		for i, f := range t.Frames {
+			if i == 10 {
+				fmt.Printf("    ... %d more\n", len(t.Frames)-i)
+				break
+			}
+			fmt.Printf("    %s+%s  [%s]\n", f.Module, f.Offset, f.Trust)
+		}
+	}
+}
+
 func formatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
@@ -20,6 +20,10 @@ func ListCmd() *cobra.Command {
 		platform   string
 		limit      int
 		outputJSON bool
+		hasCrash   bool
+
+		exceptionCode string
+		crashModule   string
 	)
 
 	cmd := &cobra.Command{
@@ -47,6 +51,12 @@ func ListCmd() *cobra.Command {
 				BuildID:  buildID,
 				Platform: platform,
 				Limit:    limit,
+
+				ExceptionCode: exceptionCode,
+				CrashModule:   crashModule,
+			}
+			if cmd.Flags().Changed("has-crash") {
+				query.HasCrash = &hasCrash
 			}
 
 			result, err := database.ListBundles(query)
@@ -97,6 +107,9 @@ func ListCmd() *cobra.Command {
 
 	cmd.Flags().StringVar(&buildID, "build-id", "", "Filter by build ID")
 	cmd.Flags().StringVar(&platform, "platform", "", "Filter by platform")
+	cmd.Flags().BoolVar(&hasCrash, "has-crash", false, "Only bundles with a parsed crash (--has-crash=false for those without)")
+	cmd.Flags().StringVar(&exceptionCode, "exception-code", "", "Only bundles with a minidump exception code, e.g. 0xC0000005")
+	cmd.Flags().StringVar(&crashModule, "crash-module", "", "Only bundles whose minidump exception is in this module")
 	cmd.Flags().IntVar(&limit, "limit", 20, "Max results")
 	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
 
@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// QuarantineCmd returns the quarantine command.
+func QuarantineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quarantine",
+		Short: "Manage rejected uploads kept in quarantine",
+		Long: `Uploads rejected by bugit serve because the bundle itself was bad (invalid
+manifest, failed validation, broken or unsafe archive) are kept in the
+quarantine/ directory together with the error, request headers and time.
+These commands list, inspect, download, re-ingest or delete them.`,
+	}
+
+	cmd.AddCommand(
+		quarantineListCmd(),
+		quarantineInspectCmd(),
+		quarantineDownloadCmd(),
+		quarantineReingestCmd(),
+		quarantineDeleteCmd(),
+	)
+
+	return cmd
+}
+
+func quarantineListCmd() *cobra.Command {
+	var outputJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List quarantined uploads",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataDir, _ := cmd.Flags().GetString("data-dir")
+
+			store, err := storage.New(dataDir)
+			if err != nil {
+				return fmt.Errorf("init storage: %w", err)
+			}
+
+			entries, err := store.ListQuarantine()
+			if err != nil {
+				return fmt.Errorf("list quarantine: %w", err)
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+
+			if len(entries) == 0 {
+				fmt.Println("No quarantined uploads.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "QUARANTINE ID\tKIND\tSIZE\tATTEMPTS\tUPDATED\tERROR")
+			fmt.Fprintln(w, "-------------\t----\t----\t--------\t-------\t-----")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					e.QuarantineID,
+					e.Kind,
+					formatBytes(e.SizeBytes),
+					e.Attempts,
+					e.UpdatedAt.Format("2006-01-02 15:04"),
+					e.Error.Code+": "+e.Error.Message,
+				)
+			}
+			w.Flush()
+
+			fmt.Printf("\nTotal: %d uploads\n", len(entries))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func quarantineInspectCmd() *cobra.Command {
+	var outputJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "inspect <quarantine_id>",
+		Short: "Show the error, headers and files of a quarantined upload",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataDir, _ := cmd.Flags().GetString("data-dir")
+
+			store, err := storage.New(dataDir)
+			if err != nil {
+				return fmt.Errorf("init storage: %w", err)
+			}
+
+			entry, err := loadQuarantineEntry(store, args[0])
+			if err != nil {
+				return err
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entry)
+			}
+
+			fmt.Printf("Quarantined upload: %s\n", entry.QuarantineID)
+			fmt.Printf("  Kind:         %s\n", entry.Kind)
+			if entry.Format != "" {
+				fmt.Printf("  Format:       %s\n", entry.Format)
+			}
+			fmt.Printf("  Content Hash: %s\n", entry.ContentHash)
+			fmt.Printf("  Size:         %s\n", formatBytes(entry.SizeBytes))
+			fmt.Printf("  Attempts:     %d\n", entry.Attempts)
+			fmt.Printf("  Created:      %s\n", entry.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("  Updated:      %s\n", entry.UpdatedAt.Format("2006-01-02 15:04:05"))
+
+			fmt.Printf("\nError:\n  %s: %s\n", entry.Error.Code, entry.Error.Message)
+			for key, value := range entry.Error.Details {
+				if key == "quarantine_id" {
+					continue
+				}
+				detail, _ := json.Marshal(value)
+				fmt.Printf("  %s: %s\n", key, detail)
+			}
+
+			if len(entry.Headers) > 0 {
+				fmt.Printf("\nRequest Headers:\n")
+				names := make([]string, 0, len(entry.Headers))
+				for name := range entry.Headers {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					fmt.Printf("  %s: %s\n", name, entry.Headers[name])
+				}
+			}
+
+			fmt.Printf("\nFiles (%d):\n", len(entry.Files))
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, f := range entry.Files {
+				fmt.Fprintf(w, "  %s\t%s\n", f.Path, formatBytes(f.SizeBytes))
+			}
+			w.Flush()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func quarantineDownloadCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "download <quarantine_id>",
+		Short: "Save a quarantined upload as it was received",
+		Long: `Writes a quarantined upload to a file. Archive uploads are written as
+received; uploads of individual files are packed into a ZIP.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataDir, _ := cmd.Flags().GetString("data-dir")
+
+			store, err := storage.New(dataDir)
+			if err != nil {
+				return fmt.Errorf("init storage: %w", err)
+			}
+
+			entry, err := loadQuarantineEntry(store, args[0])
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				output = ingest.QuarantineFileName(entry)
+			}
+
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("create output: %w", err)
+			}
+			if err := store.WriteQuarantineArchive(entry, f); err != nil {
+				f.Close()
+				os.Remove(output)
+				return fmt.Errorf("write %s: %w", output, err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("write %s: %w", output, err)
+			}
+
+			fmt.Printf("Saved %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default <quarantine_id> plus the archive extension)")
+
+	return cmd
+}
+
+func quarantineReingestCmd() *cobra.Command {
+	var (
+		outputJSON bool
+		policy     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reingest <quarantine_id>",
+		Short: "Ingest a quarantined upload again",
+		Long: `Runs a quarantined upload through ingestion again, for example after a
+fix or with a more lenient --validation-policy. On success it leaves
+quarantine; on failure it stays there with the new error.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			ingester := ingest.New(database, store)
+			if err := ingester.SetValidationPolicy(policy); err != nil {
+				return err
+			}
+
+			result, err := ingester.ReingestQuarantined(args[0])
+			if err != nil {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("reingest failed: %w", err)
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(result)
+			}
+
+			fmt.Printf("Bundle ID: %s\n", result.BundleID)
+			fmt.Printf("Status: %s\n", result.Status)
+			fmt.Printf("Artifacts: %d\n", result.ArtifactCount)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	cmd.Flags().StringVar(&policy, "validation-policy", ingest.ValidationPolicyFlag, "What to do with bundles that fail validation: flag, quarantine or reject")
+
+	return cmd
+}
+
+func quarantineDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <quarantine_id>...",
+		Short: "Delete quarantined uploads",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataDir, _ := cmd.Flags().GetString("data-dir")
+
+			store, err := storage.New(dataDir)
+			if err != nil {
+				return fmt.Errorf("init storage: %w", err)
+			}
+
+			for _, id := range args {
+				if _, err := loadQuarantineEntry(store, id); err != nil {
+					return err
+				}
+				if err := store.RemoveQuarantine(id); err != nil {
+					return fmt.Errorf("delete %s: %w", id, err)
+				}
+				fmt.Printf("Deleted %s\n", id)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// loadQuarantineEntry loads an entry or returns a not-found error.
+func loadQuarantineEntry(store *storage.Storage, id string) (*models.QuarantineEntry, error) {
+	entry, err := store.LoadQuarantine(id)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", id, err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("quarantined upload not found: %s", id)
+	}
+	return entry, nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// ReindexCmd returns the reindex command.
+func ReindexCmd() *cobra.Command {
+	var (
+		annotationsFile string
+		outputJSON      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the database from the bundle directories",
+		Long: `Recreates the database rows of every bundle directory in storage that
+has none, for when bugit.db was lost or corrupted. Move the damaged
+database aside first; a new one is created.
+
+Each manifest is parsed and validated and each file hashed as at ingest,
+and every bundle keeps the ID its directory is named after, so existing
+links keep working. Bundles already in the database are left alone, so
+reindex can be run again after fixing a directory that failed.
+
+Tags and notes are not stored with the bundles. Restore them from a
+'bugit annotations export' file with --annotations.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var annotations *models.Annotations
+			if annotationsFile != "" {
+				var err error
+				if annotations, err = readAnnotations(annotationsFile); err != nil {
+					return err
+				}
+			}
+
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			report, err := ingest.New(database, store).Reindex()
+			if err != nil {
+				return err
+			}
+
+			var imported *models.AnnotationsImport
+			if annotations != nil {
+				if imported, err = database.ImportAnnotations(annotations); err != nil {
+					return fmt.Errorf("import annotations: %w", err)
+				}
+			}
+
+			if outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(struct {
+					*ingest.ReindexReport
+					Annotations *models.AnnotationsImport `json:"annotations,omitempty"`
+				}{report, imported}); err != nil {
+					return err
+				}
+			} else {
+				printReindexReport(report)
+				if imported != nil {
+					printAnnotationsImport(imported)
+				}
+			}
+
+			if len(report.Failed) > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d bundle directories could not be indexed", len(report.Failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&annotationsFile, "annotations", "", "Import tags and notes from this annotations export afterwards")
+	cmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func printReindexReport(report *ingest.ReindexReport) {
+	for _, bundleID := range report.Indexed {
+		fmt.Printf("Indexed %s\n", bundleID)
+	}
+	for _, dir := range report.Skipped {
+		fmt.Printf("Skipped %s: ingest not committed\n", dir)
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("FAILED %s: %s\n", f.Path, f.Error)
+	}
+
+	fmt.Printf("Indexed %d bundles, %d already in the database, %d skipped, %d failed.\n",
+		len(report.Indexed), report.Existing, len(report.Skipped), len(report.Failed))
+}
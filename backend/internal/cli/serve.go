@@ -14,12 +14,41 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/unrealsolutions/bugit/internal/api"
 	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/fsck"
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/jobs"
+	"github.com/unrealsolutions/bugit/internal/retention"
 	"github.com/unrealsolutions/bugit/internal/storage"
+	"github.com/unrealsolutions/bugit/internal/watch"
 )
 
 // ServeCmd returns the serve command.
 func ServeCmd() *cobra.Command {
-	var port int
+	var (
+		port         int
+		uploadTTL    time.Duration
+		maxPartMB    int64
+		maxUploadMB  int64
+		workers      int
+		queueSize    int
+		watchDir     string
+		watchPoll    time.Duration
+		watchSettle  time.Duration
+		extract      = ingest.DefaultExtractLimits()
+		extractMB    int64
+		policy       string
+		quarantine   = storage.QuarantineLimits{MaxAge: storage.DefaultQuarantineMaxAge}
+		quarantineMB int64
+		keep         retention.Policy
+		keepTotalMB  int64
+		gcInterval   time.Duration
+		gcDryRun     bool
+		reserveMB    int64
+		buildQuota   map[string]int64
+		platQuota    map[string]int64
+		fsckInterval time.Duration
+		fsckOpts     fsck.Options
+	)
 
 	cmd := &cobra.Command{
 		Use:   "serve",
@@ -33,9 +62,17 @@ func ServeCmd() *cobra.Command {
 			setupLogging(logLevel)
 
 			// Initialize storage
-			store, err := storage.New(dataDir)
+			store, err := openStorage(cmd)
 			if err != nil {
-				return fmt.Errorf("init storage: %w", err)
+				return err
+			}
+
+			// Keep rejected uploads within budget
+			quarantine.MaxBytes = quarantineMB << 20
+			store.SetQuarantineLimits(quarantine)
+			store.SetDiskReserve(reserveMB << 20)
+			if removed, err := store.PruneQuarantine(); err == nil && removed > 0 {
+				slog.Info("pruned quarantined uploads", "count", removed)
 			}
 
 			// Cleanup old temp directories on startup
@@ -53,6 +90,127 @@ func ServeCmd() *cobra.Command {
 			// Create server
 			version := cmd.Root().Version
 			server := api.NewServer(database, store, version)
+			server.Ingester().SetUploadSessionTTL(uploadTTL)
+			server.Ingester().SetUploadLimits(ingest.UploadLimits{
+				MaxPartBytes:   maxPartMB << 20,
+				MaxUploadBytes: maxUploadMB << 20,
+			})
+			extract.MaxBytes = extractMB << 20
+			server.Ingester().SetExtractLimits(extract)
+			if err := server.Ingester().SetValidationPolicy(policy); err != nil {
+				return err
+			}
+			server.Ingester().SetQuotas(ingest.Quotas{
+				Build:    quotaBytes(buildQuota),
+				Platform: quotaBytes(platQuota),
+			})
+
+			// Reconcile anything left behind by an interrupted ingest
+			report, err := server.Ingester().Recover()
+			if err != nil {
+				return fmt.Errorf("recover: %w", err)
+			}
+			if len(report.RemovedDirs) > 0 || len(report.ClearedMarkers) > 0 || len(report.FixedStoragePaths) > 0 {
+				slog.Info("recovered interrupted ingests",
+					"removed_dirs", len(report.RemovedDirs),
+					"cleared_markers", len(report.ClearedMarkers),
+					"fixed_storage_paths", len(report.FixedStoragePaths),
+				)
+			}
+			if len(report.BackfilledHashes) > 0 {
+				slog.Info("backfilled canonical hashes", "bundles", len(report.BackfilledHashes))
+			}
+			if len(report.Fingerprinted) > 0 {
+				slog.Info("fingerprinted existing bundles", "bundles", len(report.Fingerprinted))
+			}
+
+			// Start the async ingest workers
+			queue := jobs.New(database, store, server.Ingester(), workers, queueSize)
+			if err := queue.Start(); err != nil {
+				return fmt.Errorf("start ingest queue: %w", err)
+			}
+			server.SetQueue(queue)
+
+			// Let running jobs finish; queued jobs resume on next start
+			defer queue.Stop()
+
+			// Optionally ingest bundles dropped into a directory
+			watchCtx, stopWatch := context.WithCancel(context.Background())
+			defer stopWatch()
+			watchDone := make(chan struct{})
+			if watchDir != "" {
+				watcher := watch.New(server.Ingester(), watch.Config{
+					Dir:      watchDir,
+					Interval: watchPoll,
+					Settle:   watchSettle,
+				})
+				go func() {
+					defer close(watchDone)
+					if err := watcher.Run(watchCtx); err != nil {
+						slog.Error("watcher stopped", "error", err)
+					}
+				}()
+			} else {
+				close(watchDone)
+			}
+
+			// Periodically expire abandoned uploads and resumable sessions
+			janitorDone := make(chan struct{})
+			defer close(janitorDone)
+			go func() {
+				ticker := time.NewTicker(10 * time.Minute)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						if removed, err := store.CleanupOldTempDirs(time.Hour); err == nil && removed > 0 {
+							slog.Info("cleaned up old temp directories", "count", removed)
+						}
+						if removed, err := store.PruneQuarantine(); err == nil && removed > 0 {
+							slog.Info("pruned quarantined uploads", "count", removed)
+						}
+					case <-janitorDone:
+						return
+					}
+				}
+			}()
+
+			// Periodically apply retention rules. A run in progress finishes
+			// before the database is closed
+			gcCtx, stopGC := context.WithCancel(context.Background())
+			gcDone := make(chan struct{})
+			defer func() {
+				stopGC()
+				<-gcDone
+			}()
+			keep.MaxTotalBytes = keepTotalMB << 20
+			if keep.Enabled() && gcInterval > 0 {
+				collector := retention.New(database, store, keep)
+				go func() {
+					defer close(gcDone)
+					collector.Run(gcCtx, gcInterval, gcDryRun)
+				}()
+			} else {
+				close(gcDone)
+			}
+
+			// Optionally check storage integrity in the background, letting
+			// a check in progress finish before the database is closed
+			fsckCtx, stopFsck := context.WithCancel(context.Background())
+			fsckDone := make(chan struct{})
+			defer func() {
+				stopFsck()
+				<-fsckDone
+			}()
+			if fsckInterval > 0 {
+				checker := fsck.New(database, store)
+				go func() {
+					defer close(fsckDone)
+					checker.Run(fsckCtx, fsckInterval, fsckOpts)
+				}()
+			} else {
+				close(fsckDone)
+			}
 
 			// Setup HTTP server
 			httpServer := &http.Server{
@@ -68,7 +226,7 @@ func ServeCmd() *cobra.Command {
 			signal.Notify(done, os.Interrupt, syscall.SIGTERM)
 
 			go func() {
-				slog.Info("starting server", "port", port, "data_dir", dataDir)
+				slog.Info("starting server", "port", port, "data_dir", dataDir, "storage_backend", store.Backend().Name())
 				if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 					slog.Error("server error", "error", err)
 					os.Exit(1)
@@ -85,12 +243,39 @@ func ServeCmd() *cobra.Command {
 				return fmt.Errorf("shutdown: %w", err)
 			}
 
+			stopWatch()
+			<-watchDone
+
 			slog.Info("server stopped")
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&port, "port", 8080, "HTTP port")
+	cmd.Flags().Int64Var(&maxPartMB, "max-part-mb", ingest.DefaultMaxPartBytes>>20, "Maximum size of a single multipart file in MB (0 = unlimited)")
+	cmd.Flags().Int64Var(&maxUploadMB, "max-upload-mb", ingest.DefaultMaxUploadBytes>>20, "Maximum total multipart upload size in MB (0 = unlimited)")
+	cmd.Flags().IntVar(&extract.MaxEntries, "max-extract-entries", ingest.DefaultMaxEntries, "Maximum number of entries in a bundle archive (0 = unlimited)")
+	cmd.Flags().Int64Var(&extractMB, "max-extract-mb", ingest.DefaultMaxExtractBytes>>20, "Maximum uncompressed size of a bundle archive in MB (0 = unlimited)")
+	cmd.Flags().Float64Var(&extract.MaxRatio, "max-extract-ratio", ingest.DefaultMaxRatio, "Maximum uncompressed to compressed size ratio (0 = unlimited)")
+	cmd.Flags().IntVar(&extract.MaxDepth, "max-extract-depth", ingest.DefaultMaxDepth, "Maximum directory depth of archive entries (0 = unlimited)")
+	cmd.Flags().StringVar(&policy, "validation-policy", ingest.ValidationPolicyFlag, "What to do with bundles that fail validation: flag, quarantine or reject")
+	cmd.Flags().Int64Var(&quarantineMB, "quarantine-max-mb", storage.DefaultQuarantineMaxBytes>>20, "Maximum total size of quarantined uploads in MB (0 = unlimited)")
+	cmd.Flags().DurationVar(&quarantine.MaxAge, "quarantine-max-age", storage.DefaultQuarantineMaxAge, "How long a quarantined upload is kept (0 = forever)")
+	cmd.Flags().IntVar(&workers, "ingest-workers", jobs.DefaultWorkers, "Number of async ingest workers")
+	cmd.Flags().IntVar(&queueSize, "ingest-queue-size", jobs.DefaultQueueSize, "Maximum number of queued async ingest jobs")
+	cmd.Flags().StringVar(&watchDir, "watch-dir", "", "Also ingest bundle archives dropped into this directory")
+	cmd.Flags().DurationVar(&watchPoll, "watch-interval", watch.DefaultInterval, "How often to poll --watch-dir")
+	cmd.Flags().DurationVar(&watchSettle, "watch-settle", watch.DefaultSettle, "How long a dropped file must be unchanged before it is ingested")
+	cmd.Flags().DurationVar(&uploadTTL, "upload-session-ttl", ingest.DefaultUploadSessionTTL, "How long an idle resumable upload is kept")
+	addRetentionFlags(cmd, "retention-", &keep, &keepTotalMB)
+	cmd.Flags().DurationVar(&gcInterval, "gc-interval", retention.DefaultInterval, "How often to apply the retention rules (0 = never)")
+	cmd.Flags().BoolVar(&gcDryRun, "gc-dry-run", false, "Only log what the retention rules would delete")
+	cmd.Flags().DurationVar(&fsckInterval, "fsck-interval", 0, "How often to check stored files against the database (0 = never)")
+	cmd.Flags().BoolVar(&fsckOpts.Checksums, "fsck-checksums", false, "Hash every file in periodic checks instead of only comparing sizes")
+	cmd.Flags().BoolVar(&fsckOpts.Repair, "fsck-repair", false, "Apply safe fixes in periodic checks")
+	cmd.Flags().Int64Var(&reserveMB, "disk-reserve-mb", storage.DefaultDiskReserve>>20, "Free space in MB uploads must leave on the data volume")
+	cmd.Flags().StringToInt64Var(&buildQuota, "build-quota-mb", nil, "Maximum size in MB of the bundles of a build ID, e.g. 1.2.3=5000,*=2000 (* = any other build)")
+	cmd.Flags().StringToInt64Var(&platQuota, "platform-quota-mb", nil, "Maximum size in MB of the bundles of a platform, e.g. Win64=50000,*=20000 (* = any other platform)")
 
 	return cmd
 }
@@ -113,3 +298,12 @@ func setupLogging(level string) {
 	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})
 	slog.SetDefault(slog.New(handler))
 }
+
+// quotaBytes converts quotas given in MB to bytes.
+func quotaBytes(quotasMB map[string]int64) map[string]int64 {
+	quotas := make(map[string]int64, len(quotasMB))
+	for key, mb := range quotasMB {
+		quotas[key] = mb << 20
+	}
+	return quotas
+}
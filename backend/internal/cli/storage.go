@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// AddStorageFlags registers the bundle storage backend flags on root, so
+// every command that reads or commits bundles sees the same backend.
+func AddStorageFlags(root *cobra.Command) {
+	flags := root.PersistentFlags()
+	flags.String("storage-backend", "local", "Where committed bundles are stored: local or s3")
+	flags.String("s3-endpoint", "", "S3-compatible endpoint URL, e.g. http://minio:9000")
+	flags.String("s3-region", "us-east-1", "S3 signing region")
+	flags.String("s3-bucket", "", "S3 bucket for bundles")
+	flags.String("s3-prefix", "", "Key prefix for bundles inside the bucket")
+	flags.Bool("s3-path-style", true, "Address the bucket in the URL path (required by MinIO)")
+	flags.String("s3-access-key", "", "S3 access key (default $AWS_ACCESS_KEY_ID)")
+	flags.String("s3-secret-key", "", "S3 secret key (default $AWS_SECRET_ACCESS_KEY)")
+	flags.Int64("s3-part-size-mb", storage.DefaultS3PartSize>>20, "Files larger than this are uploaded to S3 in parts of this size")
+}
+
+// openStorage opens the data directory and attaches the bundle backend
+// selected by the storage flags.
+func openStorage(cmd *cobra.Command) (*storage.Storage, error) {
+	flags := cmd.Flags()
+	dataDir, _ := flags.GetString("data-dir")
+
+	store, err := storage.New(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("init storage: %w", err)
+	}
+
+	backend, _ := flags.GetString("storage-backend")
+	switch backend {
+	case "", "local":
+		return store, nil
+	case "s3":
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %q (want local or s3)", backend)
+	}
+
+	cfg := storage.S3Config{}
+	cfg.Endpoint, _ = flags.GetString("s3-endpoint")
+	cfg.Region, _ = flags.GetString("s3-region")
+	cfg.Bucket, _ = flags.GetString("s3-bucket")
+	cfg.Prefix, _ = flags.GetString("s3-prefix")
+	cfg.PathStyle, _ = flags.GetBool("s3-path-style")
+	cfg.AccessKey, _ = flags.GetString("s3-access-key")
+	cfg.SecretKey, _ = flags.GetString("s3-secret-key")
+	partSizeMB, _ := flags.GetInt64("s3-part-size-mb")
+	cfg.PartSize = partSizeMB << 20
+
+	// Keep secrets out of the process list where possible
+	if cfg.AccessKey == "" {
+		cfg.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if cfg.SecretKey == "" {
+		cfg.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	s3, err := storage.NewS3Backend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store.SetBackend(s3)
+	return store, nil
+}
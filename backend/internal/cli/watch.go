@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/watch"
+)
+
+// WatchCmd returns the watch command.
+func WatchCmd() *cobra.Command {
+	var (
+		interval time.Duration
+		settle   time.Duration
+		policy   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch <dir>",
+		Short: "Ingest repro bundles dropped into a directory",
+		Long: `Polls a drop directory and ingests each archive (zip, tar, tar.gz or
+tar.zst) once it has stopped changing. Ingested files are moved to
+processed/ and rejected files to failed/, each with a <name>.json sidecar
+holding the result or error. Files that fail on the server side, such as
+on a full disk, stay in .processing/ and are retried with backoff.
+
+Run only one watcher per directory, either this command or bugit serve
+--watch-dir.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+			logLevel, _ := cmd.Flags().GetString("log-level")
+
+			// Setup logging
+			setupLogging(logLevel)
+
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("directory not found: %s", dir)
+			}
+
+			// Initialize storage
+			store, err := openStorage(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Initialize database
+			database, err := db.Open(store.DBPath())
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			ingester := ingest.New(database, store)
+			if err := ingester.SetValidationPolicy(policy); err != nil {
+				return err
+			}
+
+			watcher := watch.New(ingester, watch.Config{
+				Dir:      dir,
+				Interval: interval,
+				Settle:   settle,
+			})
+			return watcher.Run(ctx)
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", watch.DefaultInterval, "How often to poll the directory")
+	cmd.Flags().StringVar(&policy, "validation-policy", ingest.ValidationPolicyFlag, "What to do with bundles that fail validation: flag, quarantine or reject")
+	cmd.Flags().DurationVar(&settle, "settle", watch.DefaultSettle, "How long a file must be unchanged before it is ingested")
+
+	return cmd
+}
@@ -0,0 +1,250 @@
+// Package crash extracts structured crash records from the crash files
+// Unreal Engine writes next to a repro bundle.
+//
+// CrashContext.runtime-xml is written by FGenericCrashContext when the
+// engine crashes, asserts, ensures or hangs. Only the RuntimeProperties
+// section is read; the platform, engine and game data sections are ignored.
+package crash
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// ContextFileName is the name Unreal gives the crash context file.
+const ContextFileName = "CrashContext.runtime-xml"
+
+// MaxContextSize is the largest crash context file that is parsed.
+const MaxContextSize = 16 << 20 // 16 MB
+
+// IsContextFile reports whether a bundle-relative path names a crash
+// context file.
+func IsContextFile(name string) bool {
+	return strings.EqualFold(path.Base(strings.ReplaceAll(name, "\\", "/")), ContextFileName)
+}
+
+// runtimeXML is the subset of FGenericCrashContext that is kept.
+type runtimeXML struct {
+	Runtime struct {
+		CrashGUID          string `xml:"CrashGUID"`
+		ExecutionGUID      string `xml:"ExecutionGuid"`
+		CrashType          string `xml:"CrashType"`
+		IsEnsure           string `xml:"IsEnsure"`
+		IsAssert           string `xml:"IsAssert"`
+		IsStall            string `xml:"IsStall"`
+		ErrorMessage       string `xml:"ErrorMessage"`
+		EngineVersion      string `xml:"EngineVersion"`
+		BuildVersion       string `xml:"BuildVersion"`
+		BuildConfiguration string `xml:"BuildConfiguration"`
+		PlatformName       string `xml:"PlatformName"`
+		PlatformFullName   string `xml:"PlatformFullName"`
+		GameName           string `xml:"GameName"`
+		CallStack          string `xml:"CallStack"`
+		PCallStack         string `xml:"PCallStack"`
+		Modules            string `xml:"Modules"`
+	} `xml:"RuntimeProperties"`
+}
+
+// ParseContext parses a CrashContext.runtime-xml document. UTF-8 and
+// UTF-16 (with a byte order mark) are accepted.
+func ParseContext(data []byte) (*models.Crash, error) {
+	data = toUTF8(data)
+
+	var doc runtimeXML
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// The document is UTF-8 by now, whatever its declaration says
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse crash context: %w", err)
+	}
+
+	rt := doc.Runtime
+	if rt.CrashGUID == "" && rt.ErrorMessage == "" && rt.CallStack == "" && rt.PCallStack == "" {
+		return nil, fmt.Errorf("parse crash context: no RuntimeProperties")
+	}
+
+	c := &models.Crash{
+		CrashType:     crashType(rt.CrashType, rt.IsEnsure, rt.IsAssert, rt.IsStall),
+		ErrorMessage:  strings.TrimSpace(rt.ErrorMessage),
+		CrashGUID:     strings.TrimSpace(rt.CrashGUID),
+		ExecutionGUID: strings.TrimSpace(rt.ExecutionGUID),
+		EngineVersion: strings.TrimSpace(rt.EngineVersion),
+		BuildVersion:  strings.TrimSpace(rt.BuildVersion),
+		BuildConfig:   strings.TrimSpace(rt.BuildConfiguration),
+		Platform:      strings.TrimSpace(rt.PlatformName),
+		GameName:      strings.TrimSpace(rt.GameName),
+	}
+	if c.Platform == "" {
+		c.Platform = strings.TrimSpace(rt.PlatformFullName)
+	}
+
+	c.Callstack = parseCallstack(rt.CallStack)
+	if len(c.Callstack) == 0 {
+		c.Callstack = parsePortableCallstack(rt.PCallStack)
+	}
+	c.Modules = parseModules(rt.Modules, c.Callstack)
+
+	return c, nil
+}
+
+// crashType returns the CrashType property, or derives it from the older
+// Is* flags.
+func crashType(explicit, isEnsure, isAssert, isStall string) string {
+	if t := strings.TrimSpace(explicit); t != "" {
+		return t
+	}
+	switch {
+	case isTrue(isEnsure):
+		return "Ensure"
+	case isTrue(isAssert):
+		return "Assert"
+	case isTrue(isStall):
+		return "Hang"
+	default:
+		return "Crash"
+	}
+}
+
+func isTrue(s string) bool {
+	v, _ := strconv.ParseBool(strings.TrimSpace(s))
+	return v
+}
+
+// parseCallstack parses the symbolicated callstack, one frame per line:
+//
+//	UnrealEditor_Core!FDebug::CheckVerifyFailedImpl() [D:\Engine\Source\AssertionMacros.cpp:466]
+//	0x00007ffb1b2c3d4e UnrealEditor-Engine.dll!UWorld::Tick() []
+//	kernel32
+func parseCallstack(text string) []models.CrashFrame {
+	frames := make([]models.CrashFrame, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var f models.CrashFrame
+		if strings.HasPrefix(line, "0x") {
+			addr, rest, _ := strings.Cut(line, " ")
+			f.Address = addr
+			line = strings.TrimSpace(rest)
+		}
+
+		// Trailing [file:line]
+		if open := strings.LastIndex(line, " ["); open >= 0 && strings.HasSuffix(line, "]") {
+			f.File, f.Line = splitLocation(line[open+2 : len(line)-1])
+			line = strings.TrimSpace(line[:open])
+		} else if strings.HasSuffix(line, "[]") {
+			line = strings.TrimSpace(strings.TrimSuffix(line, "[]"))
+		}
+
+		if module, function, ok := strings.Cut(line, "!"); ok {
+			f.Module = module
+			f.Function = function
+		} else {
+			f.Module = line
+		}
+		if f.Module == "" && f.Function == "" && f.Address == "" {
+			continue
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// splitLocation splits "C:\path\File.cpp:42" into file and line. The
+// line is optional and drive letters are not mistaken for one.
+func splitLocation(loc string) (string, int) {
+	loc = strings.TrimSpace(loc)
+	if i := strings.LastIndex(loc, ":"); i > 1 {
+		if line, err := strconv.Atoi(loc[i+1:]); err == nil {
+			return loc[:i], line
+		}
+	}
+	return loc, 0
+}
+
+// parsePortableCallstack parses the unsymbolicated callstack, used when the
+// crash reporter could not symbolicate:
+//
+//	UnrealEditor-Core 0x00007ffb1b000000 + 2c3d4e
+func parsePortableCallstack(text string) []models.CrashFrame {
+	frames := make([]models.CrashFrame, 0)
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) >= 4 && fields[2] == "+":
+			frames = append(frames, models.CrashFrame{
+				Module:  fields[0],
+				Address: fields[1] + "+" + fields[3],
+			})
+		default:
+			frames = append(frames, models.CrashFrame{Module: fields[0]})
+		}
+	}
+	return frames
+}
+
+// parseModules returns the module names from the Modules property, one
+// path per line, or failing that the modules seen in the callstack, in
+// order of first appearance.
+func parseModules(text string, frames []models.CrashFrame) []string {
+	modules := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			modules = append(modules, name)
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			add(path.Base(strings.ReplaceAll(line, "\\", "/")))
+		}
+	}
+	if len(modules) > 0 {
+		return modules
+	}
+
+	for _, f := range frames {
+		add(f.Module)
+	}
+	return modules
+}
+
+// toUTF8 converts UTF-16 text with a byte order mark to UTF-8 and strips a
+// UTF-8 byte order mark. Anything else is returned unchanged.
+func toUTF8(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return data[3:]
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE:
+		return decodeUTF16(data[2:], false)
+	case len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF:
+		return decodeUTF16(data[2:], true)
+	}
+	return data
+}
+
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
@@ -0,0 +1,600 @@
+package crash
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// Minidumps are the MINIDUMP format written by MiniDumpWriteDump on Windows
+// and by Breakpad/Crashpad elsewhere. Only the stream directory and the
+// thread, module, exception and system info streams are read; memory is
+// read only for thread stacks.
+
+const minidumpSignature = 0x504d444d // "MDMP"
+
+// Stream types
+const (
+	threadListStream = 3
+	moduleListStream = 4
+	exceptionStream  = 6
+	systemInfoStream = 7
+)
+
+// Limits on declared counts and lengths, so a corrupt dump cannot make the
+// reader allocate without bound.
+const (
+	maxMinidumpStreams   = 4096
+	maxMinidumpThreads   = 65536
+	maxMinidumpModules   = 65536
+	maxMinidumpStringLen = 32 << 10
+)
+
+// Sizes of the fixed records
+const (
+	headerSize    = 32
+	directorySize = 12
+	threadSize    = 48
+	moduleSize    = 108
+)
+
+// Frames kept per thread by stack scanning, and the most stack read per thread.
+const (
+	maxCrashedFrames = 64
+	maxThreadFrames  = 16
+	maxStackScan     = 1 << 20
+)
+
+// IsMinidump reports whether data starts with the minidump signature.
+func IsMinidump(data []byte) bool {
+	return len(data) >= 4 && binary.LittleEndian.Uint32(data) == minidumpSignature
+}
+
+// ParseMinidumpFile parses the minidump at path.
+func ParseMinidumpFile(path string) (*models.Minidump, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ParseMinidump(f, info.Size())
+}
+
+// ParseMinidump parses a minidump of the given size. It reads only the
+// records it needs, so full-memory dumps are not loaded into memory.
+func ParseMinidump(r io.ReaderAt, size int64) (*models.Minidump, error) {
+	d := &dumpReader{r: r, size: size}
+
+	header, err := d.read(0, headerSize)
+	if err != nil {
+		return nil, fmt.Errorf("read minidump header: %w", err)
+	}
+	if !IsMinidump(header) {
+		return nil, errors.New("not a minidump: bad signature")
+	}
+	streamCount := le32(header, 8)
+	dirRVA := le32(header, 12)
+	if streamCount > maxMinidumpStreams {
+		return nil, fmt.Errorf("minidump declares %d streams", streamCount)
+	}
+
+	dir, err := d.read(int64(dirRVA), int(streamCount)*directorySize)
+	if err != nil {
+		return nil, fmt.Errorf("read stream directory: %w", err)
+	}
+	streams := make(map[uint32]location)
+	for i := 0; i < int(streamCount); i++ {
+		entry := dir[i*directorySize:]
+		streamType := le32(entry, 0)
+		if _, ok := streams[streamType]; !ok {
+			streams[streamType] = location{size: le32(entry, 4), rva: le32(entry, 8)}
+		}
+	}
+
+	m := &models.Minidump{
+		Modules: make([]models.MinidumpModule, 0),
+		Threads: make([]models.MinidumpThread, 0),
+	}
+	if ts := le32(header, 20); ts != 0 {
+		t := time.Unix(int64(ts), 0).UTC()
+		m.DumpTime = &t
+	}
+
+	// System info first: it decides pointer size and context layout
+	arch := archUnknown
+	if loc, ok := streams[systemInfoStream]; ok {
+		arch, err = d.readSystemInfo(loc, &m.System)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		m.System.CPUArch = "unknown"
+		m.System.OS = "unknown"
+	}
+
+	var modules []moduleRange
+	if loc, ok := streams[moduleListStream]; ok {
+		m.Modules, modules, err = d.readModules(loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var exceptionContext location
+	if loc, ok := streams[exceptionStream]; ok {
+		var threadID uint32
+		m.Exception, threadID, exceptionContext, err = d.readException(loc, m.System.OS, modules)
+		if err != nil {
+			return nil, err
+		}
+		m.CrashingThreadID = &threadID
+	}
+
+	if loc, ok := streams[threadListStream]; ok {
+		m.Threads, err = d.readThreads(loc, arch, modules, m.CrashingThreadID, exceptionContext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// location is a MINIDUMP_LOCATION_DESCRIPTOR.
+type location struct {
+	size uint32
+	rva  uint32
+}
+
+// dumpReader reads bounds-checked records from a minidump.
+type dumpReader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func (d *dumpReader) read(offset int64, n int) ([]byte, error) {
+	if n < 0 || offset < 0 || offset+int64(n) > d.size {
+		return nil, fmt.Errorf("record at %d (%d bytes) is outside the file", offset, n)
+	}
+	buf := make([]byte, n)
+	if _, err := d.r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readList reads a stream that is a uint32 count followed by fixed-size
+// records, returning the records.
+func (d *dumpReader) readList(loc location, recordSize int, max uint32, name string) ([]byte, int, error) {
+	head, err := d.read(int64(loc.rva), 4)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read %s: %w", name, err)
+	}
+	count := le32(head, 0)
+	if count > max {
+		return nil, 0, fmt.Errorf("read %s: %d entries", name, count)
+	}
+	records, err := d.read(int64(loc.rva)+4, int(count)*recordSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read %s: %w", name, err)
+	}
+	return records, int(count), nil
+}
+
+// readString reads a MINIDUMP_STRING: a byte length and UTF-16LE text.
+func (d *dumpReader) readString(rva uint32) (string, error) {
+	head, err := d.read(int64(rva), 4)
+	if err != nil {
+		return "", err
+	}
+	n := le32(head, 0)
+	if n > maxMinidumpStringLen {
+		return "", fmt.Errorf("string at %d is %d bytes", rva, n)
+	}
+	data, err := d.read(int64(rva)+4, int(n))
+	if err != nil {
+		return "", err
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+type cpuArch int
+
+const (
+	archUnknown cpuArch = iota
+	archX86
+	archAMD64
+	archARM
+	archARM64
+)
+
+func (a cpuArch) pointerSize() int {
+	if a == archAMD64 || a == archARM64 {
+		return 8
+	}
+	return 4
+}
+
+// readSystemInfo reads MINIDUMP_SYSTEM_INFO.
+func (d *dumpReader) readSystemInfo(loc location, sys *models.MinidumpSystem) (cpuArch, error) {
+	data, err := d.read(int64(loc.rva), 32)
+	if err != nil {
+		return archUnknown, fmt.Errorf("read system info: %w", err)
+	}
+
+	arch := archUnknown
+	switch code := binary.LittleEndian.Uint16(data); code {
+	case 0:
+		arch, sys.CPUArch = archX86, "x86"
+	case 9:
+		arch, sys.CPUArch = archAMD64, "amd64"
+	case 5:
+		arch, sys.CPUArch = archARM, "arm"
+	case 12, 0x8003: // Windows and Breakpad codes
+		arch, sys.CPUArch = archARM64, "arm64"
+	default:
+		sys.CPUArch = fmt.Sprintf("unknown(%d)", code)
+	}
+	sys.ProcessorCount = int(data[6])
+
+	platformID := le32(data, 20)
+	sys.OS = osName(platformID)
+	sys.OSVersion = fmt.Sprintf("%d.%d.%d", le32(data, 8), le32(data, 12), le32(data, 16))
+	if rva := le32(data, 24); rva != 0 {
+		// Windows service pack, or the kernel version from Breakpad
+		sys.ServicePack, _ = d.readString(rva)
+	}
+
+	return arch, nil
+}
+
+// osName maps a PlatformId to a name. Values above 0x8000 are Breakpad's.
+func osName(platformID uint32) string {
+	switch platformID {
+	case 2:
+		return "windows"
+	case 0x8101:
+		return "macos"
+	case 0x8102:
+		return "ios"
+	case 0x8201:
+		return "linux"
+	case 0x8203:
+		return "android"
+	default:
+		return fmt.Sprintf("unknown(%d)", platformID)
+	}
+}
+
+// moduleRange is the address range of a module, for attributing addresses.
+type moduleRange struct {
+	name string
+	base uint64
+	end  uint64
+}
+
+// findModule returns the module containing addr. modules must be sorted by
+// base address.
+func findModule(modules []moduleRange, addr uint64) (moduleRange, bool) {
+	i := sort.Search(len(modules), func(i int) bool { return modules[i].base > addr }) - 1
+	if i >= 0 && addr < modules[i].end {
+		return modules[i], true
+	}
+	return moduleRange{}, false
+}
+
+// readModules reads MINIDUMP_MODULE_LIST. The ranges are returned sorted
+// by base address, for findModule.
+func (d *dumpReader) readModules(loc location) ([]models.MinidumpModule, []moduleRange, error) {
+	records, count, err := d.readList(loc, moduleSize, maxMinidumpModules, "module list")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modules := make([]models.MinidumpModule, 0, count)
+	ranges := make([]moduleRange, 0, count)
+	for i := 0; i < count; i++ {
+		rec := records[i*moduleSize:]
+		base := le64(rec, 0)
+		size := le32(rec, 8)
+
+		name, err := d.readString(le32(rec, 20))
+		if err != nil {
+			return nil, nil, fmt.Errorf("read module %d name: %w", i, err)
+		}
+
+		mod := models.MinidumpModule{
+			Name:        path.Base(strings.ReplaceAll(name, "\\", "/")),
+			Path:        name,
+			BaseAddress: hexAddr(base),
+			Size:        size,
+			Version:     fileVersion(rec[24:76]),
+		}
+		if ts := le32(rec, 16); ts != 0 {
+			t := time.Unix(int64(ts), 0).UTC()
+			mod.Timestamp = &t
+		}
+		mod.DebugFile, mod.DebugID = d.readCodeView(location{size: le32(rec, 76), rva: le32(rec, 80)})
+
+		modules = append(modules, mod)
+		ranges = append(ranges, moduleRange{name: mod.Name, base: base, end: base + uint64(size)})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].base < ranges[j].base })
+	return modules, ranges, nil
+}
+
+// fileVersion formats the file version of a VS_FIXEDFILEINFO, or returns ""
+// when there is none.
+func fileVersion(info []byte) string {
+	if le32(info, 0) != 0xFEEF04BD {
+		return ""
+	}
+	ms, ls := le32(info, 8), le32(info, 12)
+	return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+}
+
+// readCodeView reads a module's RSDS CodeView record: the PDB name and the
+// GUID plus age that symbol servers index it by. Other record kinds are
+// ignored.
+func (d *dumpReader) readCodeView(loc location) (string, string) {
+	if loc.size < 25 || loc.size > 4096 {
+		return "", ""
+	}
+	data, err := d.read(int64(loc.rva), int(loc.size))
+	if err != nil || string(data[:4]) != "RSDS" {
+		return "", ""
+	}
+
+	guid := data[4:20]
+	debugID := fmt.Sprintf("%08X%04X%04X%X%X",
+		le32(guid, 0),
+		binary.LittleEndian.Uint16(guid[4:]),
+		binary.LittleEndian.Uint16(guid[6:]),
+		guid[8:16],
+		le32(data, 20),
+	)
+
+	pdb := data[24:]
+	if i := strings.IndexByte(string(pdb), 0); i >= 0 {
+		pdb = pdb[:i]
+	}
+	return path.Base(strings.ReplaceAll(string(pdb), "\\", "/")), debugID
+}
+
+// readException reads MINIDUMP_EXCEPTION_STREAM, returning the exception,
+// the crashing thread and the thread context captured at the exception.
+func (d *dumpReader) readException(loc location, osName string, modules []moduleRange) (*models.MinidumpException, uint32, location, error) {
+	data, err := d.read(int64(loc.rva), 168)
+	if err != nil {
+		return nil, 0, location{}, fmt.Errorf("read exception: %w", err)
+	}
+
+	threadID := le32(data, 0)
+	rec := data[8:160]
+	code := le32(rec, 0)
+	addr := le64(rec, 16)
+
+	exc := &models.MinidumpException{
+		Code:    fmt.Sprintf("0x%08X", code),
+		Name:    exceptionName(code, osName),
+		Flags:   le32(rec, 4),
+		Address: hexAddr(addr),
+	}
+	if mod, ok := findModule(modules, addr); ok {
+		exc.Module = mod.name
+		exc.ModuleOffset = hexAddr(addr - mod.base)
+	}
+
+	n := le32(rec, 24)
+	if n > 15 {
+		n = 15
+	}
+	params := make([]uint64, n)
+	for i := range params {
+		params[i] = le64(rec, 32+8*i)
+		exc.Parameters = append(exc.Parameters, hexAddr(params[i]))
+	}
+	if code == 0xC0000005 && len(params) >= 2 {
+		switch params[0] {
+		case 0:
+			exc.AccessType = "read"
+		case 1:
+			exc.AccessType = "write"
+		case 8:
+			exc.AccessType = "execute"
+		}
+		exc.AccessAddress = hexAddr(params[1])
+	}
+
+	return exc, threadID, location{size: le32(data, 160), rva: le32(data, 164)}, nil
+}
+
+// exceptionNames covers the Windows exception codes seen in game crashes.
+var exceptionNames = map[uint32]string{
+	0x80000003: "EXCEPTION_BREAKPOINT",
+	0x80000004: "EXCEPTION_SINGLE_STEP",
+	0xC0000005: "EXCEPTION_ACCESS_VIOLATION",
+	0xC0000006: "EXCEPTION_IN_PAGE_ERROR",
+	0xC000001D: "EXCEPTION_ILLEGAL_INSTRUCTION",
+	0xC000008C: "EXCEPTION_ARRAY_BOUNDS_EXCEEDED",
+	0xC000008E: "EXCEPTION_FLT_DIVIDE_BY_ZERO",
+	0xC0000094: "EXCEPTION_INT_DIVIDE_BY_ZERO",
+	0xC0000096: "EXCEPTION_PRIV_INSTRUCTION",
+	0xC00000FD: "EXCEPTION_STACK_OVERFLOW",
+	0xC0000374: "STATUS_HEAP_CORRUPTION",
+	0xC0000409: "STATUS_STACK_BUFFER_OVERRUN",
+	0xE06D7363: "CPP_EXCEPTION",
+}
+
+// signalNames are the POSIX signals Breakpad records as exception codes.
+var signalNames = map[uint32]string{
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+}
+
+func exceptionName(code uint32, osName string) string {
+	if osName == "windows" {
+		return exceptionNames[code]
+	}
+	switch {
+	case code == 7 && (osName == "linux" || osName == "android"):
+		return "SIGBUS"
+	case code == 10 && (osName == "macos" || osName == "ios"):
+		return "SIGBUS"
+	}
+	return signalNames[code]
+}
+
+// readThreads reads MINIDUMP_THREAD_LIST and scans each thread's stack.
+func (d *dumpReader) readThreads(loc location, arch cpuArch, modules []moduleRange, crashedID *uint32, exceptionContext location) ([]models.MinidumpThread, error) {
+	records, count, err := d.readList(loc, threadSize, maxMinidumpThreads, "thread list")
+	if err != nil {
+		return nil, err
+	}
+
+	threads := make([]models.MinidumpThread, 0, count)
+	for i := 0; i < count; i++ {
+		rec := records[i*threadSize:]
+		stackStart := le64(rec, 24)
+		stackMem := location{size: le32(rec, 32), rva: le32(rec, 36)}
+		context := location{size: le32(rec, 40), rva: le32(rec, 44)}
+
+		t := models.MinidumpThread{
+			ThreadID:   le32(rec, 0),
+			StackStart: hexAddr(stackStart),
+			StackSize:  stackMem.size,
+			Frames:     make([]models.MinidumpFrame, 0),
+		}
+		maxFrames := maxThreadFrames
+		if crashedID != nil && t.ThreadID == *crashedID {
+			t.Crashed = true
+			maxFrames = maxCrashedFrames
+			// The thread list holds the handler's context; the exception
+			// stream holds the one at the fault
+			if exceptionContext.size > 0 {
+				context = exceptionContext
+			}
+		}
+
+		ip, sp, ok := d.readContext(context, arch)
+		if ok {
+			t.InstructionPointer = hexAddr(ip)
+			t.StackPointer = hexAddr(sp)
+			if mod, found := findModule(modules, ip); found {
+				t.Frames = append(t.Frames, frame(ip, mod, "context"))
+			}
+		}
+
+		t.Frames = append(t.Frames, d.scanStack(stackStart, stackMem, sp, arch, modules, maxFrames-len(t.Frames))...)
+		threads = append(threads, t)
+	}
+	return threads, nil
+}
+
+// readContext returns the instruction and stack pointers from a thread
+// context in the Windows CONTEXT layout, which Breakpad also uses.
+func (d *dumpReader) readContext(loc location, arch cpuArch) (uint64, uint64, bool) {
+	var ipOff, spOff, width int
+	switch arch {
+	case archX86:
+		ipOff, spOff, width = 0xB8, 0xC4, 4
+	case archAMD64:
+		ipOff, spOff, width = 0xF8, 0x98, 8
+	case archARM64:
+		ipOff, spOff, width = 0x108, 0x100, 8
+	default:
+		return 0, 0, false
+	}
+	if int(loc.size) < ipOff+width || int(loc.size) < spOff+width {
+		return 0, 0, false
+	}
+	data, err := d.read(int64(loc.rva), int(loc.size))
+	if err != nil {
+		return 0, 0, false
+	}
+	if width == 4 {
+		return uint64(le32(data, ipOff)), uint64(le32(data, spOff)), true
+	}
+	return le64(data, ipOff), le64(data, spOff), true
+}
+
+// scanStack walks a thread's stack memory from the stack pointer upwards
+// and returns the values that point into a module, as candidate return
+// addresses.
+func (d *dumpReader) scanStack(start uint64, mem location, sp uint64, arch cpuArch, modules []moduleRange, max int) []models.MinidumpFrame {
+	frames := make([]models.MinidumpFrame, 0)
+	if max <= 0 || mem.size == 0 || len(modules) == 0 {
+		return frames
+	}
+
+	// Skip what lies below the stack pointer; it belongs to returned calls
+	var skip uint64
+	if sp > start && sp < start+uint64(mem.size) {
+		skip = sp - start
+	}
+	n := uint64(mem.size) - skip
+	if n > maxStackScan {
+		n = maxStackScan
+	}
+	data, err := d.read(int64(mem.rva)+int64(skip), int(n))
+	if err != nil {
+		return frames
+	}
+
+	width := arch.pointerSize()
+	for off := 0; off+width <= len(data) && len(frames) < max; off += width {
+		var value uint64
+		if width == 8 {
+			value = le64(data, off)
+		} else {
+			value = uint64(le32(data, off))
+		}
+		if mod, ok := findModule(modules, value); ok {
+			frames = append(frames, frame(value, mod, "scan"))
+		}
+	}
+	return frames
+}
+
+func frame(addr uint64, mod moduleRange, trust string) models.MinidumpFrame {
+	return models.MinidumpFrame{
+		Address: hexAddr(addr),
+		Module:  mod.name,
+		Offset:  hexAddr(addr - mod.base),
+		Trust:   trust,
+	}
+}
+
+func hexAddr(v uint64) string {
+	return fmt.Sprintf("0x%x", v)
+}
+
+func le32(b []byte, off int) uint32 {
+	return binary.LittleEndian.Uint32(b[off:])
+}
+
+func le64(b []byte, off int) uint64 {
+	return binary.LittleEndian.Uint64(b[off:])
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// ExportAnnotations returns the tags and notes of every bundle that has any,
+// ordered by bundle ID.
+func (db *DB) ExportAnnotations() (*models.Annotations, error) {
+	export := &models.Annotations{
+		Version:    models.AnnotationsVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Bundles:    []models.BundleAnnotations{},
+	}
+	index := make(map[string]int)
+	bundle := func(bundleID string) *models.BundleAnnotations {
+		n, ok := index[bundleID]
+		if !ok {
+			n = len(export.Bundles)
+			index[bundleID] = n
+			export.Bundles = append(export.Bundles, models.BundleAnnotations{BundleID: bundleID})
+		}
+		return &export.Bundles[n]
+	}
+
+	tagRows, err := db.conn.Query("SELECT bundle_id, tag FROM tags ORDER BY bundle_id, tag")
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var bundleID, tag string
+		if err := tagRows.Scan(&bundleID, &tag); err != nil {
+			return nil, err
+		}
+		b := bundle(bundleID)
+		b.Tags = append(b.Tags, tag)
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, err
+	}
+	tagRows.Close()
+
+	noteRows, err := db.conn.Query(`
+		SELECT note_id, bundle_id, author, content, created_at
+		FROM qa_notes
+		ORDER BY bundle_id, created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer noteRows.Close()
+
+	for noteRows.Next() {
+		var n models.QANote
+		var createdAt string
+		if err := noteRows.Scan(&n.NoteID, &n.BundleID, &n.Author, &n.Content, &createdAt); err != nil {
+			return nil, err
+		}
+		n.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		b := bundle(n.BundleID)
+		b.Notes = append(b.Notes, n)
+	}
+	if err := noteRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Bundles with notes but no tags were appended after the rest
+	slices.SortFunc(export.Bundles, func(a, b models.BundleAnnotations) int {
+		return strings.Compare(a.BundleID, b.BundleID)
+	})
+	return export, nil
+}
+
+// ImportAnnotations adds the tags and notes in a to the bundles they belong
+// to, in one transaction. Tags and notes already present are left alone, so
+// an export can be imported more than once; notes keep their IDs and
+// creation times. Bundles not in the database are reported as missing.
+func (db *DB) ImportAnnotations(a *models.Annotations) (*models.AnnotationsImport, error) {
+	if a.Version != models.AnnotationsVersion {
+		return nil, fmt.Errorf("unsupported annotations version %d", a.Version)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &models.AnnotationsImport{}
+	for _, b := range a.Bundles {
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM repro_bundles WHERE bundle_id = ?", b.BundleID).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			result.Missing = append(result.Missing, b.BundleID)
+			continue
+		}
+
+		for _, tag := range b.Tags {
+			res, err := tx.Exec("INSERT OR IGNORE INTO tags (bundle_id, tag) VALUES (?, ?)", b.BundleID, tag)
+			if err != nil {
+				return nil, fmt.Errorf("insert tag %q: %w", tag, err)
+			}
+			added, _ := res.RowsAffected()
+			result.Tags += int(added)
+		}
+
+		for _, note := range b.Notes {
+			createdAt := note.CreatedAt
+			if createdAt.IsZero() {
+				createdAt = time.Now()
+			}
+			res, err := tx.Exec(`
+				INSERT OR IGNORE INTO qa_notes (note_id, bundle_id, author, content, created_at)
+				VALUES (?, ?, ?, ?, ?)`,
+				note.NoteID, b.BundleID, note.Author, note.Content,
+				createdAt.UTC().Format(time.RFC3339),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("insert note %s: %w", note.NoteID, err)
+			}
+			added, _ := res.RowsAffected()
+			result.Notes += int(added)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return result, nil
+}
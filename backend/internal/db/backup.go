@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot writes a consistent copy of the database to path with
+// VACUUM INTO. It only holds a read transaction, so it is safe while other
+// processes, such as a running server, write to the database; their
+// changes after the transaction began are not included. path must not
+// exist.
+func (db *DB) Snapshot(path string) error {
+	if _, err := db.conn.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("vacuum into %s: %w", path, err)
+	}
+	return nil
+}
+
+// OpenSnapshot opens a database snapshot read-only after checking it with
+// PRAGMA integrity_check. Unlike Open it neither creates nor migrates the
+// schema, so the snapshot is left exactly as it was taken.
+func OpenSnapshot(path string) (*DB, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs // Windows drive letter
+	}
+	uri := (&url.URL{Scheme: "file", Path: abs}).String()
+
+	conn, err := sql.Open("sqlite", uri+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	conn.SetMaxOpenConns(1)
+
+	db := &DB{conn: conn}
+	if err := db.checkIntegrity(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// checkIntegrity runs PRAGMA integrity_check and verifies the database
+// holds the BugIt schema.
+func (db *DB) checkIntegrity() error {
+	rows, err := db.conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("integrity check: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	var n int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM repro_bundles").Scan(&n); err != nil {
+		return fmt.Errorf("not a BugIt database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// hasCrashColumn selects whether a bundle has a parsed crash from
+// repro_bundles without a join, like validationStatusColumn.
+const hasCrashColumn = `EXISTS (SELECT 1 FROM crashes c WHERE c.bundle_id = repro_bundles.bundle_id)`
+
+func insertCrash(e execer, bundleID string, c *models.Crash) error {
+	callstack, err := json.Marshal(c.Callstack)
+	if err != nil {
+		return fmt.Errorf("marshal callstack: %w", err)
+	}
+	modules, err := json.Marshal(c.Modules)
+	if err != nil {
+		return fmt.Errorf("marshal modules: %w", err)
+	}
+
+	_, err = e.Exec(`
+		INSERT INTO crashes (
+			crash_id, bundle_id, source, crash_type, error_message,
+			crash_guid, execution_guid, engine_version, build_version,
+			build_config, platform, game_name, callstack_json, modules_json
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.CrashID,
+		bundleID,
+		c.Source,
+		c.CrashType,
+		c.ErrorMessage,
+		c.CrashGUID,
+		c.ExecutionGUID,
+		c.EngineVersion,
+		c.BuildVersion,
+		c.BuildConfig,
+		c.Platform,
+		c.GameName,
+		string(callstack),
+		string(modules),
+	)
+	return err
+}
+
+// GetCrashes returns the crashes parsed from a bundle, ordered by source.
+func (db *DB) GetCrashes(bundleID string) ([]models.Crash, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, crash_id, source, crash_type, error_message,
+		       crash_guid, execution_guid, engine_version, build_version,
+		       build_config, platform, game_name, callstack_json, modules_json,
+		       created_at
+		FROM crashes WHERE bundle_id = ?
+		ORDER BY source`, bundleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crashes []models.Crash
+	for rows.Next() {
+		var c models.Crash
+		var callstack, modules, createdAt string
+
+		err := rows.Scan(
+			&c.ID,
+			&c.CrashID,
+			&c.Source,
+			&c.CrashType,
+			&c.ErrorMessage,
+			&c.CrashGUID,
+			&c.ExecutionGUID,
+			&c.EngineVersion,
+			&c.BuildVersion,
+			&c.BuildConfig,
+			&c.Platform,
+			&c.GameName,
+			&callstack,
+			&modules,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		c.BundleID = bundleID
+		if err := json.Unmarshal([]byte(callstack), &c.Callstack); err != nil {
+			return nil, fmt.Errorf("parse callstack of %s: %w", c.CrashID, err)
+		}
+		if err := json.Unmarshal([]byte(modules), &c.Modules); err != nil {
+			return nil, fmt.Errorf("parse modules of %s: %w", c.CrashID, err)
+		}
+		c.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		crashes = append(crashes, c)
+	}
+
+	return crashes, rows.Err()
+}
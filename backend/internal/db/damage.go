@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// MarkBundleDamaged records the problems found with a bundle's files,
+// replacing any recorded before. The first detection time is kept.
+func (db *DB) MarkBundleDamaged(bundleID string, problems []models.IntegrityProblem) error {
+	data, err := json.Marshal(problems)
+	if err != nil {
+		return fmt.Errorf("marshal problems: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO bundle_damage (bundle_id, problems_json) VALUES (?, ?)
+		ON CONFLICT(bundle_id) DO UPDATE SET problems_json = excluded.problems_json`,
+		bundleID, string(data),
+	)
+	return err
+}
+
+// ClearBundleDamage removes a bundle's damage record. It returns false if
+// there was none.
+func (db *DB) ClearBundleDamage(bundleID string) (bool, error) {
+	res, err := db.conn.Exec("DELETE FROM bundle_damage WHERE bundle_id = ?", bundleID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListDamagedBundles returns the IDs of all bundles marked damaged.
+func (db *DB) ListDamagedBundles() (map[string]bool, error) {
+	rows, err := db.conn.Query("SELECT bundle_id FROM bundle_damage")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	damaged := make(map[string]bool)
+	for rows.Next() {
+		var bundleID string
+		if err := rows.Scan(&bundleID); err != nil {
+			return nil, err
+		}
+		damaged[bundleID] = true
+	}
+	return damaged, rows.Err()
+}
+
+// GetBundleDamage returns a bundle's damage record, or nil if it has none.
+func (db *DB) GetBundleDamage(bundleID string) (*models.BundleDamage, error) {
+	var problems, detectedAt string
+	err := db.conn.QueryRow(
+		"SELECT problems_json, detected_at FROM bundle_damage WHERE bundle_id = ?", bundleID,
+	).Scan(&problems, &detectedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query damage: %w", err)
+	}
+
+	damage := &models.BundleDamage{}
+	if err := json.Unmarshal([]byte(problems), &damage.Problems); err != nil {
+		return nil, fmt.Errorf("decode damage: %w", err)
+	}
+	damage.DetectedAt, _ = time.Parse(time.RFC3339, detectedAt)
+	return damage, nil
+}
+
+// RegisterArtifact adds an artifact found in a bundle's directory after
+// ingest and counts it in the bundle's artifact_count.
+func (db *DB) RegisterArtifact(artifact *models.Artifact) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertArtifact(tx, artifact); err != nil {
+		return fmt.Errorf("insert artifact: %w", err)
+	}
+	if _, err := tx.Exec(
+		"UPDATE repro_bundles SET artifact_count = artifact_count + 1 WHERE bundle_id = ?",
+		artifact.BundleID,
+	); err != nil {
+		return fmt.Errorf("update artifact count: %w", err)
+	}
+
+	return tx.Commit()
+}
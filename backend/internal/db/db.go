@@ -5,12 +5,13 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "modernc.org/sqlite" // Pure Go SQLite driver - no CGO required
 	"github.com/unrealsolutions/bugit/internal/models"
+	_ "modernc.org/sqlite" // Pure Go SQLite driver - no CGO required
 )
 
 //go:embed schema.sql
@@ -23,7 +24,10 @@ type DB struct {
 
 // Open opens the SQLite database and initializes the schema.
 func Open(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	// Transactions take the write lock up front (BEGIN IMMEDIATE) so that
+	// concurrent ingests wait on busy_timeout instead of failing with
+	// SQLITE_BUSY when a read transaction is upgraded to a write.
+	conn, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
@@ -66,7 +70,7 @@ func (db *DB) initSchema() error {
 		return fmt.Errorf("exec schema: %w", err)
 	}
 
-	return nil
+	return db.migrate()
 }
 
 // Close closes the database connection.
@@ -80,9 +84,31 @@ func (db *DB) CheckHealth() error {
 	return db.conn.QueryRow("SELECT 1").Scan(&result)
 }
 
-// InsertBundle inserts a new repro bundle.
-// Returns the bundle_id if successful, or existing bundle_id if content_hash exists.
-func (db *DB) InsertBundle(bundle *models.ReproBundle) (string, bool, error) {
+// FindBundleByContentHash returns the bundle_id with the given archive hash
+// or canonical hash, or "" if there is none.
+func (db *DB) FindBundleByContentHash(contentHash, canonicalHash string) (string, error) {
+	var bundleID string
+	err := db.conn.QueryRow(
+		"SELECT bundle_id FROM repro_bundles WHERE content_hash = ? OR canonical_hash = ? LIMIT 1",
+		contentHash, canonicalHash,
+	).Scan(&bundleID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return bundleID, err
+}
+
+// ErrBundleIDTaken is returned by InsertBundle when the bundle_id is already
+// used by a bundle with different content.
+var ErrBundleIDTaken = errors.New("bundle_id already in use")
+
+// InsertBundle inserts a new repro bundle together with its artifacts,
+// validation result, crashes and minidump analyses in one transaction, so a
+// bundle is never visible without its artifacts. The bundle is grouped into
+// the issue for its signature, and bundle.IssueID set.
+// Returns the bundle_id if successful, or existing bundle_id if content_hash
+// or canonical_hash exists.
+func (db *DB) InsertBundle(bundle *models.ReproBundle, artifacts []*models.Artifact) (string, bool, error) {
 	tx, err := db.conn.Begin()
 	if err != nil {
 		return "", false, fmt.Errorf("begin tx: %w", err)
@@ -92,8 +118,8 @@ func (db *DB) InsertBundle(bundle *models.ReproBundle) (string, bool, error) {
 	// Check if bundle already exists by content hash
 	var existingID string
 	err = tx.QueryRow(
-		"SELECT bundle_id FROM repro_bundles WHERE content_hash = ?",
-		bundle.ContentHash,
+		"SELECT bundle_id FROM repro_bundles WHERE content_hash = ? OR canonical_hash = ? LIMIT 1",
+		bundle.ContentHash, bundle.CanonicalHash,
 	).Scan(&existingID)
 
 	if err == nil {
@@ -103,20 +129,35 @@ func (db *DB) InsertBundle(bundle *models.ReproBundle) (string, bool, error) {
 		return "", false, fmt.Errorf("check existing: %w", err)
 	}
 
+	// Client-supplied IDs can be reused for different content
+	err = tx.QueryRow("SELECT bundle_id FROM repro_bundles WHERE bundle_id = ?", bundle.BundleID).Scan(&existingID)
+	if err == nil {
+		return "", false, ErrBundleIDTaken
+	} else if err != sql.ErrNoRows {
+		return "", false, fmt.Errorf("check bundle id: %w", err)
+	}
+
 	// Insert new bundle
 	metadataJSON := ""
 	if bundle.Metadata != nil {
 		metadataJSON = string(bundle.Metadata)
 	}
 
+	// Reindexed bundles keep their original ingest time
+	var createdAt any
+	if !bundle.CreatedAt.IsZero() {
+		createdAt = bundle.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
 	_, err = tx.Exec(`
 		INSERT INTO repro_bundles (
-			bundle_id, content_hash, schema_version, build_id, map_name,
+			bundle_id, content_hash, canonical_hash, schema_version, build_id, map_name,
 			platform, rvr_version, bundle_timestamp, metadata_json,
-			size_bytes, artifact_count, storage_path
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			size_bytes, artifact_count, storage_path, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, strftime('%Y-%m-%dT%H:%M:%SZ', 'now')))`,
 		bundle.BundleID,
 		bundle.ContentHash,
+		bundle.CanonicalHash,
 		bundle.SchemaVersion,
 		bundle.BuildID,
 		bundle.MapName,
@@ -127,11 +168,40 @@ func (db *DB) InsertBundle(bundle *models.ReproBundle) (string, bool, error) {
 		bundle.SizeBytes,
 		bundle.ArtifactCount,
 		bundle.StoragePath,
+		createdAt,
 	)
 	if err != nil {
 		return "", false, fmt.Errorf("insert bundle: %w", err)
 	}
 
+	for _, artifact := range artifacts {
+		if err := insertArtifact(tx, artifact); err != nil {
+			return "", false, fmt.Errorf("insert artifact %s: %w", artifact.Filename, err)
+		}
+	}
+
+	if bundle.Validation != nil {
+		if err := insertValidation(tx, bundle.BundleID, bundle.Validation); err != nil {
+			return "", false, fmt.Errorf("insert validation: %w", err)
+		}
+	}
+
+	for i := range bundle.Crashes {
+		if err := insertCrash(tx, bundle.BundleID, &bundle.Crashes[i]); err != nil {
+			return "", false, fmt.Errorf("insert crash %s: %w", bundle.Crashes[i].Source, err)
+		}
+	}
+
+	for i := range bundle.Minidumps {
+		if err := insertMinidump(tx, bundle.BundleID, &bundle.Minidumps[i]); err != nil {
+			return "", false, fmt.Errorf("insert minidump %s: %w", bundle.Minidumps[i].Source, err)
+		}
+	}
+
+	if bundle.IssueID, err = assignIssue(tx, bundle.BundleID, bundle.Signature); err != nil {
+		return "", false, fmt.Errorf("assign issue: %w", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		return "", false, fmt.Errorf("commit: %w", err)
 	}
@@ -139,13 +209,22 @@ func (db *DB) InsertBundle(bundle *models.ReproBundle) (string, bool, error) {
 	return bundle.BundleID, false, nil
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
 // InsertArtifact inserts an artifact for a bundle.
 func (db *DB) InsertArtifact(artifact *models.Artifact) error {
-	_, err := db.conn.Exec(`
+	return insertArtifact(db.conn, artifact)
+}
+
+func insertArtifact(e execer, artifact *models.Artifact) error {
+	_, err := e.Exec(`
 		INSERT INTO artifacts (
 			artifact_id, bundle_id, filename, artifact_type,
-			mime_type, size_bytes, storage_path, checksum
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			mime_type, size_bytes, storage_path, checksum, undeclared, role
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		artifact.ArtifactID,
 		artifact.BundleID,
 		artifact.Filename,
@@ -154,6 +233,87 @@ func (db *DB) InsertArtifact(artifact *models.Artifact) error {
 		artifact.SizeBytes,
 		artifact.StoragePath,
 		artifact.Checksum,
+		artifact.Undeclared,
+		artifact.Role,
+	)
+	return err
+}
+
+// BundleExists reports whether a bundle with the given ID exists.
+func (db *DB) BundleExists(bundleID string) (bool, error) {
+	var n int
+	err := db.conn.QueryRow("SELECT COUNT(*) FROM repro_bundles WHERE bundle_id = ?", bundleID).Scan(&n)
+	return n > 0, err
+}
+
+// BundleBytes returns the total size of the bundles of buildID and of the
+// bundles of platform.
+func (db *DB) BundleBytes(buildID, platform string) (buildBytes, platformBytes int64, err error) {
+	err = db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN build_id = ? THEN size_bytes END), 0),
+			COALESCE(SUM(CASE WHEN platform = ? THEN size_bytes END), 0)
+		FROM repro_bundles
+		WHERE build_id = ? OR platform = ?`,
+		buildID, platform, buildID, platform,
+	).Scan(&buildBytes, &platformBytes)
+	return buildBytes, platformBytes, err
+}
+
+// ListBundleStoragePaths returns the storage_path of every bundle keyed by bundle_id.
+func (db *DB) ListBundleStoragePaths() (map[string]string, error) {
+	rows, err := db.conn.Query("SELECT bundle_id, storage_path FROM repro_bundles")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make(map[string]string)
+	for rows.Next() {
+		var bundleID, storagePath string
+		if err := rows.Scan(&bundleID, &storagePath); err != nil {
+			return nil, err
+		}
+		paths[bundleID] = storagePath
+	}
+
+	return paths, rows.Err()
+}
+
+// ListBundlesWithoutCanonicalHash returns the storage_path of every bundle
+// that has no canonical hash yet, keyed by bundle_id.
+func (db *DB) ListBundlesWithoutCanonicalHash() (map[string]string, error) {
+	rows, err := db.conn.Query("SELECT bundle_id, storage_path FROM repro_bundles WHERE canonical_hash IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make(map[string]string)
+	for rows.Next() {
+		var bundleID, storagePath string
+		if err := rows.Scan(&bundleID, &storagePath); err != nil {
+			return nil, err
+		}
+		paths[bundleID] = storagePath
+	}
+	return paths, rows.Err()
+}
+
+// SetBundleCanonicalHash records the canonical hash of a bundle.
+func (db *DB) SetBundleCanonicalHash(bundleID, canonicalHash string) error {
+	_, err := db.conn.Exec(
+		"UPDATE repro_bundles SET canonical_hash = ? WHERE bundle_id = ?",
+		canonicalHash, bundleID,
+	)
+	return err
+}
+
+// SetBundleStoragePath records where a bundle's directory lives.
+func (db *DB) SetBundleStoragePath(bundleID, storagePath string) error {
+	_, err := db.conn.Exec(
+		"UPDATE repro_bundles SET storage_path = ? WHERE bundle_id = ?",
+		storagePath, bundleID,
 	)
 	return err
 }
@@ -161,19 +321,21 @@ func (db *DB) InsertArtifact(artifact *models.Artifact) error {
 // GetBundle retrieves a bundle by ID with all related data.
 func (db *DB) GetBundle(bundleID string) (*models.ReproBundle, error) {
 	bundle := &models.ReproBundle{}
-	var metadataJSON sql.NullString
+	var metadataJSON, canonicalHash sql.NullString
 	var bundleTimestamp string
 	var createdAt string
 
 	err := db.conn.QueryRow(`
-		SELECT id, bundle_id, content_hash, schema_version, build_id, map_name,
+		SELECT id, bundle_id, content_hash, canonical_hash, schema_version, build_id, map_name,
 		       platform, rvr_version, bundle_timestamp, metadata_json,
-		       size_bytes, artifact_count, storage_path, created_at
+		       size_bytes, artifact_count, storage_path, created_at,
+		       `+validationStatusColumn+`
 		FROM repro_bundles WHERE bundle_id = ?`, bundleID,
 	).Scan(
 		&bundle.ID,
 		&bundle.BundleID,
 		&bundle.ContentHash,
+		&canonicalHash,
 		&bundle.SchemaVersion,
 		&bundle.BuildID,
 		&bundle.MapName,
@@ -185,6 +347,7 @@ func (db *DB) GetBundle(bundleID string) (*models.ReproBundle, error) {
 		&bundle.ArtifactCount,
 		&bundle.StoragePath,
 		&createdAt,
+		&bundle.ValidationStatus,
 	)
 
 	if err == sql.ErrNoRows {
@@ -196,6 +359,7 @@ func (db *DB) GetBundle(bundleID string) (*models.ReproBundle, error) {
 
 	bundle.BundleTimestamp, _ = time.Parse(time.RFC3339, bundleTimestamp)
 	bundle.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	bundle.CanonicalHash = canonicalHash.String
 	if metadataJSON.Valid {
 		bundle.Metadata = json.RawMessage(metadataJSON.String)
 	}
@@ -218,6 +382,23 @@ func (db *DB) GetBundle(bundleID string) (*models.ReproBundle, error) {
 		return nil, fmt.Errorf("get notes: %w", err)
 	}
 
+	// Load crashes
+	bundle.Crashes, err = db.GetCrashes(bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("get crashes: %w", err)
+	}
+	bundle.HasCrash = len(bundle.Crashes) > 0
+
+	bundle.Signature, bundle.IssueID, err = db.GetBundleSignature(bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("load signature: %w", err)
+	}
+
+	bundle.Damage, err = db.GetBundleDamage(bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("load damage: %w", err)
+	}
+
 	return bundle, nil
 }
 
@@ -225,7 +406,7 @@ func (db *DB) GetBundle(bundleID string) (*models.ReproBundle, error) {
 func (db *DB) GetArtifacts(bundleID string) ([]models.Artifact, error) {
 	rows, err := db.conn.Query(`
 		SELECT artifact_id, filename, artifact_type, mime_type,
-		       size_bytes, storage_path, checksum, created_at
+		       size_bytes, storage_path, checksum, undeclared, role, created_at
 		FROM artifacts WHERE bundle_id = ?
 		ORDER BY filename`, bundleID,
 	)
@@ -237,7 +418,7 @@ func (db *DB) GetArtifacts(bundleID string) ([]models.Artifact, error) {
 	var artifacts []models.Artifact
 	for rows.Next() {
 		var a models.Artifact
-		var mimeType, checksum sql.NullString
+		var mimeType, checksum, role sql.NullString
 		var createdAt string
 
 		err := rows.Scan(
@@ -248,6 +429,8 @@ func (db *DB) GetArtifacts(bundleID string) ([]models.Artifact, error) {
 			&a.SizeBytes,
 			&a.StoragePath,
 			&checksum,
+			&a.Undeclared,
+			&role,
 			&createdAt,
 		)
 		if err != nil {
@@ -257,6 +440,7 @@ func (db *DB) GetArtifacts(bundleID string) ([]models.Artifact, error) {
 		a.BundleID = bundleID
 		a.MimeType = mimeType.String
 		a.Checksum = checksum.String
+		a.Role = role.String
 		a.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		artifacts = append(artifacts, a)
 	}
@@ -267,12 +451,12 @@ func (db *DB) GetArtifacts(bundleID string) ([]models.Artifact, error) {
 // GetArtifact retrieves a single artifact by ID.
 func (db *DB) GetArtifact(artifactID string) (*models.Artifact, error) {
 	var a models.Artifact
-	var mimeType, checksum sql.NullString
+	var mimeType, checksum, role sql.NullString
 	var createdAt string
 
 	err := db.conn.QueryRow(`
 		SELECT artifact_id, bundle_id, filename, artifact_type, mime_type,
-		       size_bytes, storage_path, checksum, created_at
+		       size_bytes, storage_path, checksum, undeclared, role, created_at
 		FROM artifacts WHERE artifact_id = ?`, artifactID,
 	).Scan(
 		&a.ArtifactID,
@@ -283,6 +467,8 @@ func (db *DB) GetArtifact(artifactID string) (*models.Artifact, error) {
 		&a.SizeBytes,
 		&a.StoragePath,
 		&checksum,
+		&a.Undeclared,
+		&role,
 		&createdAt,
 	)
 
@@ -295,6 +481,7 @@ func (db *DB) GetArtifact(artifactID string) (*models.Artifact, error) {
 
 	a.MimeType = mimeType.String
 	a.Checksum = checksum.String
+	a.Role = role.String
 	a.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 
 	return &a, nil
@@ -398,6 +585,9 @@ func (db *DB) PurgeAllBundles() (int, error) {
 	if _, err := tx.Exec("DELETE FROM repro_bundles"); err != nil {
 		return 0, fmt.Errorf("delete bundles: %w", err)
 	}
+	if _, err := tx.Exec("DELETE FROM issues"); err != nil {
+		return 0, fmt.Errorf("delete issues: %w", err)
+	}
 
 	if err := tx.Commit(); err != nil {
 		return 0, fmt.Errorf("commit: %w", err)
@@ -429,6 +619,40 @@ func (db *DB) ListBundles(query *models.BundleListQuery) (*models.BundleListResu
 		args = append(args, query.Since.Format(time.RFC3339))
 	}
 
+	switch {
+	case query.ValidationStatus == models.ValidationUnvalidated:
+		conditions = append(conditions, "bundle_id NOT IN (SELECT bundle_id FROM bundle_validations)")
+	case query.ValidationStatus != "":
+		conditions = append(conditions, "bundle_id IN (SELECT bundle_id FROM bundle_validations WHERE status = ?)")
+		args = append(args, query.ValidationStatus)
+	case query.Valid != nil && *query.Valid:
+		conditions = append(conditions, "bundle_id IN (SELECT bundle_id FROM bundle_validations WHERE status = 'passed')")
+	case query.Valid != nil:
+		conditions = append(conditions, "bundle_id IN (SELECT bundle_id FROM bundle_validations WHERE status != 'passed')")
+	default:
+		conditions = append(conditions, "bundle_id NOT IN (SELECT bundle_id FROM bundle_validations WHERE status = 'quarantined')")
+	}
+
+	if query.HasCrash != nil {
+		if *query.HasCrash {
+			conditions = append(conditions, hasCrashColumn)
+		} else {
+			conditions = append(conditions, "NOT "+hasCrashColumn)
+		}
+	}
+	if query.ExceptionCode != "" {
+		conditions = append(conditions, "bundle_id IN (SELECT bundle_id FROM minidumps WHERE exception_code = ? COLLATE NOCASE)")
+		args = append(args, query.ExceptionCode)
+	}
+	if query.CrashModule != "" {
+		conditions = append(conditions, "bundle_id IN (SELECT bundle_id FROM minidumps WHERE crash_module = ? COLLATE NOCASE)")
+		args = append(args, query.CrashModule)
+	}
+	if query.IssueID != "" {
+		conditions = append(conditions, "bundle_id IN (SELECT bundle_id FROM bundle_signatures WHERE issue_id = ?)")
+		args = append(args, query.IssueID)
+	}
+
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
@@ -454,10 +678,10 @@ func (db *DB) ListBundles(query *models.BundleListQuery) (*models.BundleListResu
 	querySQL := fmt.Sprintf(`
 		SELECT bundle_id, content_hash, schema_version, build_id, map_name,
 		       platform, rvr_version, bundle_timestamp, size_bytes,
-		       artifact_count, created_at
+		       artifact_count, created_at, %s, %s, %s
 		FROM repro_bundles %s
 		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?`, whereClause)
+		LIMIT ? OFFSET ?`, validationStatusColumn, hasCrashColumn, issueIDColumn, whereClause)
 
 	args = append(args, limit, query.Offset)
 
@@ -484,6 +708,9 @@ func (db *DB) ListBundles(query *models.BundleListQuery) (*models.BundleListResu
 			&b.SizeBytes,
 			&b.ArtifactCount,
 			&createdAt,
+			&b.ValidationStatus,
+			&b.HasCrash,
+			&b.IssueID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan bundle: %w", err)
@@ -0,0 +1,440 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// Errors returned by MergeIssues and SplitIssue.
+var (
+	ErrIssueNotFound  = errors.New("issue not found")
+	ErrNotIssueMember = errors.New("not part of the issue")
+)
+
+// issueIDColumn selects a bundle's issue from repro_bundles without a join,
+// like validationStatusColumn.
+const issueIDColumn = `COALESCE((SELECT s.issue_id FROM bundle_signatures s WHERE s.bundle_id = repro_bundles.bundle_id), '')`
+
+// issueMembers selects the bundles grouped into issues. Quarantined bundles
+// are left out, as they are from bundle listings.
+const issueMembers = `(
+	SELECT s.issue_id, b.id, b.bundle_id, b.build_id, b.platform, b.map_name, b.created_at
+	FROM bundle_signatures s JOIN repro_bundles b ON b.bundle_id = s.bundle_id
+	WHERE s.issue_id IS NOT NULL
+	  AND b.bundle_id NOT IN (SELECT bundle_id FROM bundle_validations WHERE status = 'quarantined'))`
+
+// issueSelect selects an issue with the statistics of its members. Issues
+// without members have a zero count and no first or last seen.
+const issueSelect = `
+	SELECT i.id, i.issue_id, i.title, i.created_at,
+	       COUNT(m.bundle_id), MIN(m.created_at), MAX(m.created_at),
+	       json_group_array(DISTINCT m.platform) FILTER (WHERE m.platform != ''),
+	       json_group_array(DISTINCT m.map_name) FILTER (WHERE m.map_name != ''),
+	       (SELECT f.build_id FROM ` + issueMembers + ` f WHERE f.issue_id = i.issue_id ORDER BY f.created_at, f.id LIMIT 1),
+	       (SELECT l.build_id FROM ` + issueMembers + ` l WHERE l.issue_id = i.issue_id ORDER BY l.created_at DESC, l.id DESC LIMIT 1)
+	FROM issues i LEFT JOIN ` + issueMembers + ` m ON m.issue_id = i.issue_id`
+
+// assignIssue records a bundle's signature and groups the bundle into the
+// issue for that signature, opening a new issue for a signature not seen
+// before. A nil signature is recorded too, so the bundle is not
+// fingerprinted again. It returns the issue ID, or "" for a nil signature.
+func assignIssue(tx *sql.Tx, bundleID string, sig *models.CrashSignature) (string, error) {
+	if sig == nil {
+		_, err := tx.Exec(
+			"INSERT INTO bundle_signatures (bundle_id) VALUES (?) ON CONFLICT (bundle_id) DO NOTHING",
+			bundleID,
+		)
+		return "", err
+	}
+
+	var issueID string
+	err := tx.QueryRow("SELECT issue_id FROM issue_signatures WHERE signature = ?", sig.Signature).Scan(&issueID)
+	if err == sql.ErrNoRows {
+		issueID = "iss_" + ids.Generate(8)
+		if _, err := tx.Exec("INSERT INTO issues (issue_id, title) VALUES (?, ?)", issueID, sig.Title); err != nil {
+			return "", fmt.Errorf("insert issue: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO issue_signatures (signature, issue_id, source, signature_text, title)
+			VALUES (?, ?, ?, ?, ?)`,
+			sig.Signature, issueID, sig.Source, sig.Text, sig.Title,
+		)
+		if err != nil {
+			return "", fmt.Errorf("insert issue signature: %w", err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("look up signature: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO bundle_signatures (bundle_id, signature, source, signature_text, title, issue_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (bundle_id) DO NOTHING`,
+		bundleID, sig.Signature, sig.Source, sig.Text, sig.Title, issueID,
+	)
+	if err != nil {
+		return "", fmt.Errorf("insert bundle signature: %w", err)
+	}
+	return issueID, nil
+}
+
+// SetBundleSignature records the signature of a bundle ingested before
+// signatures were computed and groups it into an issue. A bundle that
+// already has one is left alone.
+func (db *DB) SetBundleSignature(bundleID string, sig *models.CrashSignature) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := assignIssue(tx, bundleID, sig); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListBundlesWithoutSignature returns the bundle_id and storage_path of
+// bundles that have not been fingerprinted.
+func (db *DB) ListBundlesWithoutSignature() (map[string]string, error) {
+	rows, err := db.conn.Query(
+		"SELECT bundle_id, storage_path FROM repro_bundles WHERE bundle_id NOT IN (SELECT bundle_id FROM bundle_signatures)",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make(map[string]string)
+	for rows.Next() {
+		var bundleID, storagePath string
+		if err := rows.Scan(&bundleID, &storagePath); err != nil {
+			return nil, err
+		}
+		paths[bundleID] = storagePath
+	}
+	return paths, rows.Err()
+}
+
+// GetBundleSignature returns a bundle's signature and issue, or nil and ""
+// if it has none.
+func (db *DB) GetBundleSignature(bundleID string) (*models.CrashSignature, string, error) {
+	var sig models.CrashSignature
+	var issueID sql.NullString
+	err := db.conn.QueryRow(`
+		SELECT signature, source, signature_text, title, issue_id
+		FROM bundle_signatures WHERE bundle_id = ? AND signature != ''`, bundleID,
+	).Scan(&sig.Signature, &sig.Source, &sig.Text, &sig.Title, &issueID)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &sig, issueID.String, nil
+}
+
+// ListIssues returns issues with at least one member bundle.
+func (db *DB) ListIssues(query *models.IssueListQuery) (*models.IssueListResult, error) {
+	var conditions []string
+	var args []interface{}
+
+	if query.BuildID != "" {
+		conditions = append(conditions, "i.issue_id IN (SELECT issue_id FROM "+issueMembers+" WHERE build_id = ?)")
+		args = append(args, query.BuildID)
+	}
+	if query.Platform != "" {
+		conditions = append(conditions, "i.issue_id IN (SELECT issue_id FROM "+issueMembers+" WHERE platform = ?)")
+		args = append(args, query.Platform)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	groupClause := "GROUP BY i.issue_id HAVING COUNT(m.bundle_id) > 0"
+
+	var orderBy string
+	switch query.Sort {
+	case "", "last_seen":
+		orderBy = "MAX(m.created_at) DESC, i.id DESC"
+	case "first_seen":
+		orderBy = "MIN(m.created_at) DESC, i.id DESC"
+	case "count":
+		orderBy = "COUNT(m.bundle_id) DESC, i.id DESC"
+	default:
+		return nil, fmt.Errorf("unknown sort: %s", query.Sort)
+	}
+
+	var total int
+	countSQL := fmt.Sprintf(`
+		SELECT COUNT(*) FROM (
+			SELECT i.issue_id FROM issues i LEFT JOIN %s m ON m.issue_id = i.issue_id
+			%s %s
+		)`, issueMembers, whereClause, groupClause)
+	if err := db.conn.QueryRow(countSQL, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count issues: %w", err)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	querySQL := fmt.Sprintf("%s %s %s ORDER BY %s LIMIT ? OFFSET ?", issueSelect, whereClause, groupClause, orderBy)
+	args = append(args, limit, query.Offset)
+
+	rows, err := db.conn.Query(querySQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query issues: %w", err)
+	}
+	defer rows.Close()
+
+	issues := make([]models.Issue, 0)
+	for rows.Next() {
+		issue, err := scanIssue(rows)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, *issue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate issues: %w", err)
+	}
+
+	return &models.IssueListResult{
+		Issues: issues,
+		Total:  total,
+		Limit:  limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// GetIssue returns an issue with its signatures, or nil if it does not
+// exist.
+func (db *DB) GetIssue(issueID string) (*models.Issue, error) {
+	rows, err := db.conn.Query(issueSelect+" WHERE i.issue_id = ? GROUP BY i.issue_id", issueID)
+	if err != nil {
+		return nil, fmt.Errorf("query issue: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	issue, err := scanIssue(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	sigRows, err := db.conn.Query(`
+		SELECT signature, source, signature_text, title
+		FROM issue_signatures WHERE issue_id = ?
+		ORDER BY created_at, signature`, issueID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query signatures: %w", err)
+	}
+	defer sigRows.Close()
+
+	for sigRows.Next() {
+		var sig models.CrashSignature
+		if err := sigRows.Scan(&sig.Signature, &sig.Source, &sig.Text, &sig.Title); err != nil {
+			return nil, err
+		}
+		issue.Signatures = append(issue.Signatures, sig)
+	}
+	return issue, sigRows.Err()
+}
+
+func scanIssue(rows *sql.Rows) (*models.Issue, error) {
+	var issue models.Issue
+	var createdAt string
+	var firstSeen, lastSeen, firstBuild, lastBuild sql.NullString
+	var platforms, maps sql.NullString
+
+	err := rows.Scan(
+		&issue.ID,
+		&issue.IssueID,
+		&issue.Title,
+		&createdAt,
+		&issue.BundleCount,
+		&firstSeen,
+		&lastSeen,
+		&platforms,
+		&maps,
+		&firstBuild,
+		&lastBuild,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("scan issue: %w", err)
+	}
+
+	issue.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	issue.FirstSeen = parseNullTime(firstSeen)
+	issue.LastSeen = parseNullTime(lastSeen)
+	issue.FirstSeenBuild = firstBuild.String
+	issue.LastSeenBuild = lastBuild.String
+
+	issue.Platforms = make([]string, 0)
+	issue.Maps = make([]string, 0)
+	if platforms.Valid {
+		json.Unmarshal([]byte(platforms.String), &issue.Platforms)
+	}
+	if maps.Valid {
+		json.Unmarshal([]byte(maps.String), &issue.Maps)
+	}
+	return &issue, nil
+}
+
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// MergeIssues moves the signatures and bundles of the source issues into
+// the target and deletes the sources. Bundles ingested later with any of
+// the merged signatures join the target.
+func (db *DB) MergeIssues(targetID string, sourceIDs []string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range append([]string{targetID}, sourceIDs...) {
+		if err := requireIssue(tx, id); err != nil {
+			return err
+		}
+	}
+
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE issue_signatures SET issue_id = ? WHERE issue_id = ?", targetID, sourceID); err != nil {
+			return fmt.Errorf("move signatures of %s: %w", sourceID, err)
+		}
+		if _, err := tx.Exec("UPDATE bundle_signatures SET issue_id = ? WHERE issue_id = ?", targetID, sourceID); err != nil {
+			return fmt.Errorf("move bundles of %s: %w", sourceID, err)
+		}
+		if _, err := tx.Exec("DELETE FROM issues WHERE issue_id = ?", sourceID); err != nil {
+			return fmt.Errorf("delete %s: %w", sourceID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SplitIssue moves part of an issue into a new issue and returns its ID.
+// Each signature moves together with the issue's bundles that have it, and
+// later bundles with it join the new issue. Each bundle moves on its own;
+// later bundles with its signature still join the old issue. The old issue
+// is deleted if nothing is left in it.
+func (db *DB) SplitIssue(issueID string, signatures, bundleIDs []string, title string) (string, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireIssue(tx, issueID); err != nil {
+		return "", err
+	}
+
+	// Check membership first, taking the default title from the first
+	// signature or bundle moved
+	var titles []string
+	for _, sig := range signatures {
+		var sigTitle string
+		err := tx.QueryRow(
+			"SELECT title FROM issue_signatures WHERE signature = ? AND issue_id = ?", sig, issueID,
+		).Scan(&sigTitle)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("signature %s: %w", sig, ErrNotIssueMember)
+		}
+		if err != nil {
+			return "", err
+		}
+		titles = append(titles, sigTitle)
+	}
+	for _, bundleID := range bundleIDs {
+		var bundleTitle string
+		err := tx.QueryRow(
+			"SELECT title FROM bundle_signatures WHERE bundle_id = ? AND issue_id = ?", bundleID, issueID,
+		).Scan(&bundleTitle)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("bundle %s: %w", bundleID, ErrNotIssueMember)
+		}
+		if err != nil {
+			return "", err
+		}
+		titles = append(titles, bundleTitle)
+	}
+	if title == "" && len(titles) > 0 {
+		title = titles[0]
+	}
+
+	newID := "iss_" + ids.Generate(8)
+	if _, err := tx.Exec("INSERT INTO issues (issue_id, title) VALUES (?, ?)", newID, title); err != nil {
+		return "", fmt.Errorf("insert issue: %w", err)
+	}
+
+	for _, sig := range signatures {
+		if _, err := tx.Exec("UPDATE issue_signatures SET issue_id = ? WHERE signature = ?", newID, sig); err != nil {
+			return "", fmt.Errorf("move signature %s: %w", sig, err)
+		}
+		_, err := tx.Exec(
+			"UPDATE bundle_signatures SET issue_id = ? WHERE signature = ? AND issue_id = ?", newID, sig, issueID,
+		)
+		if err != nil {
+			return "", fmt.Errorf("move bundles with signature %s: %w", sig, err)
+		}
+	}
+	for _, bundleID := range bundleIDs {
+		if _, err := tx.Exec("UPDATE bundle_signatures SET issue_id = ? WHERE bundle_id = ?", newID, bundleID); err != nil {
+			return "", fmt.Errorf("move bundle %s: %w", bundleID, err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM issues WHERE issue_id = ?
+		  AND NOT EXISTS (SELECT 1 FROM issue_signatures WHERE issue_id = ?)
+		  AND NOT EXISTS (SELECT 1 FROM bundle_signatures WHERE issue_id = ?)`,
+		issueID, issueID, issueID,
+	)
+	if err != nil {
+		return "", fmt.Errorf("delete emptied issue: %w", err)
+	}
+
+	return newID, tx.Commit()
+}
+
+func requireIssue(tx *sql.Tx, issueID string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM issues WHERE issue_id = ?)", issueID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s: %w", issueID, ErrIssueNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// InsertIngestJob inserts a new queued ingest job.
+func (db *DB) InsertIngestJob(job *models.IngestJob) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO ingest_jobs (job_id, status, stage, staged_json)
+		VALUES (?, ?, ?, ?)`,
+		job.JobID, models.JobStatusQueued, models.JobStatusQueued, string(job.Staged),
+	)
+	return err
+}
+
+// ClaimIngestJob atomically marks the oldest queued job as running and
+// returns it. Returns nil if no job is queued.
+func (db *DB) ClaimIngestJob() (*models.IngestJob, error) {
+	var jobID, staged string
+	err := db.conn.QueryRow(`
+		UPDATE ingest_jobs
+		SET status = 'running', stage = 'running',
+		    updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		WHERE id = (
+			SELECT id FROM ingest_jobs WHERE status = 'queued'
+			ORDER BY id LIMIT 1
+		)
+		RETURNING job_id, staged_json`,
+	).Scan(&jobID, &staged)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", err)
+	}
+
+	return &models.IngestJob{
+		JobID:  jobID,
+		Status: models.JobStatusRunning,
+		Staged: json.RawMessage(staged),
+	}, nil
+}
+
+// UpdateIngestJobProgress records the current stage of a running job.
+func (db *DB) UpdateIngestJobProgress(jobID, stage string, progress float64) error {
+	_, err := db.conn.Exec(`
+		UPDATE ingest_jobs
+		SET stage = ?, progress = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		WHERE job_id = ?`,
+		stage, progress, jobID,
+	)
+	return err
+}
+
+// FinishIngestJob records the outcome of a job. Exactly one of result and
+// apiErr should be set.
+func (db *DB) FinishIngestJob(jobID string, result interface{}, apiErr *models.APIError) error {
+	status := models.JobStatusSucceeded
+	var resultJSON, errorJSON sql.NullString
+
+	if apiErr != nil {
+		status = models.JobStatusFailed
+		data, err := json.Marshal(apiErr)
+		if err != nil {
+			return fmt.Errorf("marshal error: %w", err)
+		}
+		errorJSON = sql.NullString{String: string(data), Valid: true}
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("marshal result: %w", err)
+		}
+		resultJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := db.conn.Exec(`
+		UPDATE ingest_jobs
+		SET status = ?, stage = 'done', progress = 1, result_json = ?, error_json = ?,
+		    updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		WHERE job_id = ?`,
+		status, resultJSON, errorJSON, jobID,
+	)
+	return err
+}
+
+// GetIngestJob retrieves a job by ID. Returns nil if it does not exist.
+func (db *DB) GetIngestJob(jobID string) (*models.IngestJob, error) {
+	var job models.IngestJob
+	var staged string
+	var resultJSON, errorJSON sql.NullString
+	var createdAt, updatedAt string
+
+	err := db.conn.QueryRow(`
+		SELECT id, job_id, status, stage, progress, staged_json,
+		       result_json, error_json, created_at, updated_at
+		FROM ingest_jobs WHERE job_id = ?`, jobID,
+	).Scan(
+		&job.ID,
+		&job.JobID,
+		&job.Status,
+		&job.Stage,
+		&job.Progress,
+		&staged,
+		&resultJSON,
+		&errorJSON,
+		&createdAt,
+		&updatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query job: %w", err)
+	}
+
+	job.Staged = json.RawMessage(staged)
+	if resultJSON.Valid {
+		job.Result = json.RawMessage(resultJSON.String)
+	}
+	if errorJSON.Valid {
+		job.Error = &models.APIError{}
+		if err := json.Unmarshal([]byte(errorJSON.String), job.Error); err != nil {
+			return nil, fmt.Errorf("parse job error: %w", err)
+		}
+	}
+	job.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	job.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return &job, nil
+}
+
+// CountIngestJobs counts jobs with the given status.
+func (db *DB) CountIngestJobs(status string) (int, error) {
+	var count int
+	err := db.conn.QueryRow(
+		"SELECT COUNT(*) FROM ingest_jobs WHERE status = ?", status,
+	).Scan(&count)
+	return count, err
+}
+
+// ListActiveIngestJobIDs returns the IDs of all queued and running jobs.
+func (db *DB) ListActiveIngestJobIDs() (map[string]bool, error) {
+	rows, err := db.conn.Query(
+		"SELECT job_id FROM ingest_jobs WHERE status IN ('queued', 'running')",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// RequeueRunningIngestJobs puts jobs interrupted by a shutdown back in the queue.
+func (db *DB) RequeueRunningIngestJobs() (int, error) {
+	res, err := db.conn.Exec(`
+		UPDATE ingest_jobs
+		SET status = 'queued', stage = 'queued', progress = 0,
+		    updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		WHERE status = 'running'`)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// PruneIngestJobs deletes finished jobs last updated before cutoff.
+func (db *DB) PruneIngestJobs(cutoff time.Time) (int, error) {
+	res, err := db.conn.Exec(`
+		DELETE FROM ingest_jobs
+		WHERE status IN ('succeeded', 'failed') AND updated_at < ?`,
+		cutoff.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migration upgrades a database to version. The embedded schema already
+// creates fresh databases in their latest shape, so every step must be a
+// no-op when its change is already present.
+type migration struct {
+	version int
+	apply   func(tx *sql.Tx) error
+}
+
+// migrations are applied in order to databases below their version.
+var migrations = []migration{
+	{version: 2, apply: func(tx *sql.Tx) error {
+		return addColumn(tx, "artifacts", "undeclared", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{version: 3, apply: func(tx *sql.Tx) error {
+		// Filled in for existing bundles by ingest.Recover
+		if err := addColumn(tx, "repro_bundles", "canonical_hash", "TEXT"); err != nil {
+			return err
+		}
+		_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_bundles_canonical_hash ON repro_bundles(canonical_hash)")
+		return err
+	}},
+	{version: 4, apply: func(tx *sql.Tx) error {
+		return addColumn(tx, "artifacts", "role", "TEXT")
+	}},
+}
+
+// migrate applies every migration not yet recorded in schema_migrations.
+func (db *DB) migrate() error {
+	var current int
+	if err := db.conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := db.conn.Begin()
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", m.version, err)
+		}
+		if err := m.apply(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO schema_migrations (version) VALUES (?)", m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// addColumn adds a column unless the table already has it.
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// insertMinidump stores an analysis unless one is already stored for the
+// artifact.
+func insertMinidump(e execer, bundleID string, m *models.Minidump) error {
+	m.BundleID = bundleID
+	analysis, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshal analysis: %w", err)
+	}
+
+	var exceptionCode, exceptionName, crashModule string
+	if m.Exception != nil {
+		exceptionCode = m.Exception.Code
+		exceptionName = m.Exception.Name
+		crashModule = m.Exception.Module
+	}
+	var crashingThread sql.NullInt64
+	if m.CrashingThreadID != nil {
+		crashingThread = sql.NullInt64{Int64: int64(*m.CrashingThreadID), Valid: true}
+	}
+	var dumpTime sql.NullString
+	if m.DumpTime != nil {
+		dumpTime = sql.NullString{String: m.DumpTime.UTC().Format(time.RFC3339), Valid: true}
+	}
+
+	_, err = e.Exec(`
+		INSERT INTO minidumps (
+			artifact_id, bundle_id, source, exception_code, exception_name,
+			crash_module, crashing_thread_id, cpu_arch, os, os_version,
+			module_count, thread_count, dump_time, analysis_json, analyzed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (artifact_id) DO NOTHING`,
+		m.ArtifactID,
+		bundleID,
+		m.Source,
+		exceptionCode,
+		exceptionName,
+		crashModule,
+		crashingThread,
+		m.System.CPUArch,
+		m.System.OS,
+		m.System.OSVersion,
+		len(m.Modules),
+		len(m.Threads),
+		dumpTime,
+		string(analysis),
+		m.AnalyzedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// InsertMinidump stores the analysis of a crash_dump artifact made after
+// its bundle was ingested. An analysis already stored for the artifact is
+// kept.
+func (db *DB) InsertMinidump(bundleID string, m *models.Minidump) error {
+	return insertMinidump(db.conn, bundleID, m)
+}
+
+// GetMinidumps returns the stored minidump analyses of a bundle, ordered by
+// source.
+func (db *DB) GetMinidumps(bundleID string) ([]models.Minidump, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, analysis_json FROM minidumps WHERE bundle_id = ?
+		ORDER BY source`, bundleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dumps []models.Minidump
+	for rows.Next() {
+		var m models.Minidump
+		var analysis string
+		if err := rows.Scan(&m.ID, &analysis); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(analysis), &m); err != nil {
+			return nil, fmt.Errorf("parse minidump analysis: %w", err)
+		}
+		dumps = append(dumps, m)
+	}
+
+	return dumps, rows.Err()
+}
+
+// GetMinidump returns the stored analysis of an artifact, or nil if there
+// is none.
+func (db *DB) GetMinidump(artifactID string) (*models.Minidump, error) {
+	var m models.Minidump
+	var analysis string
+	err := db.conn.QueryRow(
+		"SELECT id, analysis_json FROM minidumps WHERE artifact_id = ?", artifactID,
+	).Scan(&m.ID, &analysis)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(analysis), &m); err != nil {
+		return nil, fmt.Errorf("parse minidump analysis: %w", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// ListRetentionCandidates returns every bundle with the fields retention
+// rules look at, and its tags, newest first.
+func (db *DB) ListRetentionCandidates() ([]models.ReproBundle, error) {
+	rows, err := db.conn.Query(`
+		SELECT bundle_id, build_id, platform, size_bytes, storage_path, created_at
+		FROM repro_bundles
+		ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bundles []models.ReproBundle
+	index := make(map[string]int)
+	for rows.Next() {
+		var b models.ReproBundle
+		var createdAt string
+		if err := rows.Scan(&b.BundleID, &b.BuildID, &b.Platform, &b.SizeBytes, &b.StoragePath, &createdAt); err != nil {
+			return nil, err
+		}
+		b.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		index[b.BundleID] = len(bundles)
+		bundles = append(bundles, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tagRows, err := db.conn.Query("SELECT bundle_id, tag FROM tags ORDER BY tag")
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var bundleID, tag string
+		if err := tagRows.Scan(&bundleID, &tag); err != nil {
+			return nil, err
+		}
+		if n, ok := index[bundleID]; ok {
+			bundles[n].Tags = append(bundles[n].Tags, tag)
+		}
+	}
+	return bundles, tagRows.Err()
+}
+
+// ErrBundlePinned is returned by DeleteBundle for a bundle that was given a
+// pinned tag after it was selected for deletion.
+var ErrBundlePinned = errors.New("bundle is pinned")
+
+// DeleteBundle deletes the bundle named by entry together with its
+// artifacts, tags, notes and crash data, and records entry in
+// retention_audit in the same transaction. It returns false if the bundle
+// no longer exists, and ErrBundlePinned if it now carries one of
+// pinnedTags. The bundle directory is left to the caller.
+func (db *DB) DeleteBundle(entry *models.RetentionAuditEntry, pinnedTags []string) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The tags are checked again here: one added since the candidates were
+	// listed must still protect the bundle
+	query := "DELETE FROM repro_bundles WHERE bundle_id = ?"
+	args := []any{entry.BundleID}
+	if len(pinnedTags) > 0 {
+		query += `
+			AND NOT EXISTS (
+				SELECT 1 FROM tags t
+				WHERE t.bundle_id = repro_bundles.bundle_id AND t.tag IN (?` + strings.Repeat(", ?", len(pinnedTags)-1) + `)
+			)`
+		for _, tag := range pinnedTags {
+			args = append(args, tag)
+		}
+	}
+
+	// Related rows go with it through ON DELETE CASCADE
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("delete bundle: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM repro_bundles WHERE bundle_id = ?)", entry.BundleID).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("check bundle: %w", err)
+		}
+		if exists {
+			return false, ErrBundlePinned
+		}
+		return false, nil
+	}
+
+	var deletedAt string
+	err = tx.QueryRow(`
+		INSERT INTO retention_audit (bundle_id, build_id, platform, size_bytes, storage_path, reason, bundle_created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING deleted_at`,
+		entry.BundleID, entry.BuildID, entry.Platform, entry.SizeBytes, entry.StoragePath, entry.Reason,
+		entry.BundleCreatedAt.UTC().Format(time.RFC3339),
+	).Scan(&deletedAt)
+	if err != nil {
+		return false, fmt.Errorf("insert audit record: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+
+	t, _ := time.Parse(time.RFC3339, deletedAt)
+	entry.DeletedAt = &t
+	return true, nil
+}
+
+// ListRetentionAudit returns the most recent retention deletions, newest
+// first.
+func (db *DB) ListRetentionAudit(limit int) ([]models.RetentionAuditEntry, error) {
+	rows, err := db.conn.Query(`
+		SELECT bundle_id, build_id, platform, size_bytes, storage_path, reason, bundle_created_at, deleted_at
+		FROM retention_audit
+		ORDER BY id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.RetentionAuditEntry
+	for rows.Next() {
+		var e models.RetentionAuditEntry
+		var createdAt, deletedAt string
+		if err := rows.Scan(&e.BundleID, &e.BuildID, &e.Platform, &e.SizeBytes, &e.StoragePath, &e.Reason, &createdAt, &deletedAt); err != nil {
+			return nil, err
+		}
+		e.BundleCreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		t, _ := time.Parse(time.RFC3339, deletedAt)
+		e.DeletedAt = &t
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
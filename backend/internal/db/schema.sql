@@ -11,6 +11,7 @@ CREATE TABLE IF NOT EXISTS repro_bundles (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,
     bundle_id       TEXT NOT NULL UNIQUE,
     content_hash    TEXT NOT NULL UNIQUE,
+    canonical_hash  TEXT,
     schema_version  TEXT NOT NULL,
     build_id        TEXT NOT NULL,
     map_name        TEXT,
@@ -47,6 +48,8 @@ CREATE TABLE IF NOT EXISTS artifacts (
     size_bytes      INTEGER NOT NULL DEFAULT 0,
     storage_path    TEXT NOT NULL,
     checksum        TEXT,
+    undeclared      INTEGER NOT NULL DEFAULT 0,
+    role            TEXT,
     created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
     
     FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
@@ -97,6 +100,171 @@ CREATE TABLE IF NOT EXISTS qa_notes (
 CREATE INDEX IF NOT EXISTS idx_notes_bundle_id ON qa_notes(bundle_id);
 CREATE INDEX IF NOT EXISTS idx_notes_author ON qa_notes(author);
 
+--------------------------------------------------------------------------------
+-- bundle_validations: Consistency check results recorded at ingest
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS bundle_validations (
+    bundle_id       TEXT PRIMARY KEY,
+    status          TEXT NOT NULL,
+    error_count     INTEGER NOT NULL DEFAULT 0,
+    warning_count   INTEGER NOT NULL DEFAULT 0,
+    result_json     TEXT NOT NULL,
+    validated_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    
+    CHECK (status IN ('passed', 'failed', 'quarantined'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_validations_status ON bundle_validations(status);
+
+CREATE TABLE IF NOT EXISTS crashes (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    crash_id        TEXT NOT NULL UNIQUE,
+    bundle_id       TEXT NOT NULL,
+    source          TEXT NOT NULL,
+    crash_type      TEXT NOT NULL DEFAULT '',
+    error_message   TEXT NOT NULL DEFAULT '',
+    crash_guid      TEXT NOT NULL DEFAULT '',
+    execution_guid  TEXT NOT NULL DEFAULT '',
+    engine_version  TEXT NOT NULL DEFAULT '',
+    build_version   TEXT NOT NULL DEFAULT '',
+    build_config    TEXT NOT NULL DEFAULT '',
+    platform        TEXT NOT NULL DEFAULT '',
+    game_name       TEXT NOT NULL DEFAULT '',
+    callstack_json  TEXT NOT NULL DEFAULT '[]',
+    modules_json    TEXT NOT NULL DEFAULT '[]',
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    UNIQUE (bundle_id, source),
+    
+    CHECK (crash_id LIKE 'crash_%')
+);
+
+CREATE INDEX IF NOT EXISTS idx_crashes_bundle_id ON crashes(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_crashes_crash_guid ON crashes(crash_guid);
+
+--------------------------------------------------------------------------------
+-- minidumps: Analyses of crash_dump artifacts
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS minidumps (
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    artifact_id         TEXT NOT NULL UNIQUE,
+    bundle_id           TEXT NOT NULL,
+    source              TEXT NOT NULL,
+    exception_code      TEXT NOT NULL DEFAULT '',
+    exception_name      TEXT NOT NULL DEFAULT '',
+    crash_module        TEXT NOT NULL DEFAULT '',
+    crashing_thread_id  INTEGER,
+    cpu_arch            TEXT NOT NULL DEFAULT '',
+    os                  TEXT NOT NULL DEFAULT '',
+    os_version          TEXT NOT NULL DEFAULT '',
+    module_count        INTEGER NOT NULL DEFAULT 0,
+    thread_count        INTEGER NOT NULL DEFAULT 0,
+    dump_time           TEXT,
+    analysis_json       TEXT NOT NULL,
+    analyzed_at         TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    FOREIGN KEY (artifact_id) REFERENCES artifacts(artifact_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_minidumps_bundle_id ON minidumps(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_minidumps_exception_code ON minidumps(exception_code);
+CREATE INDEX IF NOT EXISTS idx_minidumps_crash_module ON minidumps(crash_module);
+
+--------------------------------------------------------------------------------
+-- issues: Groups of bundles with the same crash signature
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS issues (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    issue_id        TEXT NOT NULL UNIQUE,
+    title           TEXT NOT NULL DEFAULT '',
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    CHECK (issue_id LIKE 'iss_%')
+);
+
+CREATE TABLE IF NOT EXISTS issue_signatures (
+    signature       TEXT PRIMARY KEY,
+    issue_id        TEXT NOT NULL,
+    source          TEXT NOT NULL,
+    signature_text  TEXT NOT NULL,
+    title           TEXT NOT NULL DEFAULT '',
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (issue_id) REFERENCES issues(issue_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_issue_signatures_issue_id ON issue_signatures(issue_id);
+
+CREATE TABLE IF NOT EXISTS bundle_signatures (
+    bundle_id       TEXT PRIMARY KEY,
+    signature       TEXT NOT NULL DEFAULT '',
+    source          TEXT NOT NULL DEFAULT '',
+    signature_text  TEXT NOT NULL DEFAULT '',
+    title           TEXT NOT NULL DEFAULT '',
+    issue_id        TEXT,
+    computed_at     TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    FOREIGN KEY (issue_id) REFERENCES issues(issue_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_signatures_issue_id ON bundle_signatures(issue_id);
+CREATE INDEX IF NOT EXISTS idx_bundle_signatures_signature ON bundle_signatures(signature);
+
+--------------------------------------------------------------------------------
+-- ingest_jobs: Asynchronous ingestion jobs
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS ingest_jobs (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id          TEXT NOT NULL UNIQUE,
+    status          TEXT NOT NULL DEFAULT 'queued',
+    stage           TEXT NOT NULL DEFAULT 'queued',
+    progress        REAL NOT NULL DEFAULT 0,
+    staged_json     TEXT NOT NULL,
+    result_json     TEXT,
+    error_json      TEXT,
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    CHECK (job_id LIKE 'job_%'),
+    CHECK (status IN ('queued', 'running', 'succeeded', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_jobs_status ON ingest_jobs(status, id);
+
+--------------------------------------------------------------------------------
+-- retention_audit: Bundles deleted by retention garbage collection
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS retention_audit (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    bundle_id       TEXT NOT NULL,
+    build_id        TEXT NOT NULL,
+    platform        TEXT NOT NULL,
+    size_bytes      INTEGER NOT NULL DEFAULT 0,
+    storage_path    TEXT NOT NULL DEFAULT '',
+    reason          TEXT NOT NULL,
+    bundle_created_at TEXT NOT NULL,
+    deleted_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_audit_bundle_id ON retention_audit(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_retention_audit_deleted_at ON retention_audit(deleted_at DESC);
+
+--------------------------------------------------------------------------------
+-- bundle_damage: Bundles whose stored files fsck found missing or altered
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS bundle_damage (
+    bundle_id       TEXT PRIMARY KEY,
+    problems_json   TEXT NOT NULL,
+    detected_at     TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE
+);
+
 --------------------------------------------------------------------------------
 -- schema_migrations: Track applied migrations
 --------------------------------------------------------------------------------
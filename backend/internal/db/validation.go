@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// validationStatusColumn selects a bundle's validation status from
+// repro_bundles without a join, so existing WHERE clauses stay unambiguous.
+const validationStatusColumn = `COALESCE((SELECT v.status FROM bundle_validations v
+		WHERE v.bundle_id = repro_bundles.bundle_id), '')`
+
+func insertValidation(e execer, bundleID string, v *models.BundleValidation) error {
+	_, err := e.Exec(`
+		INSERT INTO bundle_validations (bundle_id, status, error_count, warning_count, result_json)
+		VALUES (?, ?, ?, ?, ?)`,
+		bundleID, v.Status, v.ErrorCount, v.WarningCount, string(v.Result),
+	)
+	return err
+}
+
+// GetBundleValidation returns the stored validation result for a bundle,
+// or nil if none was recorded.
+func (db *DB) GetBundleValidation(bundleID string) (*models.BundleValidation, error) {
+	v := &models.BundleValidation{}
+	var result, validatedAt string
+
+	err := db.conn.QueryRow(`
+		SELECT bundle_id, status, error_count, warning_count, result_json, validated_at
+		FROM bundle_validations WHERE bundle_id = ?`, bundleID,
+	).Scan(
+		&v.BundleID,
+		&v.Status,
+		&v.ErrorCount,
+		&v.WarningCount,
+		&result,
+		&validatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query validation: %w", err)
+	}
+
+	v.Valid = v.ErrorCount == 0
+	v.Result = json.RawMessage(result)
+	v.ValidatedAt, _ = time.Parse(time.RFC3339, validatedAt)
+
+	return v, nil
+}
@@ -0,0 +1,270 @@
+// Package fingerprint computes crash signatures, so that bundles showing the
+// same crash can be grouped into one issue.
+//
+// A signature is the hash of a normalized description of the crash. The
+// description is taken from the first source that has one: the callstack of
+// a parsed crash context, the exception of a minidump, or the fatal line of
+// a log. Normalization strips what differs between occurrences of the same
+// crash: addresses, line numbers, numbers, GUIDs and build-specific paths.
+package fingerprint
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// Signature sources, in order of preference.
+const (
+	SourceCrashContext = "crash_context"
+	SourceMinidump     = "minidump"
+	SourceLog          = "log"
+)
+
+// Version is part of every signature text. Changing the normalization
+// changes signatures, so bump it when doing so.
+const Version = "v1"
+
+// MaxFrames is the number of callstack frames a signature is built from.
+const MaxFrames = 5
+
+// maxTitleLen caps the length of issue titles.
+const maxTitleLen = 200
+
+// FromCrashes returns the signature of the first crash context that has a
+// callstack or an error message, or nil.
+func FromCrashes(crashes []models.Crash) *models.CrashSignature {
+	for _, c := range crashes {
+		frames := NormalizeFrames(c.Callstack)
+		message := NormalizeLine(c.ErrorMessage)
+		if len(frames) == 0 && message == "" {
+			continue
+		}
+
+		parts := append([]string{c.CrashType, message}, frames...)
+		title := message
+		if title == "" {
+			title = c.CrashType
+		}
+		if len(frames) > 0 {
+			title += " in " + frames[0]
+		}
+		return newSignature(SourceCrashContext, parts, title)
+	}
+	return nil
+}
+
+// FromMinidumps returns the signature of the first minidump with an
+// exception, or nil. Minidump frames are module offsets that change with
+// every build, so only the exception and the module it occurred in are
+// used.
+func FromMinidumps(dumps []models.Minidump) *models.CrashSignature {
+	for _, m := range dumps {
+		e := m.Exception
+		if e == nil {
+			continue
+		}
+
+		name := e.Name
+		if name == "" {
+			name = e.Code
+		}
+		module := NormalizeModule(e.Module)
+		parts := []string{name, e.AccessType, module}
+
+		title := name
+		if e.AccessType != "" {
+			title += " (" + e.AccessType + ")"
+		}
+		if module != "" {
+			title += " in " + e.Module
+		}
+		return newSignature(SourceMinidump, parts, title)
+	}
+	return nil
+}
+
+// FromLogLine returns the signature of a fatal log line, or nil if it is
+// empty.
+func FromLogLine(line string) *models.CrashSignature {
+	normalized := NormalizeLine(stripLogPrefix(line))
+	if normalized == "" {
+		return nil
+	}
+	return newSignature(SourceLog, []string{normalized}, normalized)
+}
+
+func newSignature(source string, parts []string, title string) *models.CrashSignature {
+	text := Version + "|" + source + "|" + strings.Join(parts, "|")
+	sum := sha256.Sum256([]byte(text))
+	if len(title) > maxTitleLen {
+		title = title[:maxTitleLen-3] + "..."
+	}
+	return &models.CrashSignature{
+		Signature: hex.EncodeToString(sum[:8]),
+		Source:    source,
+		Text:      text,
+		Title:     title,
+	}
+}
+
+// fatalMarkers identify the log line that explains a crash, in the order
+// Unreal writes them.
+var fatalMarkers = []string{
+	"Assertion failed:",
+	"Fatal error:",
+	"Unhandled Exception:",
+	"Ensure condition failed:",
+}
+
+// FatalLogLine returns the first line of an Unreal log that reports a crash,
+// assert or ensure, or "" if there is none.
+func FatalLogLine(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, marker := range fatalMarkers {
+			if i := strings.Index(line, marker); i >= 0 {
+				return strings.TrimSpace(line[i:])
+			}
+		}
+	}
+	return ""
+}
+
+var (
+	logPrefixPattern = regexp.MustCompile(`^\[[^\]]*\]\[\s*\d+\]`)
+	categoryPattern  = regexp.MustCompile(`^Log\w+: (?:(?:Error|Warning|Fatal|Display): )?`)
+	pathPattern      = regexp.MustCompile(`(?:[A-Za-z]:)?[\\/](?:[^\\/\s\[\]:]+[\\/])+([^\\/\s\[\]:]+)`)
+	lineTagPattern   = regexp.MustCompile(`\[Line:\s*\d+\]`)
+	fileLinePattern  = regexp.MustCompile(`(\.\w+):\d+\b`)
+	guidPattern      = regexp.MustCompile(`\b[0-9A-Fa-f]{8}-?[0-9A-Fa-f]{4}-?[0-9A-Fa-f]{4}-?[0-9A-Fa-f]{4}-?[0-9A-Fa-f]{12}\b`)
+	hexPattern       = regexp.MustCompile(`\b0[xX][0-9A-Fa-f]+\b`)
+	numberPattern    = regexp.MustCompile(`\b\d+(?:\.\d+)*\b`)
+	spacePattern     = regexp.MustCompile(`\s+`)
+)
+
+func stripLogPrefix(line string) string {
+	line = logPrefixPattern.ReplaceAllString(strings.TrimSpace(line), "")
+	return categoryPattern.ReplaceAllString(line, "")
+}
+
+// NormalizeLine normalizes an error message or log line: paths are reduced
+// to their file name, and line numbers, addresses, GUIDs and other numbers
+// are replaced so that they do not split one crash into several signatures.
+func NormalizeLine(s string) string {
+	s = pathPattern.ReplaceAllString(s, "$1")
+	s = lineTagPattern.ReplaceAllString(s, "[Line: ?]")
+	s = fileLinePattern.ReplaceAllString(s, "$1")
+	s = guidPattern.ReplaceAllString(s, "?")
+	s = hexPattern.ReplaceAllString(s, "0x?")
+	s = numberPattern.ReplaceAllString(s, "?")
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
+
+// boilerplateFunctions are frames of the crash handling machinery rather
+// than of the crash itself. They are matched as prefixes of the function.
+var boilerplateFunctions = []string{
+	"FDebug::",
+	"FGenericPlatformMisc::",
+	"FWindowsPlatformMisc::",
+	"FUnixPlatformMisc::",
+	"FAndroidMisc::",
+	"FIOSPlatformMisc::",
+	"FMacPlatformMisc::",
+	"FPlatformMisc::",
+	"FWindowsErrorOutputDevice::",
+	"FOutputDevice",
+	"AssertFailedImplV",
+	"CheckVerifyFailedImpl",
+	"EnsureFailed",
+	"ReportAssert",
+	"ReportCrash",
+	"ReportEnsure",
+	"RaiseException",
+	"UE::Assert::",
+	"__scrt_",
+	"_CxxThrowException",
+	"abort",
+	"raise",
+}
+
+// systemModules are modules whose frames never identify a crash.
+var systemModules = map[string]bool{
+	"kernelbase":       true,
+	"kernel32":         true,
+	"ntdll":            true,
+	"ucrtbase":         true,
+	"vcruntime140":     true,
+	"libc":             true,
+	"libpthread":       true,
+	"libsystem_kernel": true,
+}
+
+var (
+	paramsPattern   = regexp.MustCompile(`\([^()]*\)`)
+	lambdaPattern   = regexp.MustCompile(`<lambda_[0-9a-fA-F]+>`)
+	platformPattern = regexp.MustCompile(`-(?:win64|win32|linux|linuxarm64|mac|android|ios)(?:-(?:debug|debuggame|development|test|shipping))?$`)
+)
+
+// NormalizeFrames returns the first MaxFrames frames of a callstack that
+// identify the crash, as "module!function" with parameters, lambda IDs and
+// build-specific module suffixes removed. Frames without a function and
+// frames of the crash handler or system libraries are skipped.
+func NormalizeFrames(frames []models.CrashFrame) []string {
+	out := make([]string, 0, MaxFrames)
+	for _, f := range frames {
+		if len(out) == MaxFrames {
+			break
+		}
+		function := strings.TrimSpace(f.Function)
+		module := NormalizeModule(f.Module)
+		if function == "" || systemModules[module] || isBoilerplate(function) {
+			continue
+		}
+
+		// Strip parameter lists, innermost first, so nested ones go too
+		for {
+			stripped := paramsPattern.ReplaceAllString(function, "")
+			if stripped == function {
+				break
+			}
+			function = stripped
+		}
+		function = lambdaPattern.ReplaceAllString(function, "<lambda>")
+		out = append(out, module+"!"+strings.TrimSpace(function))
+	}
+	return out
+}
+
+func isBoilerplate(function string) bool {
+	for _, prefix := range boilerplateFunctions {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeModule reduces a module path to a lower-case name without
+// extension or platform and configuration suffix, so that
+// UnrealEditor-Engine.dll and UnrealEditor-Engine-Win64-Shipping.dll match.
+func NormalizeModule(module string) string {
+	module = strings.ToLower(strings.TrimSpace(module))
+	if i := strings.LastIndexAny(module, `\/`); i >= 0 {
+		module = module[i+1:]
+	}
+	for _, ext := range []string{".dll", ".exe", ".so", ".dylib"} {
+		if i := strings.Index(module, ext); i > 0 {
+			module = module[:i]
+			break
+		}
+	}
+	return platformPattern.ReplaceAllString(module, "")
+}
@@ -0,0 +1,361 @@
+// Package fsck checks that the bundles recorded in the database match the
+// files in bundle storage.
+//
+// Every bundle row is compared with its directory:
+//
+//   - a bundle without any stored files is missing_bundle;
+//   - an artifact without its file is missing_file;
+//   - a file whose size or, with Options.Checksums, SHA-256 differs from
+//     its artifact row is size_mismatch or checksum_mismatch;
+//   - a file without an artifact row is unlisted_file.
+//
+// Bundle directories without a row are orphan_dir, and staging directories
+// of abandoned uploads are orphan_upload. Directories still carrying the
+// pending marker belong to an ingest in progress and are skipped.
+//
+// With Options.Repair, only safe fixes are made: unlisted files are
+// registered as undeclared artifacts, bundles with missing or altered files
+// are marked damaged (and unmarked once a check with checksums passes), and
+// abandoned uploads are removed. Orphan directories are never deleted, since
+// they may be all that is left of a lost database.
+package fsck
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// staleUploadAge is how long an upload without a resumable session may sit
+// in tmp/ before it counts as abandoned, as in the serve janitor.
+const staleUploadAge = time.Hour
+
+// Repairs recorded on a problem.
+const (
+	RepairRegistered    = "registered"
+	RepairMarkedDamaged = "marked_damaged"
+	RepairRemoved       = "removed"
+)
+
+// Options controls a check.
+type Options struct {
+	Checksums bool // Hash every file, not only compare sizes
+	Repair    bool // Apply safe fixes
+}
+
+// Report summarizes a check.
+type Report struct {
+	Checksums bool                      `json:"checksums"`
+	Repair    bool                      `json:"repair"`
+	Bundles   int                       `json:"bundles"`   // Bundles checked
+	Artifacts int                       `json:"artifacts"` // Artifact rows checked
+	Problems  []models.IntegrityProblem `json:"problems"`
+	Undamaged []string                  `json:"undamaged,omitempty"` // Bundles unmarked as damaged
+	Errors    []string                  `json:"errors,omitempty"`    // Checks or repairs that failed
+}
+
+// Unrepaired returns the number of problems left without a repair.
+func (r *Report) Unrepaired() int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Repair == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Checker compares a database with bundle storage.
+type Checker struct {
+	db      *db.DB
+	storage *storage.Storage
+	logger  *slog.Logger
+}
+
+// New creates a checker.
+func New(database *db.DB, store *storage.Storage) *Checker {
+	return &Checker{
+		db:      database,
+		storage: store,
+		logger:  slog.Default(),
+	}
+}
+
+// Check walks every bundle and staging directory and reports what does not
+// match. It is safe to run against a live server.
+func (c *Checker) Check(opts Options) (*Report, error) {
+	report := &Report{
+		Checksums: opts.Checksums,
+		Repair:    opts.Repair,
+		Problems:  []models.IntegrityProblem{},
+	}
+
+	// Listed before the rows, so a directory committed in between is
+	// either still pending or already has its row
+	dirs, err := c.storage.ListBundleDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := c.db.ListBundleStoragePaths()
+	if err != nil {
+		return nil, fmt.Errorf("list bundles: %w", err)
+	}
+
+	damaged, err := c.db.ListDamagedBundles()
+	if err != nil {
+		return nil, fmt.Errorf("list damaged bundles: %w", err)
+	}
+
+	referenced := make(map[string]bool, len(paths))
+	for bundleID, storagePath := range paths {
+		storagePath = filepath.ToSlash(storagePath)
+		referenced[storagePath] = true
+		if err := c.checkBundle(bundleID, storagePath, damaged[bundleID], opts, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", bundleID, err))
+		}
+	}
+
+	for _, dir := range dirs {
+		if referenced[dir] || c.storage.IsPending(dir) {
+			continue
+		}
+		report.Problems = append(report.Problems, models.IntegrityProblem{
+			Kind: models.ProblemOrphanDir,
+			Path: dir,
+		})
+	}
+
+	uploads, err := c.storage.StaleTempDirs(staleUploadAge)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range uploads {
+		problem := models.IntegrityProblem{
+			Kind: models.ProblemOrphanUpload,
+			Path: "tmp/" + filepath.Base(dir),
+		}
+		if opts.Repair {
+			if err := c.storage.RemoveTempDir(dir); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("remove %s: %v", problem.Path, err))
+			} else {
+				problem.Repair = RepairRemoved
+			}
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+
+	return report, nil
+}
+
+// checkBundle compares one bundle's artifact rows with its stored files.
+func (c *Checker) checkBundle(bundleID, storagePath string, wasDamaged bool, opts Options, report *Report) error {
+	report.Bundles++
+
+	artifacts, err := c.db.GetArtifacts(bundleID)
+	if err != nil {
+		return fmt.Errorf("get artifacts: %w", err)
+	}
+	report.Artifacts += len(artifacts)
+
+	var files []storage.FileInfo
+	if storagePath != "" {
+		if files, err = c.storage.ListBundleFiles(storagePath); err != nil {
+			return fmt.Errorf("list files: %w", err)
+		}
+	}
+
+	var damage, unlisted []models.IntegrityProblem
+	if len(files) == 0 {
+		if storagePath == "" {
+			storagePath = "bundles/" + storage.BundleDirName(bundleID)
+		}
+		damage = append(damage, models.IntegrityProblem{
+			Kind:     models.ProblemMissingBundle,
+			BundleID: bundleID,
+			Path:     storagePath,
+		})
+	} else {
+		stored := make(map[string]storage.FileInfo, len(files))
+		for _, f := range files {
+			stored[f.Name] = f
+		}
+
+		listed := make(map[string]bool, len(artifacts))
+		for _, a := range artifacts {
+			name := filepath.ToSlash(a.StoragePath)
+			listed[name] = true
+
+			problem, err := c.checkArtifact(storagePath, &a, stored, opts.Checksums)
+			if err != nil {
+				return err
+			}
+			if problem != nil {
+				damage = append(damage, *problem)
+			}
+		}
+
+		for _, f := range files {
+			if listed[f.Name] || f.Name == "manifest.json" || f.Name == storage.PendingMarker {
+				continue
+			}
+			unlisted = append(unlisted, models.IntegrityProblem{
+				Kind:     models.ProblemUnlistedFile,
+				BundleID: bundleID,
+				Path:     storage.ArtifactName(storagePath, f.Name),
+				Actual:   strconv.FormatInt(f.Size, 10),
+			})
+		}
+	}
+
+	if opts.Repair {
+		for n := range unlisted {
+			name := strings.TrimPrefix(unlisted[n].Path, storagePath+"/")
+			if err := c.register(bundleID, storagePath, name); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("register %s: %v", unlisted[n].Path, err))
+				continue
+			}
+			unlisted[n].Repair = RepairRegistered
+		}
+
+		switch {
+		case len(damage) > 0:
+			if err := c.db.MarkBundleDamaged(bundleID, damage); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("mark %s damaged: %v", bundleID, err))
+				break
+			}
+			for n := range damage {
+				damage[n].Repair = RepairMarkedDamaged
+			}
+		case wasDamaged && opts.Checksums:
+			// Only a full check can tell a bundle is intact again
+			if _, err := c.db.ClearBundleDamage(bundleID); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("unmark %s damaged: %v", bundleID, err))
+				break
+			}
+			report.Undamaged = append(report.Undamaged, bundleID)
+		}
+	}
+
+	report.Problems = append(report.Problems, damage...)
+	report.Problems = append(report.Problems, unlisted...)
+	return nil
+}
+
+// checkArtifact compares an artifact row with its stored file.
+func (c *Checker) checkArtifact(storagePath string, a *models.Artifact, stored map[string]storage.FileInfo, checksums bool) (*models.IntegrityProblem, error) {
+	name := filepath.ToSlash(a.StoragePath)
+	problem := &models.IntegrityProblem{
+		BundleID:   a.BundleID,
+		ArtifactID: a.ArtifactID,
+		Path:       storage.ArtifactName(storagePath, name),
+	}
+
+	f, ok := stored[name]
+	switch {
+	case !ok:
+		problem.Kind = models.ProblemMissingFile
+		return problem, nil
+	case f.Size != a.SizeBytes:
+		problem.Kind = models.ProblemSizeMismatch
+		problem.Expected = strconv.FormatInt(a.SizeBytes, 10)
+		problem.Actual = strconv.FormatInt(f.Size, 10)
+		return problem, nil
+	case !checksums || a.Checksum == "":
+		return nil, nil
+	}
+
+	checksum, err := c.hash(storagePath, name)
+	if err != nil {
+		return nil, fmt.Errorf("hash %s: %w", problem.Path, err)
+	}
+	if checksum != a.Checksum {
+		problem.Kind = models.ProblemChecksumMismatch
+		problem.Expected = a.Checksum
+		problem.Actual = checksum
+		return problem, nil
+	}
+	return nil, nil
+}
+
+// register records a file found in a bundle directory as an undeclared
+// artifact, as ingest does for files the manifest does not list.
+func (c *Checker) register(bundleID, storagePath, name string) error {
+	info, err := c.storage.StatArtifact(storagePath, name)
+	if err != nil {
+		return err
+	}
+	checksum, err := c.hash(storagePath, name)
+	if err != nil {
+		return err
+	}
+
+	lower := strings.ToLower(name)
+	return c.db.RegisterArtifact(&models.Artifact{
+		ArtifactID:   "art_" + ids.Generate(8),
+		BundleID:     bundleID,
+		Filename:     name,
+		ArtifactType: models.GuessArtifactType(lower),
+		MimeType:     models.GuessMimeType(lower),
+		SizeBytes:    info.Size,
+		StoragePath:  name,
+		Checksum:     checksum,
+		Undeclared:   true,
+	})
+}
+
+func (c *Checker) hash(storagePath, name string) (string, error) {
+	rc, err := c.storage.OpenArtifact(storagePath, name, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return storage.HashReader(rc)
+}
+
+// Run checks every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context, interval time.Duration, opts Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report, err := c.Check(opts)
+			if err != nil {
+				c.logger.Error("fsck run failed", "error", err)
+				continue
+			}
+			for _, p := range report.Problems {
+				c.logger.Warn("storage integrity problem",
+					"kind", p.Kind,
+					"bundle_id", p.BundleID,
+					"path", p.Path,
+					"repair", p.Repair,
+				)
+			}
+			for _, e := range report.Errors {
+				c.logger.Error("fsck check failed", "error", e)
+			}
+			if len(report.Problems) > 0 || len(report.Undamaged) > 0 {
+				c.logger.Info("fsck run finished",
+					"bundles", report.Bundles,
+					"problems", len(report.Problems),
+					"unrepaired", report.Unrepaired(),
+					"undamaged", len(report.Undamaged),
+				)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
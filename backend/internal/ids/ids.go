@@ -0,0 +1,15 @@
+// Package ids generates the random hex IDs used for bundles, jobs, issues
+// and temporary directories.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Generate returns a random hex ID of the given length.
+func Generate(length int) string {
+	bytes := make([]byte, (length+1)/2)
+	rand.Read(bytes) // Never fails; a broken entropy source aborts the program
+	return hex.EncodeToString(bytes)[:length]
+}
@@ -0,0 +1,367 @@
+package ingest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// Supported archive formats.
+const (
+	FormatZip    = "zip"
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// archiveExtensions are the file name suffixes recognized by IsArchiveName.
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.zst", ".tzst"}
+
+// IsArchiveName reports whether a file name has a supported archive extension.
+// Used when scanning directories; ingestion itself detects the format from
+// the file contents.
+func IsArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectArchiveFormat identifies an archive from its leading bytes.
+// Returns "" if the format is not recognized.
+func detectArchiveFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatTarGz
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return FormatTarZst
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return FormatTar
+	}
+	return ""
+}
+
+// archiveErrorCode returns the error code for a failure in the given format.
+func archiveErrorCode(format string) string {
+	switch format {
+	case FormatTar:
+		return models.ErrCodeInvalidTar
+	case FormatTarGz:
+		return models.ErrCodeInvalidTarGz
+	case FormatTarZst:
+		return models.ErrCodeInvalidTarZst
+	default:
+		return models.ErrCodeInvalidZip
+	}
+}
+
+// Default extraction limits.
+const (
+	DefaultMaxEntries      = 10000
+	DefaultMaxExtractBytes = int64(16 << 30) // 16 GB
+	DefaultMaxRatio        = 200.0
+	DefaultMaxDepth        = 16
+	ratioCheckFloor        = int64(1 << 20) // Small archives may legitimately exceed the ratio
+)
+
+// ExtractLimits bounds what an archive may expand into. A zero value
+// disables the corresponding limit.
+type ExtractLimits struct {
+	MaxEntries int     // Files and directories
+	MaxBytes   int64   // Total uncompressed bytes
+	MaxRatio   float64 // Uncompressed bytes per archive byte
+	MaxDepth   int     // Path components per entry
+}
+
+// DefaultExtractLimits returns the limits used unless configured otherwise.
+func DefaultExtractLimits() ExtractLimits {
+	return ExtractLimits{
+		MaxEntries: DefaultMaxEntries,
+		MaxBytes:   DefaultMaxExtractBytes,
+		MaxRatio:   DefaultMaxRatio,
+		MaxDepth:   DefaultMaxDepth,
+	}
+}
+
+// SetExtractLimits sets the limits applied when extracting archives.
+func (i *Ingester) SetExtractLimits(limits ExtractLimits) {
+	i.extract = limits
+}
+
+// extractArchive detects the format of the archive at path and extracts it
+// into destDir. Errors are returned as an *models.APIError whose code names
+// the format that failed, or ARCHIVE_LIMIT_EXCEEDED / UNSAFE_ARCHIVE_ENTRY
+// for hostile content. The caller removes destDir on failure.
+func extractArchive(path, destDir string, limits ExtractLimits) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("open archive: %v", err),
+		}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("stat archive: %v", err),
+		}
+	}
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(f, header)
+	format := detectArchiveFormat(header[:n])
+	if format == "" {
+		return &models.APIError{
+			Code:    models.ErrCodeUnsupportedArchive,
+			Message: "unrecognized archive format; expected zip, tar, tar.gz or tar.zst",
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("seek archive: %v", err),
+		}
+	}
+
+	x := &extractor{
+		destDir:     destDir,
+		root:        filepath.Clean(destDir),
+		limits:      limits,
+		archiveSize: info.Size(),
+	}
+
+	switch format {
+	case FormatZip:
+		err = x.extractZip(f, info.Size())
+	case FormatTar:
+		err = x.extractTar(f)
+	case FormatTarGz:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(f); err == nil {
+			err = x.extractTar(gz)
+			gz.Close()
+		}
+	case FormatTarZst:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(f, zstd.WithDecoderConcurrency(1)); err == nil {
+			err = x.extractTar(zr)
+			zr.Close()
+		}
+	}
+
+	if err != nil {
+		// Limit and safety violations keep their own codes
+		var apiErr *models.APIError
+		if errors.As(err, &apiErr) {
+			return apiErr.WithDetails("format", format)
+		}
+		if fullErr := diskFull(err); fullErr != nil {
+			return fullErr
+		}
+		return (&models.APIError{
+			Code:    archiveErrorCode(format),
+			Message: fmt.Sprintf("failed to extract %s: %v", format, err),
+		}).WithDetails("format", format)
+	}
+	return nil
+}
+
+// extractor writes archive entries into destDir while enforcing limits.
+type extractor struct {
+	destDir     string
+	root        string
+	limits      ExtractLimits
+	archiveSize int64
+	entries     int
+	written     int64
+}
+
+// limitError reports which extraction limit was exceeded.
+func limitError(limit string, max interface{}) error {
+	return (&models.APIError{
+		Code:    models.ErrCodeArchiveLimitExceeded,
+		Message: fmt.Sprintf("archive exceeds %s limit of %v", limit, max),
+	}).WithDetails("limit", limit)
+}
+
+// unsafeEntry reports an entry that is never extracted.
+func unsafeEntry(name, reason string) error {
+	return (&models.APIError{
+		Code:    models.ErrCodeUnsafeArchiveEntry,
+		Message: fmt.Sprintf("unsafe archive entry %q: %s", name, reason),
+	}).WithDetails("entry", name)
+}
+
+// entryPath counts an entry against the limits and resolves its name inside
+// destDir, rejecting names that would escape it. The root itself ("./",
+// common in tarballs) is allowed.
+func (x *extractor) entryPath(name string) (string, error) {
+	x.entries++
+	if x.limits.MaxEntries > 0 && x.entries > x.limits.MaxEntries {
+		return "", limitError("max_entries", x.limits.MaxEntries)
+	}
+
+	// Archives made on Windows may separate with backslashes, which are
+	// ordinary name characters elsewhere
+	entry := filepath.FromSlash(strings.ReplaceAll(name, "\\", "/"))
+
+	// Security: prevent path traversal
+	destPath := filepath.Join(x.destDir, entry)
+	if destPath != x.root && !strings.HasPrefix(destPath, x.root+string(os.PathSeparator)) {
+		return "", unsafeEntry(name, "path escapes the bundle directory")
+	}
+
+	if x.limits.MaxDepth > 0 && destPath != x.root {
+		rel, _ := filepath.Rel(x.root, destPath)
+		if depth := strings.Count(rel, string(os.PathSeparator)) + 1; depth > x.limits.MaxDepth {
+			return "", limitError("max_depth", x.limits.MaxDepth)
+		}
+	}
+
+	return destPath, nil
+}
+
+// writeFile streams r into destPath, counting every byte against the size
+// and ratio limits. Declared entry sizes are not trusted.
+func (x *extractor) writeFile(destPath string, r io.Reader) error {
+	// Create parent directory
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("create dir for %s: %w", destPath, err)
+	}
+
+	// Archive permissions are ignored
+	outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", destPath, err)
+	}
+
+	_, err = io.Copy(outFile, &extractLimitReader{r: r, x: x})
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// extractLimitReader fails once the extractor's byte or ratio budget is spent.
+type extractLimitReader struct {
+	r io.Reader
+	x *extractor
+}
+
+func (l *extractLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	x := l.x
+	x.written += int64(n)
+
+	if x.limits.MaxBytes > 0 && x.written > x.limits.MaxBytes {
+		return n, limitError("max_bytes", x.limits.MaxBytes)
+	}
+	if x.limits.MaxRatio > 0 && x.written > ratioCheckFloor && x.archiveSize > 0 &&
+		float64(x.written)/float64(x.archiveSize) > x.limits.MaxRatio {
+		return n, limitError("max_ratio", x.limits.MaxRatio)
+	}
+	return n, err
+}
+
+// extractZip extracts a ZIP archive.
+func (x *extractor) extractZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("open zip: %w", err)
+	}
+
+	// Fail fast on the central directory before writing anything
+	if x.limits.MaxEntries > 0 && len(zr.File) > x.limits.MaxEntries {
+		return limitError("max_entries", x.limits.MaxEntries)
+	}
+
+	for _, f := range zr.File {
+		destPath, err := x.entryPath(f.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				return fmt.Errorf("create dir %s: %w", f.Name, err)
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			return unsafeEntry(f.Name, "symlinks and special files are not allowed")
+		}
+
+		// Extract file
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("open %s in zip: %w", f.Name, err)
+		}
+		err = x.writeFile(destPath, rc)
+		rc.Close()
+
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// extractTar extracts a tar stream.
+func (x *extractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+
+		// pax global headers carry metadata only (e.g. from git archive)
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		destPath, err := x.entryPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				return fmt.Errorf("create dir %s: %w", hdr.Name, err)
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			return unsafeEntry(hdr.Name, "symlinks and special files are not allowed")
+		}
+
+		if err := x.writeFile(destPath, tr); err != nil {
+			return fmt.Errorf("extract %s: %w", hdr.Name, err)
+		}
+	}
+}
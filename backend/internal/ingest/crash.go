@@ -0,0 +1,184 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/crash"
+	"github.com/unrealsolutions/bugit/internal/fingerprint"
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// parseCrashes parses every crash context file among a staged bundle's
+// artifacts. Crash data is supplementary: a file that cannot be parsed is
+// logged and skipped rather than failing the ingest.
+func parseCrashes(dir string, artifacts []*models.Artifact) []models.Crash {
+	var crashes []models.Crash
+	for _, a := range artifacts {
+		if !crash.IsContextFile(a.Filename) && a.Role != "crash_context" {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(a.StoragePath))
+		if size, err := storage.FileSize(path); err != nil || size > crash.MaxContextSize {
+			if err == nil {
+				slog.Warn("crash context too large to parse", "file", a.Filename, "size", size)
+			}
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		c, err := crash.ParseContext(data)
+		if err != nil {
+			slog.Warn("failed to parse crash context", "file", a.Filename, "error", err)
+			continue
+		}
+		c.CrashID = "crash_" + ids.Generate(8)
+		c.Source = a.Filename
+		crashes = append(crashes, *c)
+	}
+	return crashes
+}
+
+// parseMinidumps analyzes every crash_dump artifact of a staged bundle. As
+// with crash contexts, a dump that cannot be parsed is logged and skipped;
+// AnalyzeArtifact reports why when asked.
+func parseMinidumps(dir string, artifacts []*models.Artifact) []models.Minidump {
+	var dumps []models.Minidump
+	for _, a := range artifacts {
+		if a.ArtifactType != "crash_dump" {
+			continue
+		}
+
+		m, err := crash.ParseMinidumpFile(filepath.Join(dir, filepath.FromSlash(a.StoragePath)))
+		if err != nil {
+			slog.Warn("failed to parse minidump", "file", a.Filename, "error", err)
+			continue
+		}
+		setMinidumpSource(m, a)
+		dumps = append(dumps, *m)
+	}
+	return dumps
+}
+
+// AnalyzeArtifact returns the analysis of one of a bundle's artifacts. Only
+// crash_dump artifacts can be analyzed. The analysis made at ingest is
+// returned if there is one; otherwise the dump is parsed now and the result
+// stored, which covers bundles ingested before dumps were analyzed.
+func (i *Ingester) AnalyzeArtifact(bundle *models.ReproBundle, artifact *models.Artifact) (*models.Minidump, error) {
+	if artifact.ArtifactType != "crash_dump" {
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeAnalysisUnsupported,
+			Message: fmt.Sprintf("no analysis available for %s artifacts", artifact.ArtifactType),
+		}).WithDetails("supported_types", []string{"crash_dump"})
+	}
+
+	m, err := i.db.GetMinidump(artifact.ArtifactID)
+	if err != nil {
+		return nil, &models.APIError{Code: models.ErrCodeDatabaseError, Message: err.Error()}
+	}
+	if m != nil {
+		return m, nil
+	}
+
+	r, size, err := i.storage.ArtifactReaderAt(bundle.StoragePath, artifact.StoragePath)
+	if err != nil {
+		return nil, &models.APIError{Code: models.ErrCodeStorageError, Message: err.Error()}
+	}
+	m, err = crash.ParseMinidump(r, size)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, &models.APIError{Code: models.ErrCodeStorageError, Message: err.Error()}
+		}
+		return nil, &models.APIError{Code: models.ErrCodeInvalidMinidump, Message: err.Error()}
+	}
+	setMinidumpSource(m, artifact)
+
+	if err := i.db.InsertMinidump(bundle.BundleID, m); err != nil {
+		return nil, &models.APIError{Code: models.ErrCodeDatabaseError, Message: err.Error()}
+	}
+	return m, nil
+}
+
+// openFunc opens a bundle file by its slash-separated path in the bundle.
+type openFunc func(relPath string) (io.ReadCloser, error)
+
+// openStaged opens files of a bundle staged in a local directory.
+func openStaged(dir string) openFunc {
+	return func(relPath string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(relPath)))
+	}
+}
+
+// bundleSignature fingerprints a bundle from its crash contexts, its
+// minidumps or, failing both, the first fatal line in its logs. It returns
+// nil for a bundle that shows no crash.
+func bundleSignature(open openFunc, artifacts []*models.Artifact, crashes []models.Crash, dumps []models.Minidump) *models.CrashSignature {
+	if sig := fingerprint.FromCrashes(crashes); sig != nil {
+		return sig
+	}
+	if sig := fingerprint.FromMinidumps(dumps); sig != nil {
+		return sig
+	}
+
+	for _, a := range artifacts {
+		if a.ArtifactType != "log" {
+			continue
+		}
+		f, err := open(a.StoragePath)
+		if err != nil {
+			continue
+		}
+		line := fingerprint.FatalLogLine(f)
+		f.Close()
+		if sig := fingerprint.FromLogLine(line); sig != nil {
+			return sig
+		}
+	}
+	return nil
+}
+
+// backfillSignature fingerprints a bundle ingested before signatures were
+// computed, from its stored crash data and its logs.
+func (i *Ingester) backfillSignature(bundleID, storagePath string) error {
+	crashes, err := i.db.GetCrashes(bundleID)
+	if err != nil {
+		return err
+	}
+	dumps, err := i.db.GetMinidumps(bundleID)
+	if err != nil {
+		return err
+	}
+	stored, err := i.db.GetArtifacts(bundleID)
+	if err != nil {
+		return err
+	}
+	artifacts := make([]*models.Artifact, len(stored))
+	for n := range stored {
+		artifacts[n] = &stored[n]
+	}
+
+	sig := bundleSignature(func(relPath string) (io.ReadCloser, error) {
+		return i.storage.OpenArtifact(storagePath, relPath, 0, -1)
+	}, artifacts, crashes, dumps)
+	return i.db.SetBundleSignature(bundleID, sig)
+}
+
+func setMinidumpSource(m *models.Minidump, a *models.Artifact) {
+	m.ArtifactID = a.ArtifactID
+	m.BundleID = a.BundleID
+	m.Source = a.Filename
+	m.AnalyzedAt = time.Now().UTC().Truncate(time.Second)
+}
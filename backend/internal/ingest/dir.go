@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// IsBundleDir reports whether path is an unpacked bundle directory, that is
+// a directory with a manifest.json at its root.
+func IsBundleDir(path string) bool {
+	info, err := os.Stat(filepath.Join(path, "manifest.json"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// IngestDir ingests an unpacked bundle directory through the same pipeline
+// as an archive. The files are copied into staging, or hardlinked when
+// hardlink is set and the directory is on the same filesystem as the data
+// directory; dir itself is never modified. Symlinks and special files are
+// rejected with UNSAFE_ARCHIVE_ENTRY.
+//
+// There is no archive to hash, so the content hash is the canonical hash of
+// the directory.
+func (i *Ingester) IngestDir(dir string, hardlink bool) (*IngestResult, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", dir)
+	}
+
+	uploadID := ids.Generate(8)
+	tmpDir, err := i.storage.CreateTempDir(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+
+	// Always clean up staging; on success it has been moved to bundles/
+	defer i.storage.RemoveTempDir(tmpDir)
+
+	files, size, err := stageDir(dir, tmpDir, hardlink)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.ingestStagedBundle(tmpDir, storage.CanonicalHash(files), size, nil)
+}
+
+// stageDir copies or hardlinks every regular file under src into dest,
+// hashing each one. It returns the checksums keyed by relative path, as
+// storage.CanonicalHash expects, and the total size.
+func stageDir(src, dest string, hardlink bool) (map[string]string, int64, error) {
+	files := make(map[string]string)
+	var size int64
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		relPath := filepath.ToSlash(rel)
+
+		switch {
+		case d.IsDir():
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			return unsafeEntry(relPath, "symlinks are not allowed")
+		case !d.Type().IsRegular():
+			return unsafeEntry(relPath, "not a regular file")
+		case relPath == storage.PendingMarker:
+			return nil
+		}
+
+		target := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("create dir for %s: %w", relPath, err)
+		}
+
+		var checksum string
+		var n int64
+		if hardlink && os.Link(path, target) == nil {
+			checksum, err = storage.HashFile(target)
+			if err == nil {
+				n, err = storage.FileSize(target)
+			}
+		} else {
+			checksum, n, err = copyAndHash(path, target)
+		}
+		if err != nil {
+			return fmt.Errorf("stage %s: %w", relPath, err)
+		}
+
+		files[relPath] = checksum
+		size += n
+		return nil
+	})
+	if err != nil {
+		if apiErr, ok := err.(*models.APIError); ok {
+			return nil, 0, apiErr
+		}
+		return nil, 0, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+
+	return files, size, nil
+}
+
+// copyAndHash copies src to dest, returning the HashFile checksum and size
+// of what was written.
+func copyAndHash(src, dest string) (string, int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -2,33 +2,51 @@
 package ingest
 
 import (
-	"archive/zip"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ids"
 	"github.com/unrealsolutions/bugit/internal/models"
 	"github.com/unrealsolutions/bugit/internal/storage"
 )
 
 // Ingester processes repro bundle uploads.
 type Ingester struct {
-	db      *db.DB
-	storage *storage.Storage
+	db         *db.DB
+	storage    *storage.Storage
+	uploadTTL  time.Duration
+	uploads    idLocks
+	limits     UploadLimits
+	extract    ExtractLimits
+	policy     string
+	commits    idLocks
+	quotas     Quotas
+	quotaLocks idLocks // Serialize quota checks per build and platform
 }
 
 // New creates a new Ingester.
 func New(database *db.DB, store *storage.Storage) *Ingester {
 	return &Ingester{
-		db:      database,
-		storage: store,
+		db:        database,
+		storage:   store,
+		uploadTTL: DefaultUploadSessionTTL,
+		extract:   DefaultExtractLimits(),
+		policy:    ValidationPolicyFlag,
+		limits: UploadLimits{
+			MaxPartBytes:   DefaultMaxPartBytes,
+			MaxUploadBytes: DefaultMaxUploadBytes,
+		},
 	}
 }
 
@@ -38,12 +56,29 @@ type IngestResult struct {
 	Status        string `json:"status"` // "ingested" or "already_exists"
 	ArtifactCount int    `json:"artifact_count"`
 	CreatedAt     string `json:"created_at"`
+
+	// Validation outcome for newly ingested bundles: passed, failed or quarantined
+	ValidationStatus string `json:"validation_status,omitempty"`
+
+	// Issue a newly ingested crash bundle was grouped into
+	IssueID string `json:"issue_id,omitempty"`
 }
 
-// IngestZipFile ingests a repro bundle from a ZIP file path.
+// IngestZipFile ingests a repro bundle from an archive file path.
+// Despite the name, any format accepted by extractArchive works. A copy of
+// an archive rejected as a bad upload is quarantined, as for HTTP uploads;
+// the file itself is left in place.
 func (i *Ingester) IngestZipFile(zipPath string) (*IngestResult, error) {
+	result, err := i.ingestZipFile(zipPath)
+	if err != nil && IsUploadFault(err) {
+		err = i.quarantineFile(zipPath, err)
+	}
+	return result, err
+}
+
+func (i *Ingester) ingestZipFile(zipPath string) (*IngestResult, error) {
 	// Generate unique upload ID
-	uploadID := generateID(8)
+	uploadID := ids.Generate(8)
 
 	// Create temp directory for extraction
 	tmpDir, err := i.storage.CreateTempDir(uploadID)
@@ -51,132 +86,70 @@ func (i *Ingester) IngestZipFile(zipPath string) (*IngestResult, error) {
 		return nil, fmt.Errorf("create temp dir: %w", err)
 	}
 
-	// Ensure cleanup on failure
-	success := false
-	defer func() {
-		if !success {
-			i.storage.RemoveTempDir(tmpDir)
-		}
-	}()
+	// Always clean up staging; on success it has been moved to bundles/
+	defer i.storage.RemoveTempDir(tmpDir)
 
 	// Compute content hash of ZIP
 	contentHash, err := storage.HashFile(zipPath)
 	if err != nil {
 		return nil, &models.APIError{
 			Code:    models.ErrCodeInvalidZip,
-			Message: fmt.Sprintf("failed to hash zip: %v", err),
-		}
-	}
-
-	// Extract ZIP
-	if err := extractZip(zipPath, tmpDir); err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidZip,
-			Message: fmt.Sprintf("failed to extract zip: %v", err),
+			Message: fmt.Sprintf("failed to hash archive: %v", err),
 		}
 	}
 
-	// Parse and validate manifest
-	manifest, err := parseManifest(filepath.Join(tmpDir, "manifest.json"))
-	if err != nil {
+	// Extract archive (ZIP or tar)
+	if err := extractArchive(zipPath, tmpDir, i.extract); err != nil {
 		return nil, err
 	}
 
-	if err := manifest.Validate(); err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidManifest,
-			Message: err.Error(),
-		}
-	}
-
 	// Calculate total size
 	totalSize, err := storage.DirSize(tmpDir)
 	if err != nil {
 		return nil, fmt.Errorf("calculate size: %w", err)
 	}
 
-	// Generate bundle ID
-	bundleID := "rb_" + generateID(8)
-
-	// Create bundle record
-	bundle := &models.ReproBundle{
-		BundleID:        bundleID,
-		ContentHash:     contentHash,
-		SchemaVersion:   manifest.SchemaVersion,
-		BuildID:         manifest.BuildID,
-		MapName:         manifest.MapName,
-		Platform:        manifest.Platform,
-		RVRVersion:      manifest.RVRVersion,
-		BundleTimestamp: manifest.Timestamp,
-		Metadata:        manifest.Metadata,
-		SizeBytes:       totalSize,
-		ArtifactCount:   len(manifest.Artifacts),
-	}
+	return i.ingestStagedBundle(tmpDir, contentHash, totalSize, nil)
+}
 
-	// Insert bundle (handles idempotency via content hash)
-	existingID, alreadyExists, err := i.db.InsertBundle(bundle)
+// IngestFromReader ingests a repro bundle from a reader (for HTTP uploads).
+func (i *Ingester) IngestFromReader(r io.Reader, contentLength int64) (*IngestResult, error) {
+	staged, err := i.StageFromReader(r)
 	if err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeDatabaseError,
-			Message: fmt.Sprintf("insert bundle: %v", err),
-		}
+		return nil, err
 	}
+	return i.IngestStaged(staged, nil)
+}
 
-	if alreadyExists {
-		// Bundle already exists, clean up and return existing
-		i.storage.RemoveTempDir(tmpDir)
-		return &IngestResult{
-			BundleID:      existingID,
-			Status:        "already_exists",
-			ArtifactCount: len(manifest.Artifacts),
-		}, nil
-	}
+// ingestStagedZip extracts a ZIP already written to tmpDir and registers it.
+// The caller owns tmpDir and must remove it afterwards.
+func (i *Ingester) ingestStagedZip(tmpDir, zipPath, contentHash string, written int64, progress ProgressFunc) (*IngestResult, error) {
+	progress.report(StageExtracting, 0.1)
 
-	// Move to permanent storage
-	storagePath, err := i.storage.MoveToBundles(tmpDir, bundleID)
-	if err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeStorageError,
-			Message: fmt.Sprintf("move to storage: %v", err),
-		}
+	// Extract to a subdirectory, discarding leftovers from an interrupted run
+	extractDir := filepath.Join(tmpDir, "extracted")
+	if err := os.RemoveAll(extractDir); err != nil {
+		return nil, fmt.Errorf("clear extract dir: %w", err)
+	}
+	if err := os.MkdirAll(extractDir, 0755); err != nil {
+		return nil, fmt.Errorf("create extract dir: %w", err)
 	}
 
-	// Update bundle with storage path (would need additional DB method)
-	// For simplicity, we set it before insert - this is handled by updating the model
-
-	// Insert artifacts
-	for _, ma := range manifest.Artifacts {
-		artifactPath := filepath.Join(i.storage.BundlePath(storagePath), ma.Filename)
-		size, _ := storage.FileSize(artifactPath)
-
-		artifact := &models.Artifact{
-			ArtifactID:   "art_" + generateID(8),
-			BundleID:     bundleID,
-			Filename:     ma.Filename,
-			ArtifactType: normalizeArtifactType(ma.Type),
-			MimeType:     ma.MimeType,
-			SizeBytes:    size,
-			StoragePath:  ma.Filename,
-		}
-
-		if err := i.db.InsertArtifact(artifact); err != nil {
-			// Log but don't fail - bundle is already stored
-			fmt.Printf("warning: failed to insert artifact %s: %v\n", ma.Filename, err)
-		}
+	if err := extractArchive(zipPath, extractDir, i.extract); err != nil {
+		return nil, err
 	}
 
-	success = true
-	return &IngestResult{
-		BundleID:      bundleID,
-		Status:        "ingested",
-		ArtifactCount: len(manifest.Artifacts),
-	}, nil
+	// The ZIP is kept until the caller removes tmpDir so a queued job
+	// interrupted before commit can be re-run from scratch
+
+	return i.ingestStagedBundle(extractDir, contentHash, written, progress)
 }
 
-// IngestFromReader ingests a repro bundle from a reader (for HTTP uploads).
-func (i *Ingester) IngestFromReader(r io.Reader, contentLength int64) (*IngestResult, error) {
+// IngestFromFiles ingests a repro bundle from individual files (for direct multipart uploads).
+// This supports Unreal Engine uploads that send files individually rather than as a ZIP.
+func (i *Ingester) IngestFromFiles(files map[string][]byte) (*IngestResult, error) {
 	// Generate unique upload ID
-	uploadID := generateID(8)
+	uploadID := ids.Generate(8)
 
 	// Create temp directory
 	tmpDir, err := i.storage.CreateTempDir(uploadID)
@@ -184,52 +157,55 @@ func (i *Ingester) IngestFromReader(r io.Reader, contentLength int64) (*IngestRe
 		return nil, fmt.Errorf("create temp dir: %w", err)
 	}
 
-	// Ensure cleanup on failure
-	success := false
-	defer func() {
-		if !success {
-			i.storage.RemoveTempDir(tmpDir)
-		}
-	}()
-
-	// Write uploaded file to temp location
-	zipPath := filepath.Join(tmpDir, "upload.zip")
-	f, err := os.Create(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("create temp file: %w", err)
-	}
+	// Always clean up staging; on success it has been moved to bundles/
+	defer i.storage.RemoveTempDir(tmpDir)
 
-	// Hash while writing
+	// Compute content hash from all files
 	h := sha256.New()
-	written, err := io.Copy(io.MultiWriter(f, h), r)
-	f.Close()
-	if err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidZip,
-			Message: fmt.Sprintf("failed to read upload: %v", err),
-		}
+	var totalSize int64
+
+	// Hash in name order so the same files always give the same hash
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	contentHash := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	// Write all files to temp directory and compute hash
+	for _, name := range names {
+		data := files[name]
 
-	// Extract to a subdirectory
-	extractDir := filepath.Join(tmpDir, "extracted")
-	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return nil, fmt.Errorf("create extract dir: %w", err)
-	}
+		// Security: keep subdirectories but prevent path traversal
+		filename, err := bundleRelPath(name)
+		if err != nil {
+			return nil, err
+		}
 
-	if err := extractZip(zipPath, extractDir); err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidZip,
-			Message: fmt.Sprintf("failed to extract zip: %v", err),
+		destPath := filepath.Join(tmpDir, filepath.FromSlash(filename))
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return nil, fmt.Errorf("create dir for %s: %w", filename, err)
+		}
+		if err := os.WriteFile(destPath, data, 0644); err != nil {
+			return nil, fmt.Errorf("write file %s: %w", filename, err)
 		}
+
+		h.Write(data)
+		totalSize += int64(len(data))
 	}
 
-	// Remove the zip file to save space
-	os.Remove(zipPath)
+	contentHash := "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	return i.ingestStagedBundle(tmpDir, contentHash, totalSize, nil)
+}
+
+// ingestStagedBundle parses the manifest of a bundle staged in dir, hashes its
+// artifacts and commits it. The caller owns dir and must remove it afterwards;
+// on success it has already been moved into bundles/.
+func (i *Ingester) ingestStagedBundle(dir, contentHash string, size int64, progress ProgressFunc) (*IngestResult, error) {
+	progress.report(StageValidating, 0.4)
 
 	// Parse and validate manifest
-	manifest, err := parseManifest(filepath.Join(extractDir, "manifest.json"))
+	manifest, err := parseManifest(filepath.Join(dir, "manifest.json"))
 	if err != nil {
 		return nil, err
 	}
@@ -241,13 +217,39 @@ func (i *Ingester) IngestFromReader(r io.Reader, contentLength int64) (*IngestRe
 		}
 	}
 
-	// Generate bundle ID
-	bundleID := "rb_" + generateID(8)
+	// Check internal consistency (timing, inputs) under the validation policy
+	validation, err := i.validateStaged(dir, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Adopt a well-formed client ID so the SDK knows it before uploading
+	// and can retry safely; otherwise generate one
+	bundleID := manifest.BundleID
+	if !isValidBundleID(bundleID) {
+		bundleID = "rb_" + ids.Generate(8)
+	}
+
+	// Hash artifacts and verify any checksums declared in the manifest
+	progress.report(StageHashing, 0.5)
+	artifacts, err := collectArtifacts(dir, bundleID, manifest)
+	if err != nil {
+		return nil, err
+	}
+	canonicalHash, err := canonicalHash(dir, artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse crash data and fingerprint the crash, if there was one
+	crashes := parseCrashes(dir, artifacts)
+	minidumps := parseMinidumps(dir, artifacts)
 
 	// Create bundle record
 	bundle := &models.ReproBundle{
 		BundleID:        bundleID,
 		ContentHash:     contentHash,
+		CanonicalHash:   canonicalHash,
 		SchemaVersion:   manifest.SchemaVersion,
 		BuildID:         manifest.BuildID,
 		MapName:         manifest.MapName,
@@ -255,274 +257,335 @@ func (i *Ingester) IngestFromReader(r io.Reader, contentLength int64) (*IngestRe
 		RVRVersion:      manifest.RVRVersion,
 		BundleTimestamp: manifest.Timestamp,
 		Metadata:        manifest.Metadata,
-		SizeBytes:       written,
-		ArtifactCount:   len(manifest.Artifacts),
+		SizeBytes:       size,
+		ArtifactCount:   len(artifacts),
+		Validation:      validation,
+		Crashes:         crashes,
+		Minidumps:       minidumps,
+		Signature:       bundleSignature(openStaged(dir), artifacts, crashes, minidumps),
 	}
 
-	// Insert bundle (handles idempotency)
-	existingID, alreadyExists, err := i.db.InsertBundle(bundle)
+	progress.report(StageCommitting, 0.9)
+	existingID, alreadyExists, err := i.commitBundle(dir, bundle, artifacts)
 	if err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeDatabaseError,
-			Message: fmt.Sprintf("insert bundle: %v", err),
-		}
+		return nil, err
 	}
 
 	if alreadyExists {
-		i.storage.RemoveTempDir(tmpDir)
 		return &IngestResult{
 			BundleID:      existingID,
 			Status:        "already_exists",
-			ArtifactCount: len(manifest.Artifacts),
+			ArtifactCount: len(artifacts),
 		}, nil
 	}
 
-	// Move extracted files to permanent storage
-	storagePath, err := i.storage.MoveToBundles(extractDir, bundleID)
-	if err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeStorageError,
-			Message: fmt.Sprintf("move to storage: %v", err),
-		}
-	}
-
-	// Clean up remaining temp dir
-	i.storage.RemoveTempDir(tmpDir)
-
-	// Insert artifacts
-	for _, ma := range manifest.Artifacts {
-		artifactPath := filepath.Join(i.storage.BundlePath(storagePath), ma.Filename)
-		size, _ := storage.FileSize(artifactPath)
-
-		artifact := &models.Artifact{
-			ArtifactID:   "art_" + generateID(8),
-			BundleID:     bundleID,
-			Filename:     ma.Filename,
-			ArtifactType: normalizeArtifactType(ma.Type),
-			MimeType:     ma.MimeType,
-			SizeBytes:    size,
-			StoragePath:  ma.Filename,
-		}
-
-		if err := i.db.InsertArtifact(artifact); err != nil {
-			fmt.Printf("warning: failed to insert artifact %s: %v\n", ma.Filename, err)
-		}
-	}
-
-	success = true
 	return &IngestResult{
-		BundleID:      bundleID,
-		Status:        "ingested",
-		ArtifactCount: len(manifest.Artifacts),
+		BundleID:         bundleID,
+		Status:           "ingested",
+		ArtifactCount:    len(artifacts),
+		ValidationStatus: validation.Status,
+		IssueID:          bundle.IssueID,
 	}, nil
 }
 
-// IngestFromFiles ingests a repro bundle from individual files (for direct multipart uploads).
-// This supports Unreal Engine uploads that send files individually rather than as a ZIP.
-func (i *Ingester) IngestFromFiles(files map[string][]byte) (*IngestResult, error) {
-	// Generate unique upload ID
-	uploadID := generateID(8)
-
-	// Create temp directory
-	tmpDir, err := i.storage.CreateTempDir(uploadID)
+// commitBundle moves a staged directory into bundles/ and registers the bundle
+// and its artifacts in a single transaction.
+//
+// The database commit is the commit point. The directory carries a pending
+// marker until then; if the commit fails it is removed again, and if the
+// process dies in between, Recover removes it on the next start.
+func (i *Ingester) commitBundle(stagedDir string, bundle *models.ReproBundle, artifacts []*models.Artifact) (string, bool, error) {
+	// A retry of a client ID waits for the first attempt to finish
+	unlock := i.commits.lock(bundle.BundleID)
+	defer unlock()
+
+	// Skip the move entirely for known content
+	existingID, err := i.db.FindBundleByContentHash(bundle.ContentHash, bundle.CanonicalHash)
 	if err != nil {
-		return nil, fmt.Errorf("create temp dir: %w", err)
-	}
-
-	// Ensure cleanup on failure
-	success := false
-	defer func() {
-		if !success {
-			i.storage.RemoveTempDir(tmpDir)
+		return "", false, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: fmt.Sprintf("check existing: %v", err),
 		}
-	}()
+	}
+	if existingID != "" {
+		return existingID, true, nil
+	}
 
-	// Compute content hash from all files
-	h := sha256.New()
-	var totalSize int64
-	
-	// Write all files to temp directory and compute hash
-	for filename, data := range files {
-		// Security: prevent path traversal
-		if strings.Contains(filename, "..") || strings.ContainsAny(filename, "/\\") {
-			// Flatten any path - just use base filename
-			filename = filepath.Base(filename)
-		}
-		
-		destPath := filepath.Join(tmpDir, filename)
-		if err := os.WriteFile(destPath, data, 0644); err != nil {
-			return nil, fmt.Errorf("write file %s: %w", filename, err)
+	taken, err := i.db.BundleExists(bundle.BundleID)
+	if err != nil {
+		return "", false, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: fmt.Sprintf("check bundle id: %v", err),
 		}
-		
-		h.Write(data)
-		totalSize += int64(len(data))
 	}
-	
-	contentHash := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	if taken {
+		return "", false, bundleIDConflict(bundle.BundleID)
+	}
 
-	// Parse and validate manifest
-	manifest, err := parseManifest(filepath.Join(tmpDir, "manifest.json"))
+	// Only new content counts against a quota
+	unlockQuota, err := i.checkQuota(bundle)
 	if err != nil {
-		return nil, err
+		return "", false, err
 	}
+	defer unlockQuota()
 
-	if err := manifest.Validate(); err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidManifest,
+	if err := storage.MarkPending(stagedDir); err != nil {
+		return "", false, &models.APIError{
+			Code:    models.ErrCodeStorageError,
 			Message: err.Error(),
 		}
 	}
 
-	// Generate bundle ID
-	bundleID := "rb_" + generateID(8)
-
-	// Move to permanent storage FIRST (so we have the storage path for the bundle record)
-	storagePath, err := i.storage.MoveToBundles(tmpDir, bundleID)
+	// Move to permanent storage
+	storagePath, err := i.storage.MoveToBundles(stagedDir, bundle.BundleID)
 	if err != nil {
-		return nil, &models.APIError{
+		return "", false, &models.APIError{
 			Code:    models.ErrCodeStorageError,
 			Message: fmt.Sprintf("move to storage: %v", err),
 		}
 	}
+	bundle.StoragePath = storagePath
 
-	// Create bundle record with storage path
-	bundle := &models.ReproBundle{
-		BundleID:        bundleID,
-		ContentHash:     contentHash,
-		SchemaVersion:   manifest.SchemaVersion,
-		BuildID:         manifest.BuildID,
-		MapName:         manifest.MapName,
-		Platform:        manifest.Platform,
-		RVRVersion:      manifest.RVRVersion,
-		BundleTimestamp: manifest.Timestamp,
-		Metadata:        manifest.Metadata,
-		SizeBytes:       totalSize,
-		ArtifactCount:   len(manifest.Artifacts),
-		StoragePath:     storagePath,
+	// Insert bundle and artifacts (handles idempotency via content hash)
+	existingID, alreadyExists, err := i.db.InsertBundle(bundle, artifacts)
+	if err != nil || alreadyExists {
+		// Undo the move so no unregistered directory is left behind
+		if rmErr := i.storage.RemoveBundleDir(storagePath); rmErr != nil {
+			slog.Warn("failed to remove uncommitted bundle dir", "path", storagePath, "error", rmErr)
+		}
+	}
+	if errors.Is(err, db.ErrBundleIDTaken) {
+		return "", false, bundleIDConflict(bundle.BundleID)
 	}
-
-	// Insert bundle (handles idempotency)
-	existingID, alreadyExists, err := i.db.InsertBundle(bundle)
 	if err != nil {
-		return nil, &models.APIError{
+		return "", false, &models.APIError{
 			Code:    models.ErrCodeDatabaseError,
 			Message: fmt.Sprintf("insert bundle: %v", err),
 		}
 	}
 
-	if alreadyExists {
-		// Files already moved, but bundle exists - this shouldn't happen with content hash check
-		// but handle it gracefully
-		return &IngestResult{
-			BundleID:      existingID,
-			Status:        "already_exists",
-			ArtifactCount: len(manifest.Artifacts),
-		}, nil
+	if !alreadyExists {
+		// Committed; a leftover marker is cleared by Recover
+		if err := i.storage.ClearPending(storagePath); err != nil {
+			slog.Warn("failed to clear pending marker", "path", storagePath, "error", err)
+		}
 	}
 
-	// Insert artifacts
+	return existingID, alreadyExists, nil
+}
+
+// collectArtifacts builds artifact records for every file in dir. Files
+// listed in the manifest take their type from it; any other file is
+// registered as undeclared with its type guessed from the name. Every file
+// present is hashed with SHA-256; if the manifest declares a checksum it must
+// match or CHECKSUM_MISMATCH is returned.
+func collectArtifacts(dir, bundleID string, manifest *models.Manifest) ([]*models.Artifact, error) {
+	artifacts := make([]*models.Artifact, 0, len(manifest.Artifacts))
+	seen := make(map[string]bool)
+
 	for _, ma := range manifest.Artifacts {
-		artifactPath := filepath.Join(i.storage.BundlePath(storagePath), ma.Filename)
+		relPath, err := bundleRelPath(ma.Filename)
+		if err != nil {
+			return nil, (&models.APIError{
+				Code:    models.ErrCodeInvalidManifest,
+				Message: fmt.Sprintf("artifact path escapes the bundle: %s", ma.Filename),
+			}).WithDetails("filename", ma.Filename)
+		}
+		if seen[relPath] {
+			continue
+		}
+		seen[relPath] = true
+
+		artifactPath := filepath.Join(dir, filepath.FromSlash(relPath))
 		size, _ := storage.FileSize(artifactPath)
 
-		artifact := &models.Artifact{
-			ArtifactID:   "art_" + generateID(8),
+		checksum, err := storage.HashFile(artifactPath)
+		if err != nil {
+			checksum = ""
+		}
+
+		if ma.Checksum != "" {
+			expected := normalizeChecksum(ma.Checksum)
+			if checksum != expected {
+				got := checksum
+				if got == "" {
+					got = "missing"
+				}
+				return nil, (&models.APIError{
+					Code:    models.ErrCodeChecksumMismatch,
+					Message: fmt.Sprintf("checksum mismatch for %s", ma.Filename),
+				}).WithDetails("filename", ma.Filename).
+					WithDetails("expected", expected).
+					WithDetails("actual", got)
+			}
+		}
+		if ma.Size != nil && *ma.Size != size {
+			return nil, (&models.APIError{
+				Code:    models.ErrCodeChecksumMismatch,
+				Message: fmt.Sprintf("size mismatch for %s", ma.Filename),
+			}).WithDetails("filename", ma.Filename).
+				WithDetails("expected_size", *ma.Size).
+				WithDetails("actual_size", size)
+		}
+
+		artifacts = append(artifacts, &models.Artifact{
+			ArtifactID:   "art_" + ids.Generate(8),
 			BundleID:     bundleID,
-			Filename:     ma.Filename,
+			Filename:     relPath,
 			ArtifactType: normalizeArtifactType(ma.Type),
 			MimeType:     ma.MimeType,
 			SizeBytes:    size,
-			StoragePath:  ma.Filename,
+			StoragePath:  relPath,
+			Checksum:     checksum,
+			Role:         ma.Role,
+		})
+	}
+
+	// Register whatever else is in the bundle
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
 		}
 
-		if err := i.db.InsertArtifact(artifact); err != nil {
-			fmt.Printf("warning: failed to insert artifact %s: %v\n", ma.Filename, err)
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath := filepath.ToSlash(rel)
+		if seen[relPath] || relPath == "manifest.json" || relPath == storage.PendingMarker {
+			return nil
 		}
-	}
 
-	success = true
-	return &IngestResult{
-		BundleID:      bundleID,
-		Status:        "ingested",
-		ArtifactCount: len(manifest.Artifacts),
-	}, nil
-}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		checksum, err := storage.HashFile(path)
+		if err != nil {
+			return err
+		}
 
-// parseManifest reads and parses manifest.json.
-func parseManifest(path string) (*models.Manifest, error) {
-	data, err := os.ReadFile(path)
+		name := strings.ToLower(relPath)
+		artifacts = append(artifacts, &models.Artifact{
+			ArtifactID:   "art_" + ids.Generate(8),
+			BundleID:     bundleID,
+			Filename:     relPath,
+			ArtifactType: models.GuessArtifactType(name),
+			MimeType:     models.GuessMimeType(name),
+			SizeBytes:    info.Size(),
+			StoragePath:  relPath,
+			Checksum:     checksum,
+			Undeclared:   true,
+		})
+		return nil
+	})
 	if err != nil {
 		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidManifest,
-			Message: "manifest.json not found or unreadable",
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("scan bundle files: %v", err),
 		}
 	}
 
-	var manifest models.Manifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return nil, &models.APIError{
-			Code:    models.ErrCodeInvalidManifest,
-			Message: fmt.Sprintf("invalid JSON in manifest.json: %v", err),
-		}
-	}
+	return artifacts, nil
+}
 
-	return &manifest, nil
+// bundleIDConflict reports a client bundle ID that is already used by a
+// bundle with different content.
+func bundleIDConflict(bundleID string) error {
+	return (&models.APIError{
+		Code:    models.ErrCodeBundleIDConflict,
+		Message: fmt.Sprintf("bundle %s already exists with different content", bundleID),
+	}).WithDetails("bundle_id", bundleID)
 }
 
-// extractZip extracts a ZIP file to destination directory.
-func extractZip(zipPath, destDir string) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return fmt.Errorf("open zip: %w", err)
-	}
-	defer r.Close()
+// isValidBundleID reports whether id has the form of a generated bundle ID,
+// "rb_" and 8 lowercase hex characters. Longer IDs would share a directory
+// with others, see storage.BundleDirName.
+func isValidBundleID(id string) bool {
+	return strings.HasPrefix(id, "rb_") && isLowerHex(id[3:], 8)
+}
 
-	for _, f := range r.File {
-		// Security: prevent path traversal
-		destPath := filepath.Join(destDir, f.Name)
-		if !strings.HasPrefix(destPath, filepath.Clean(destDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("invalid file path in zip: %s", f.Name)
+// canonicalHash computes storage.CanonicalHash for a staged bundle from the
+// checksums collectArtifacts already took, so no artifact is read twice.
+// Declared artifacts that are missing are not part of the bundle.
+func canonicalHash(dir string, artifacts []*models.Artifact) (string, error) {
+	manifestHash, err := storage.HashFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return "", &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("hash manifest: %v", err),
 		}
+	}
 
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(destPath, f.Mode())
-			continue
+	files := map[string]string{"manifest.json": manifestHash}
+	for _, a := range artifacts {
+		if a.Checksum != "" {
+			files[a.StoragePath] = a.Checksum
 		}
+	}
+	return storage.CanonicalHash(files), nil
+}
 
-		// Create parent directory
-		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-			return fmt.Errorf("create dir for %s: %w", f.Name, err)
-		}
+// bundleRelPath cleans a client-supplied file name into a slash-separated
+// path relative to the bundle root. Backslashes count as separators so
+// Windows clients keep their folders. Absolute paths and names that would
+// escape the bundle are rejected with UNSAFE_ARCHIVE_ENTRY.
+func bundleRelPath(name string) (string, error) {
+	p := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	switch {
+	case p == "." || p == ".." || strings.HasPrefix(p, "../"):
+		return "", unsafeEntry(name, "path escapes the bundle directory")
+	case strings.HasPrefix(p, "/") || (len(p) >= 2 && p[1] == ':'):
+		return "", unsafeEntry(name, "absolute path")
+	}
+	return p, nil
+}
 
-		// Extract file
-		rc, err := f.Open()
-		if err != nil {
-			return fmt.Errorf("open %s in zip: %w", f.Name, err)
-		}
+// normalizeChecksum converts a declared checksum to the stored
+// "sha256:<hex>" form. Bare hex digests are assumed to be SHA-256.
+func normalizeChecksum(c string) string {
+	c = strings.ToLower(strings.TrimSpace(c))
+	c = strings.TrimPrefix(c, "sha256:")
+	c = strings.TrimPrefix(c, "sha-256:")
+	return "sha256:" + c
+}
 
-		outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			rc.Close()
-			return fmt.Errorf("create %s: %w", destPath, err)
+// parseManifest reads and parses manifest.json.
+func parseManifest(path string) (*models.Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeInvalidManifest,
+			Message: "manifest.json not found or unreadable",
 		}
+	}
 
-		_, err = io.Copy(outFile, rc)
-		outFile.Close()
-		rc.Close()
-
-		if err != nil {
-			return fmt.Errorf("extract %s: %w", f.Name, err)
+	manifest, err := models.ParseManifest(data)
+	var unsupported *models.UnsupportedSchemaError
+	var invalid *models.ValidationError
+	switch {
+	case err == nil:
+		return manifest, nil
+	case errors.As(err, &unsupported):
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeUnsupportedSchema,
+			Message: unsupported.Error(),
+		}).WithDetails("schema_version", unsupported.Version).
+			WithDetails("supported_versions", unsupported.Supported)
+	case errors.As(err, &invalid):
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeInvalidManifest,
+			Message: invalid.Error(),
+		}).WithDetails("field", invalid.Field)
+	default:
+		return nil, &models.APIError{
+			Code:    models.ErrCodeInvalidManifest,
+			Message: fmt.Sprintf("invalid JSON in manifest.json: %v", err),
 		}
 	}
-
-	return nil
-}
-
-// generateID generates a random hex ID of specified length.
-func generateID(length int) string {
-	bytes := make([]byte, length)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)[:length]
 }
 
 // normalizeArtifactType maps manifest types to DB enum values.
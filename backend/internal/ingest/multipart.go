@@ -0,0 +1,226 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// Default multipart upload limits.
+const (
+	DefaultMaxPartBytes   int64 = 2 << 30 // 2 GB
+	DefaultMaxUploadBytes int64 = 4 << 30 // 4 GB
+)
+
+// UploadLimits bounds the size of streamed multipart uploads.
+// A zero value disables the corresponding limit.
+type UploadLimits struct {
+	MaxPartBytes   int64 // Largest single part
+	MaxUploadBytes int64 // Sum of all parts
+}
+
+// SetUploadLimits sets the limits applied by IngestFromMultipart.
+func (i *Ingester) SetUploadLimits(limits UploadLimits) {
+	i.limits = limits
+}
+
+// IngestFromMultipart ingests a repro bundle by streaming a multipart body.
+// See StageFromMultipart for the accepted formats.
+func (i *Ingester) IngestFromMultipart(mr *multipart.Reader) (*IngestResult, error) {
+	staged, err := i.StageFromMultipart(mr)
+	if err != nil {
+		return nil, err
+	}
+	return i.IngestStaged(staged, nil)
+}
+
+// StageFromMultipart streams a multipart body into a new staging directory.
+//
+// A file part named "file" is treated as a bundle archive and staged like
+// StageFromReader; it must be the only file part. Otherwise every file part
+// is written straight into the staging directory under its form field name,
+// as sent by the Unreal SDK, and hashed as it streams. A name such as
+// "screenshots/001.png" keeps its subdirectory. The content hash is the
+// storage.CanonicalHash of the parts, so it does not depend on their order.
+// Nothing is buffered in memory.
+func (i *Ingester) StageFromMultipart(mr *multipart.Reader) (*StagedUpload, error) {
+	// Generate unique upload ID
+	uploadID := ids.Generate(8)
+
+	// Create temp directory
+	tmpDir, err := i.storage.CreateTempDir(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+
+	// Ensure cleanup on failure
+	success := false
+	defer func() {
+		if !success {
+			i.storage.RemoveTempDir(tmpDir)
+		}
+	}()
+
+	var totalSize int64
+	var staged *StagedUpload
+	files := make(map[string]string) // Part path -> checksum
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &models.APIError{
+				Code:    models.ErrCodeInvalidZip,
+				Message: fmt.Sprintf("failed to read multipart form: %v", err),
+			}
+		}
+
+		// Only file parts are artifacts; plain form values are ignored
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		name := part.FormName()
+		limit := i.partLimit(totalSize)
+
+		// A bundle archive cannot be combined with loose files
+		if staged != nil || (name == "file" && len(files) > 0) {
+			part.Close()
+			return nil, &models.APIError{
+				Code:    models.ErrCodeInvalidZip,
+				Message: `a "file" archive part must be the only file in the form`,
+			}
+		}
+		if name == "file" {
+			staged, err = stageZip(tmpDir, &sizeLimitReader{r: part, budget: limit})
+			part.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Security: keep subdirectories but prevent path traversal
+		filename, err := bundleRelPath(name)
+		if err != nil {
+			part.Close()
+			return nil, err
+		}
+
+		// First part wins for duplicate field names
+		if _, ok := files[filename]; ok {
+			part.Close()
+			continue
+		}
+
+		h := sha256.New()
+		written, err := writePart(filepath.Join(tmpDir, filepath.FromSlash(filename)), &sizeLimitReader{r: part, budget: limit}, h)
+		part.Close()
+		if err != nil {
+			if apiErr, ok := err.(*models.APIError); ok {
+				return nil, apiErr
+			}
+			if fullErr := diskFull(err); fullErr != nil {
+				return nil, fullErr
+			}
+			return nil, fmt.Errorf("write file %s: %w", filename, err)
+		}
+
+		files[filename] = "sha256:" + hex.EncodeToString(h.Sum(nil))
+		totalSize += written
+	}
+
+	if staged != nil {
+		success = true
+		return staged, nil
+	}
+	if len(files) == 0 {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeInvalidZip,
+			Message: "no files found in multipart form",
+		}
+	}
+
+	success = true
+	return &StagedUpload{
+		Dir:         tmpDir,
+		Kind:        StagedFiles,
+		ContentHash: storage.CanonicalHash(files),
+		Size:        totalSize,
+	}, nil
+}
+
+// writePart streams r into destPath, feeding the bundle hash as it goes.
+func writePart(destPath string, r io.Reader, h io.Writer) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(destPath)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
+
+// partBudget is the number of bytes the next part may use and which
+// configured limit that number comes from.
+type partBudget struct {
+	n     int64
+	field string
+	max   int64
+}
+
+// partLimit returns the budget for the next part given the bytes already used.
+// A zero max means unlimited.
+func (i *Ingester) partLimit(used int64) partBudget {
+	var budget partBudget
+	if i.limits.MaxPartBytes > 0 {
+		budget = partBudget{n: i.limits.MaxPartBytes, field: "max_part_bytes", max: i.limits.MaxPartBytes}
+	}
+	if i.limits.MaxUploadBytes > 0 {
+		remaining := i.limits.MaxUploadBytes - used
+		if budget.max == 0 || remaining < budget.n {
+			budget = partBudget{n: remaining, field: "max_upload_bytes", max: i.limits.MaxUploadBytes}
+		}
+	}
+	return budget
+}
+
+// sizeLimitReader fails with UPLOAD_TOO_LARGE once the budget is exceeded.
+type sizeLimitReader struct {
+	r      io.Reader
+	budget partBudget
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.budget.max == 0 {
+		return l.r.Read(p)
+	}
+
+	n, err := l.r.Read(p)
+	l.budget.n -= int64(n)
+	if l.budget.n < 0 {
+		return n, (&models.APIError{
+			Code:    models.ErrCodeUploadTooLarge,
+			Message: fmt.Sprintf("upload exceeds %s limit of %d bytes", l.budget.field, l.budget.max),
+		}).WithDetails("limit", l.budget.field)
+	}
+	return n, err
+}
@@ -0,0 +1,186 @@
+package ingest
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// quarantineCodes are the errors that mean the upload itself is bad, as
+// opposed to a server-side failure. Only those uploads are quarantined.
+var quarantineCodes = map[string]bool{
+	models.ErrCodeInvalidManifest:      true,
+	models.ErrCodeUnsupportedSchema:    true,
+	models.ErrCodeInvalidZip:           true,
+	models.ErrCodeInvalidTar:           true,
+	models.ErrCodeInvalidTarGz:         true,
+	models.ErrCodeInvalidTarZst:        true,
+	models.ErrCodeUnsupportedArchive:   true,
+	models.ErrCodeArchiveLimitExceeded: true,
+	models.ErrCodeUnsafeArchiveEntry:   true,
+	models.ErrCodeChecksumMismatch:     true,
+	models.ErrCodeValidationFailed:     true,
+}
+
+// IsUploadFault reports whether err means the upload itself is bad, so
+// that sending it again unchanged cannot succeed.
+func IsUploadFault(err error) bool {
+	var apiErr *models.APIError
+	return errors.As(err, &apiErr) && quarantineCodes[apiErr.Code]
+}
+
+// quarantine moves a failed staged upload into quarantine/ and returns err
+// with the quarantine_id added. Uploads re-ingested from quarantine always go
+// back, whatever the error, so a failed retry never loses them. Any other
+// error is returned unchanged and the staging directory is left to the caller.
+func (i *Ingester) quarantine(staged *StagedUpload, err error) error {
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+	if staged.quarantined == nil && !quarantineCodes[apiErr.Code] {
+		return err
+	}
+
+	// Only the upload as received is kept, not a partial extraction
+	if staged.Kind == StagedZip {
+		if rmErr := os.RemoveAll(filepath.Join(staged.Dir, "extracted")); rmErr != nil {
+			slog.Warn("failed to clear extract dir before quarantine", "dir", staged.Dir, "error", rmErr)
+		}
+	}
+
+	now := time.Now().UTC()
+	entry := &models.QuarantineEntry{
+		QuarantineID: "q_" + ids.Generate(16),
+		Kind:         staged.Kind,
+		ContentHash:  staged.ContentHash,
+		Error:        &models.APIError{Code: apiErr.Code, Message: apiErr.Message, Details: apiErr.Details},
+		Headers:      staged.Headers,
+		Attempts:     1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if prev := staged.quarantined; prev != nil {
+		entry.QuarantineID = prev.QuarantineID
+		entry.Headers = prev.Headers
+		entry.Attempts = prev.Attempts + 1
+		entry.CreatedAt = prev.CreatedAt
+	}
+	if staged.Kind == StagedZip {
+		entry.Format = detectArchiveFile(filepath.Join(staged.Dir, "upload.zip"))
+	}
+
+	kept, qErr := i.storage.Quarantine(staged.Dir, entry)
+	if qErr != nil {
+		slog.Warn("failed to quarantine upload", "error", qErr)
+	}
+	if !kept {
+		return err
+	}
+
+	slog.Info("quarantined rejected upload", "quarantine_id", entry.QuarantineID, "code", apiErr.Code)
+	return (&models.APIError{
+		Code:    apiErr.Code,
+		Message: apiErr.Message,
+		Details: apiErr.Details,
+	}).WithDetails("quarantine_id", entry.QuarantineID)
+}
+
+// quarantineFile quarantines a copy of the archive at path, which was
+// rejected with err, and returns err as quarantine does. If no copy can be
+// made, err is returned unchanged.
+func (i *Ingester) quarantineFile(path string, err error) error {
+	tmpDir, tmpErr := i.storage.CreateTempDir(ids.Generate(8))
+	if tmpErr != nil {
+		slog.Warn("failed to quarantine archive", "file", path, "error", tmpErr)
+		return err
+	}
+	defer i.storage.RemoveTempDir(tmpDir)
+
+	f, openErr := os.Open(path)
+	if openErr != nil {
+		slog.Warn("failed to quarantine archive", "file", path, "error", openErr)
+		return err
+	}
+	staged, stageErr := stageZip(tmpDir, f)
+	f.Close()
+	if stageErr != nil {
+		slog.Warn("failed to quarantine archive", "file", path, "error", stageErr)
+		return err
+	}
+	return i.quarantine(staged, err)
+}
+
+// ReingestQuarantined ingests a quarantined upload again, for example after
+// a server-side fix or a policy change. On success the entry is gone; on
+// failure it stays in quarantine with the new error and attempt count.
+func (i *Ingester) ReingestQuarantined(quarantineID string) (*IngestResult, error) {
+	entry, dir, err := i.storage.TakeFromQuarantine(quarantineID, ids.Generate(8))
+	if err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+	if entry == nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeQuarantineNotFound,
+			Message: "quarantined upload not found: " + quarantineID,
+		}
+	}
+
+	size, err := storage.DirSize(dir)
+	if err != nil {
+		size = entry.SizeBytes
+	}
+
+	return i.IngestStaged(&StagedUpload{
+		Dir:         dir,
+		Kind:        entry.Kind,
+		ContentHash: entry.ContentHash,
+		Size:        size,
+		quarantined: entry,
+	}, nil)
+}
+
+// detectArchiveFile returns the archive format of the file at path, or ""
+// if it cannot be read or is not a supported archive.
+func detectArchiveFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	header := make([]byte, 512)
+	n, _ := f.Read(header)
+	return detectArchiveFormat(header[:n])
+}
+
+// QuarantineFileName returns a download name for a quarantined upload.
+func QuarantineFileName(entry *models.QuarantineEntry) string {
+	if entry.Kind != StagedZip {
+		return entry.QuarantineID + ".zip"
+	}
+	switch entry.Format {
+	case FormatZip:
+		return entry.QuarantineID + ".zip"
+	case FormatTar:
+		return entry.QuarantineID + ".tar"
+	case FormatTarGz:
+		return entry.QuarantineID + ".tar.gz"
+	case FormatTarZst:
+		return entry.QuarantineID + ".tar.zst"
+	default:
+		return entry.QuarantineID + ".bin"
+	}
+}
@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// RecoveryReport summarizes what Recover reconciled.
+type RecoveryReport struct {
+	RemovedDirs       []string `json:"removed_dirs,omitempty"`        // Uncommitted directories deleted
+	ClearedMarkers    []string `json:"cleared_markers,omitempty"`     // Committed directories whose pending marker was left behind
+	FixedStoragePaths []string `json:"fixed_storage_paths,omitempty"` // Bundles whose storage_path was filled in
+	UnregisteredDirs  []string `json:"unregistered_dirs,omitempty"`   // Directories with no row and no marker, left in place
+	MissingDirs       []string `json:"missing_dirs,omitempty"`        // Bundles whose directory does not exist
+	BackfilledHashes  []string `json:"backfilled_hashes,omitempty"`   // Bundles whose canonical_hash was filled in
+	Fingerprinted     []string `json:"fingerprinted,omitempty"`       // Bundles whose crash signature was computed
+}
+
+// Recover reconciles bundles/ with the database after an interrupted ingest.
+// It must run before any ingestion starts, normally on serve startup.
+//
+// Directories still carrying the pending marker without a database row were
+// never committed and are removed. Directories without a row and without a
+// marker are only reported, never deleted, since they may be all that is left
+// of a lost database. Bundles ingested before canonical hashes existed get
+// one computed from their directory, and bundles ingested before crash
+// signatures existed are fingerprinted and grouped into issues.
+func (i *Ingester) Recover() (*RecoveryReport, error) {
+	report := &RecoveryReport{}
+
+	paths, err := i.db.ListBundleStoragePaths()
+	if err != nil {
+		return nil, fmt.Errorf("list bundles: %w", err)
+	}
+
+	// Older ZIP ingests never recorded storage_path
+	for bundleID, storagePath := range paths {
+		if storagePath != "" {
+			continue
+		}
+		candidate := "bundles/" + storage.BundleDirName(bundleID)
+		if !i.storage.BundleExists(candidate) {
+			continue
+		}
+		if err := i.db.SetBundleStoragePath(bundleID, candidate); err != nil {
+			return nil, fmt.Errorf("set storage path for %s: %w", bundleID, err)
+		}
+		paths[bundleID] = candidate
+		report.FixedStoragePaths = append(report.FixedStoragePaths, bundleID)
+	}
+
+	referenced := make(map[string]bool, len(paths))
+	for bundleID, storagePath := range paths {
+		// Windows builds once recorded backslashes
+		referenced[filepath.ToSlash(storagePath)] = true
+		if !i.storage.BundleExists(storagePath) {
+			report.MissingDirs = append(report.MissingDirs, bundleID)
+		}
+	}
+
+	dirs, err := i.storage.ListBundleDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		pending := i.storage.IsPending(dir)
+		switch {
+		case referenced[dir] && pending:
+			if err := i.storage.ClearPending(dir); err != nil {
+				return nil, fmt.Errorf("clear marker in %s: %w", dir, err)
+			}
+			report.ClearedMarkers = append(report.ClearedMarkers, dir)
+		case referenced[dir]:
+			// Committed and consistent
+		case pending:
+			if err := i.storage.RemoveBundleDir(dir); err != nil {
+				return nil, fmt.Errorf("remove %s: %w", dir, err)
+			}
+			report.RemovedDirs = append(report.RemovedDirs, dir)
+		default:
+			report.UnregisteredDirs = append(report.UnregisteredDirs, dir)
+		}
+	}
+
+	missing, err := i.db.ListBundlesWithoutCanonicalHash()
+	if err != nil {
+		return nil, fmt.Errorf("list bundles without canonical hash: %w", err)
+	}
+	for bundleID, storagePath := range missing {
+		if storagePath == "" {
+			continue
+		}
+		hash, err := i.storage.HashBundle(storagePath)
+		if err != nil {
+			// Reported above if the directory is gone
+			continue
+		}
+		if err := i.db.SetBundleCanonicalHash(bundleID, hash); err != nil {
+			return nil, fmt.Errorf("set canonical hash for %s: %w", bundleID, err)
+		}
+		report.BackfilledHashes = append(report.BackfilledHashes, bundleID)
+	}
+
+	unsigned, err := i.db.ListBundlesWithoutSignature()
+	if err != nil {
+		return nil, fmt.Errorf("list bundles without signature: %w", err)
+	}
+	for bundleID, storagePath := range unsigned {
+		if storagePath == "" {
+			continue
+		}
+		if err := i.backfillSignature(bundleID, storagePath); err != nil {
+			return nil, fmt.Errorf("fingerprint %s: %w", bundleID, err)
+		}
+		report.Fingerprinted = append(report.Fingerprinted, bundleID)
+	}
+
+	for _, dir := range report.UnregisteredDirs {
+		slog.Warn("bundle directory has no database row", "path", dir)
+	}
+	for _, bundleID := range report.MissingDirs {
+		slog.Warn("bundle directory is missing", "bundle_id", bundleID)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,177 @@
+package ingest
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// ReindexReport summarizes what Reindex rebuilt.
+type ReindexReport struct {
+	Indexed  []string         `json:"indexed"`           // Bundles whose rows were recreated
+	Existing int              `json:"existing"`          // Directories already in the database
+	Skipped  []string         `json:"skipped,omitempty"` // Uncommitted directories carrying the pending marker
+	Failed   []ReindexFailure `json:"failed,omitempty"`
+}
+
+// ReindexFailure is a bundle directory that could not be indexed.
+type ReindexFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Reindex recreates the database rows of every bundle directory that has
+// none, for when bugit.db was lost. Each manifest is parsed and validated
+// and each file hashed as at ingest; the bundle keeps the ID its directory
+// is named after.
+//
+// What only the upload had is approximated: content_hash is set to the
+// canonical hash, size_bytes to the total size of the stored files and
+// created_at to the manifest timestamp, or the newest file's modification
+// time if the manifest has none. Tags and notes are not stored with the
+// bundle and must be restored with ImportAnnotations.
+func (i *Ingester) Reindex() (*ReindexReport, error) {
+	report := &ReindexReport{Indexed: []string{}}
+
+	paths, err := i.db.ListBundleStoragePaths()
+	if err != nil {
+		return nil, fmt.Errorf("list bundles: %w", err)
+	}
+	referenced := make(map[string]bool, len(paths))
+	for _, storagePath := range paths {
+		referenced[filepath.ToSlash(storagePath)] = true
+	}
+
+	dirs, err := i.storage.ListBundleDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		bundleID := path.Base(dir)
+		switch {
+		case referenced[dir] || known(paths, bundleID):
+			report.Existing++
+			continue
+		case i.storage.IsPending(dir):
+			report.Skipped = append(report.Skipped, dir)
+			continue
+		case !isValidBundleID(bundleID):
+			report.Failed = append(report.Failed, ReindexFailure{Path: dir, Error: "directory name is not a bundle ID"})
+			continue
+		}
+
+		if err := i.reindexBundle(bundleID, dir); err != nil {
+			slog.Warn("failed to reindex bundle", "path", dir, "error", err)
+			report.Failed = append(report.Failed, ReindexFailure{Path: dir, Error: err.Error()})
+			continue
+		}
+		report.Indexed = append(report.Indexed, bundleID)
+	}
+
+	return report, nil
+}
+
+// known reports whether bundleID already has a row, even one whose
+// storage_path names another directory.
+func known(paths map[string]string, bundleID string) bool {
+	_, ok := paths[bundleID]
+	return ok
+}
+
+// reindexBundle registers the committed bundle in storagePath under bundleID.
+func (i *Ingester) reindexBundle(bundleID, storagePath string) error {
+	files, err := i.storage.ListBundleFiles(storagePath)
+	if err != nil {
+		return fmt.Errorf("list files: %w", err)
+	}
+
+	var size int64
+	var createdAt time.Time
+	for _, f := range files {
+		size += f.Size
+		if f.ModTime.After(createdAt) {
+			createdAt = f.ModTime
+		}
+	}
+
+	// Bundles in object storage are parsed from a local copy
+	dir, local := i.storage.LocalBundleDir(storagePath)
+	if !local {
+		dir, err = i.storage.CreateTempDir(ids.Generate(8))
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		defer i.storage.RemoveTempDir(dir)
+
+		if err := i.storage.FetchBundle(storagePath, dir); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := parseManifest(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return err
+	}
+	if !manifest.Timestamp.IsZero() {
+		createdAt = manifest.Timestamp
+	}
+	if err := manifest.Validate(); err != nil {
+		return &models.APIError{
+			Code:    models.ErrCodeInvalidManifest,
+			Message: err.Error(),
+		}
+	}
+
+	validation, err := i.validateStaged(dir, manifest)
+	if err != nil {
+		return err
+	}
+
+	artifacts, err := collectArtifacts(dir, bundleID, manifest)
+	if err != nil {
+		return err
+	}
+	canonicalHash, err := canonicalHash(dir, artifacts)
+	if err != nil {
+		return err
+	}
+
+	crashes := parseCrashes(dir, artifacts)
+	minidumps := parseMinidumps(dir, artifacts)
+
+	bundle := &models.ReproBundle{
+		BundleID:        bundleID,
+		ContentHash:     canonicalHash, // The uploaded archive is gone
+		CanonicalHash:   canonicalHash,
+		SchemaVersion:   manifest.SchemaVersion,
+		BuildID:         manifest.BuildID,
+		MapName:         manifest.MapName,
+		Platform:        manifest.Platform,
+		RVRVersion:      manifest.RVRVersion,
+		BundleTimestamp: manifest.Timestamp,
+		Metadata:        manifest.Metadata,
+		SizeBytes:       size,
+		ArtifactCount:   len(artifacts),
+		StoragePath:     storagePath,
+		CreatedAt:       createdAt,
+		Validation:      validation,
+		Crashes:         crashes,
+		Minidumps:       minidumps,
+		Signature:       bundleSignature(openStaged(dir), artifacts, crashes, minidumps),
+	}
+
+	existingID, alreadyExists, err := i.db.InsertBundle(bundle, artifacts)
+	if err != nil {
+		return fmt.Errorf("insert bundle: %w", err)
+	}
+	if alreadyExists {
+		return fmt.Errorf("same content as bundle %s", existingID)
+	}
+	return nil
+}
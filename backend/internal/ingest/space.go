@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// QuotaDefault is the Quotas key that applies to every build ID or platform
+// without an entry of its own.
+const QuotaDefault = "*"
+
+// Quotas caps the total size in bytes of the bundles stored per build ID and
+// per platform. A missing or zero entry is unlimited.
+type Quotas struct {
+	Build    map[string]int64
+	Platform map[string]int64
+}
+
+// SetQuotas sets the per build and per platform quotas.
+func (i *Ingester) SetQuotas(quotas Quotas) {
+	i.quotas = quotas
+}
+
+// quotaFor returns the quota for key, falling back to the default entry.
+func quotaFor(quotas map[string]int64, key string) int64 {
+	if n, ok := quotas[key]; ok {
+		return n
+	}
+	return quotas[QuotaDefault]
+}
+
+// CheckSpace returns INSUFFICIENT_STORAGE if need more bytes would not fit on
+// the data volume without eating into its reserve. A need of 0, for uploads
+// of unknown size, only checks that the reserve is not already reached.
+func (i *Ingester) CheckSpace(need int64) error {
+	usage, err := i.storage.DiskUsage()
+	if err != nil {
+		// Not throttled; a write that hits a full disk still fails cleanly
+		slog.Debug("free space check skipped", "error", err)
+		return nil
+	}
+
+	if usage.HeadroomBytes > 0 && need <= usage.HeadroomBytes {
+		return nil
+	}
+	return (&models.APIError{
+		Code:    models.ErrCodeInsufficientStorage,
+		Message: fmt.Sprintf("not enough disk space: upload needs %d bytes, %d available", need, usage.HeadroomBytes),
+	}).WithDetails("needed_bytes", need).WithDetails("available_bytes", usage.HeadroomBytes)
+}
+
+// checkQuota returns QUOTA_EXCEEDED if storing bundle would take its build or
+// platform over quota. Otherwise it holds the quota locks of the build and
+// platform until the returned function is called, which must be after the
+// bundle is inserted so concurrent commits see each other's bytes.
+func (i *Ingester) checkQuota(bundle *models.ReproBundle) (func(), error) {
+	buildQuota := quotaFor(i.quotas.Build, bundle.BuildID)
+	platformQuota := quotaFor(i.quotas.Platform, bundle.Platform)
+	if buildQuota <= 0 && platformQuota <= 0 {
+		return func() {}, nil
+	}
+
+	// Always build before platform, so two commits cannot deadlock
+	unlockBuild := i.quotaLocks.lock("build:" + bundle.BuildID)
+	unlockPlatform := i.quotaLocks.lock("platform:" + bundle.Platform)
+	unlock := func() {
+		unlockPlatform()
+		unlockBuild()
+	}
+
+	buildBytes, platformBytes, err := i.db.BundleBytes(bundle.BuildID, bundle.Platform)
+	if err != nil {
+		unlock()
+		return nil, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: fmt.Sprintf("check quota: %v", err),
+		}
+	}
+
+	if buildQuota > 0 && buildBytes+bundle.SizeBytes > buildQuota {
+		unlock()
+		return nil, quotaExceeded("build_id", bundle.BuildID, buildQuota, buildBytes)
+	}
+	if platformQuota > 0 && platformBytes+bundle.SizeBytes > platformQuota {
+		unlock()
+		return nil, quotaExceeded("platform", bundle.Platform, platformQuota, platformBytes)
+	}
+	return unlock, nil
+}
+
+func quotaExceeded(scope, key string, quota, used int64) error {
+	return (&models.APIError{
+		Code:    models.ErrCodeQuotaExceeded,
+		Message: fmt.Sprintf("%s %q would exceed its quota of %d bytes (%d already used)", scope, key, quota, used),
+	}).WithDetails("scope", scope).
+		WithDetails("key", key).
+		WithDetails("quota_bytes", quota).
+		WithDetails("used_bytes", used)
+}
+
+// diskFull converts a write that failed on a full disk into
+// INSUFFICIENT_STORAGE, so it is neither blamed on the upload nor
+// quarantined. Any other error yields nil.
+func diskFull(err error) error {
+	if !storage.IsNoSpace(err) {
+		return nil
+	}
+	return &models.APIError{
+		Code:    models.ErrCodeInsufficientStorage,
+		Message: fmt.Sprintf("disk full: %v", err),
+	}
+}
@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// Staged upload kinds.
+const (
+	StagedZip   = "zip"   // Dir holds upload.zip, which may be any supported archive format
+	StagedFiles = "files" // Dir holds the bundle files directly
+)
+
+// Ingest stages reported to a ProgressFunc.
+const (
+	StageExtracting = "extracting"
+	StageValidating = "validating"
+	StageHashing    = "hashing"
+	StageCommitting = "committing"
+)
+
+// ProgressFunc receives stage transitions during ingestion.
+// progress is an estimate in [0, 1].
+type ProgressFunc func(stage string, progress float64)
+
+func (f ProgressFunc) report(stage string, progress float64) {
+	if f != nil {
+		f(stage, progress)
+	}
+}
+
+// StagedUpload is an upload that has been fully received and hashed into a
+// staging directory but not yet extracted or registered.
+type StagedUpload struct {
+	Dir         string `json:"dir"`
+	Kind        string `json:"kind"`
+	ContentHash string `json:"content_hash"`
+	Size        int64  `json:"size"`
+
+	// Request headers, kept with the upload if it is quarantined
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Set when re-ingesting from quarantine
+	quarantined *models.QuarantineEntry
+}
+
+// StageFromReader writes an archive upload into a new staging directory,
+// hashing it as it streams.
+func (i *Ingester) StageFromReader(r io.Reader) (*StagedUpload, error) {
+	// Generate unique upload ID
+	uploadID := ids.Generate(8)
+
+	// Create temp directory
+	tmpDir, err := i.storage.CreateTempDir(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+
+	staged, err := stageZip(tmpDir, r)
+	if err != nil {
+		i.storage.RemoveTempDir(tmpDir)
+		return nil, err
+	}
+	return staged, nil
+}
+
+// stageZip writes r to upload.zip inside dir and hashes it.
+func stageZip(dir string, r io.Reader) (*StagedUpload, error) {
+	// Write uploaded file to temp location
+	zipPath := filepath.Join(dir, "upload.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+
+	// Hash while writing
+	h := sha256.New()
+	written, err := io.Copy(io.MultiWriter(f, h), r)
+	f.Close()
+	if err != nil {
+		// Pass through errors raised by the source, such as upload limits
+		var apiErr *models.APIError
+		if errors.As(err, &apiErr) {
+			return nil, apiErr
+		}
+		if fullErr := diskFull(err); fullErr != nil {
+			return nil, fullErr
+		}
+		return nil, &models.APIError{
+			Code:    models.ErrCodeInvalidZip,
+			Message: fmt.Sprintf("failed to read upload: %v", err),
+		}
+	}
+
+	return &StagedUpload{
+		Dir:         dir,
+		Kind:        StagedZip,
+		ContentHash: "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		Size:        written,
+	}, nil
+}
+
+// IngestStaged extracts and registers a staged upload. The staging directory
+// is always removed afterwards; if the bundle itself is at fault it is moved
+// to quarantine/ instead and the error carries its quarantine_id.
+func (i *Ingester) IngestStaged(staged *StagedUpload, progress ProgressFunc) (*IngestResult, error) {
+	// Always clean up staging; on success it has been moved to bundles/
+	defer i.storage.RemoveTempDir(staged.Dir)
+
+	result, err := i.ingestStaged(staged, progress)
+	if err != nil {
+		err = i.quarantine(staged, err)
+	}
+	return result, err
+}
+
+func (i *Ingester) ingestStaged(staged *StagedUpload, progress ProgressFunc) (*IngestResult, error) {
+	switch staged.Kind {
+	case StagedZip:
+		zipPath := filepath.Join(staged.Dir, "upload.zip")
+		return i.ingestStagedZip(staged.Dir, zipPath, staged.ContentHash, staged.Size, progress)
+	case StagedFiles:
+		return i.ingestStagedBundle(staged.Dir, staged.ContentHash, staged.Size, progress)
+	default:
+		return nil, fmt.Errorf("unknown staged upload kind: %q", staged.Kind)
+	}
+}
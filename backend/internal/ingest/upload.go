@@ -0,0 +1,324 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// DefaultUploadSessionTTL is how long an idle resumable upload is kept
+// before CleanupOldTempDirs removes it.
+const DefaultUploadSessionTTL = 24 * time.Hour
+
+// idLocks serializes work on the same key, such as the chunks of one upload
+// session or the commits of one bundle ID. Entries are dropped once nobody
+// holds or waits for them.
+type idLocks struct {
+	mu    sync.Mutex
+	locks map[string]*idLock
+}
+
+type idLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the lock for id and returns a function that releases it.
+func (l *idLocks) lock(id string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*idLock)
+	}
+	m, ok := l.locks[id]
+	if !ok {
+		m = &idLock{}
+		l.locks[id] = m
+	}
+	m.refs++
+	l.mu.Unlock()
+
+	m.Lock()
+	return func() {
+		m.Unlock()
+		l.mu.Lock()
+		if m.refs--; m.refs == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// SetUploadSessionTTL sets how long an idle upload session is kept.
+func (i *Ingester) SetUploadSessionTTL(ttl time.Duration) {
+	i.uploadTTL = ttl
+}
+
+// CreateUpload starts a resumable upload session.
+// size is the declared total size in bytes, or 0 if unknown.
+func (i *Ingester) CreateUpload(size int64) (*models.UploadSession, error) {
+	uploadID := ids.Generate(16)
+
+	tmpDir, err := i.storage.CreateTempDir(uploadID)
+	if err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("create temp dir: %v", err),
+		}
+	}
+
+	f, err := os.Create(filepath.Join(tmpDir, "upload.zip"))
+	if err != nil {
+		i.storage.RemoveTempDir(tmpDir)
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("create upload file: %v", err),
+		}
+	}
+	f.Close()
+
+	now := time.Now().UTC()
+	session := &models.UploadSession{
+		UploadID:  uploadID,
+		Size:      size,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(i.uploadTTL),
+	}
+
+	if err := i.storage.SaveUploadSession(session); err != nil {
+		i.storage.RemoveTempDir(tmpDir)
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+
+	return session, nil
+}
+
+// GetUpload returns the current state of an upload session.
+func (i *Ingester) GetUpload(uploadID string) (*models.UploadSession, error) {
+	return i.loadUpload(uploadID)
+}
+
+// AppendUpload writes a chunk at offset. The offset must equal the number of
+// bytes already received; otherwise UPLOAD_OFFSET_MISMATCH is returned with
+// the current offset so the client can resume from there.
+func (i *Ingester) AppendUpload(uploadID string, offset int64, r io.Reader) (*models.UploadSession, error) {
+	session, unlock, err := i.lockUpload(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if offset != session.Offset {
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeUploadOffsetMismatch,
+			Message: fmt.Sprintf("offset %d does not match current upload offset %d", offset, session.Offset),
+		}).WithDetails("offset", session.Offset)
+	}
+
+	tmpDir := i.storage.TempDirPath(uploadID)
+	f, err := os.OpenFile(filepath.Join(tmpDir, "upload.zip"), os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("open upload file: %v", err),
+		}
+	}
+
+	// Discard anything past the recorded offset left by an interrupted chunk
+	if err := f.Truncate(session.Offset); err != nil {
+		f.Close()
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("truncate upload file: %v", err),
+		}
+	}
+	if _, err := f.Seek(session.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("seek upload file: %v", err),
+		}
+	}
+
+	var src io.Reader = r
+	if session.Size > 0 {
+		// Never accept more than the declared size
+		src = io.LimitReader(r, session.Size-session.Offset+1)
+	}
+
+	written, copyErr := io.Copy(f, src)
+	if syncErr := f.Sync(); copyErr == nil {
+		copyErr = syncErr
+	}
+	f.Close()
+
+	if session.Size > 0 && session.Offset+written > session.Size {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeUploadOffsetMismatch,
+			Message: fmt.Sprintf("chunk exceeds declared upload size %d", session.Size),
+		}
+	}
+
+	// Record whatever arrived, even if the connection dropped mid-chunk,
+	// so the client can resume from the last byte on disk.
+	now := time.Now().UTC()
+	session.Offset += written
+	session.UpdatedAt = now
+	session.ExpiresAt = now.Add(i.uploadTTL)
+	if err := i.storage.SaveUploadSession(session); err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+
+	if copyErr != nil {
+		if fullErr := diskFull(copyErr); fullErr != nil {
+			return nil, fullErr
+		}
+		return nil, &models.APIError{
+			Code:    models.ErrCodeInvalidZip,
+			Message: fmt.Sprintf("failed to read chunk: %v", copyErr),
+		}
+	}
+
+	return session, nil
+}
+
+// CompleteUpload finalizes an upload session and ingests the staged archive
+// through the same hash/extract/insert path as IngestFromReader.
+func (i *Ingester) CompleteUpload(uploadID string) (*IngestResult, error) {
+	staged, err := i.StageUpload(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	return i.IngestStaged(staged, nil)
+}
+
+// StageUpload finalizes an upload session and returns it as a staged upload
+// ready for IngestStaged. The session can no longer receive chunks.
+func (i *Ingester) StageUpload(uploadID string) (*StagedUpload, error) {
+	session, unlock, err := i.lockUpload(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if session.Size > 0 && session.Offset != session.Size {
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeUploadIncomplete,
+			Message: fmt.Sprintf("received %d of %d bytes", session.Offset, session.Size),
+		}).WithDetails("offset", session.Offset)
+	}
+
+	tmpDir := i.storage.TempDirPath(uploadID)
+	zipPath := filepath.Join(tmpDir, "upload.zip")
+
+	contentHash, err := storage.HashFile(zipPath)
+	if err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("failed to hash upload: %v", err),
+		}
+	}
+
+	// Drop the session state so the directory is treated as a plain
+	// staging directory from here on
+	if err := os.Remove(filepath.Join(tmpDir, storage.UploadSessionFile)); err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: fmt.Sprintf("close upload session: %v", err),
+		}
+	}
+
+	return &StagedUpload{
+		Dir:         tmpDir,
+		Kind:        StagedZip,
+		ContentHash: contentHash,
+		Size:        session.Offset,
+	}, nil
+}
+
+// AbortUpload discards an upload session and its staged data.
+func (i *Ingester) AbortUpload(uploadID string) error {
+	_, unlock, err := i.lockUpload(uploadID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return i.storage.RemoveTempDir(i.storage.TempDirPath(uploadID))
+}
+
+// lockUpload loads the session of uploadID and serializes access to it
+// until the returned function is called. Only IDs of live sessions get a
+// lock, and the session is loaded again once it is held, since a request
+// that held it before may have changed or closed it.
+func (i *Ingester) lockUpload(uploadID string) (*models.UploadSession, func(), error) {
+	if _, err := i.loadUpload(uploadID); err != nil {
+		return nil, nil, err
+	}
+
+	unlock := i.uploads.lock(uploadID)
+	session, err := i.loadUpload(uploadID)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return session, unlock, nil
+}
+
+// loadUpload loads a session, returning UPLOAD_NOT_FOUND for unknown,
+// malformed or expired IDs.
+func (i *Ingester) loadUpload(uploadID string) (*models.UploadSession, error) {
+	notFound := &models.APIError{
+		Code:    models.ErrCodeUploadNotFound,
+		Message: "upload not found: " + uploadID,
+	}
+
+	if !isValidUploadID(uploadID) {
+		return nil, notFound
+	}
+
+	session, err := i.storage.LoadUploadSession(uploadID)
+	if err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+	if session == nil || time.Now().After(session.ExpiresAt) {
+		return nil, notFound
+	}
+
+	return session, nil
+}
+
+// isValidUploadID reports whether id is a 16-char lowercase hex string.
+// This keeps client-supplied IDs from escaping tmp/.
+func isValidUploadID(id string) bool {
+	return isLowerHex(id, 16)
+}
+
+// isLowerHex reports whether s is exactly n lowercase hex characters.
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
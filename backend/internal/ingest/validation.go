@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/validate"
+)
+
+// Validation policies decide what happens to a bundle whose consistency
+// checks report errors. Warnings never affect ingestion.
+const (
+	ValidationPolicyFlag       = "flag"       // Store and list normally, marked failed
+	ValidationPolicyQuarantine = "quarantine" // Store but hide from default listings
+	ValidationPolicyReject     = "reject"     // Refuse with VALIDATION_FAILED
+)
+
+// SetValidationPolicy sets how bundles that fail validation are handled.
+func (i *Ingester) SetValidationPolicy(policy string) error {
+	switch policy {
+	case ValidationPolicyFlag, ValidationPolicyQuarantine, ValidationPolicyReject:
+		i.policy = policy
+		return nil
+	default:
+		return fmt.Errorf("unknown validation policy %q (want flag, quarantine or reject)", policy)
+	}
+}
+
+// validateStaged runs validate.ValidateManifest on an extracted bundle with
+// the manifest ingest parsed from it, so validation accepts the same formats
+// as ingest, and applies the validation policy. Returns VALIDATION_FAILED
+// under the reject policy; otherwise the result to store with the bundle.
+func (i *Ingester) validateStaged(dir string, manifest *models.Manifest) (*models.BundleValidation, error) {
+	result := validate.ValidateManifest(dir, manifest)
+
+	// The staging path is gone once the bundle is committed
+	prefix := dir + string(filepath.Separator)
+	for n := range result.Errors {
+		result.Errors[n].Message = strings.ReplaceAll(result.Errors[n].Message, prefix, "")
+	}
+	for n := range result.Warnings {
+		result.Warnings[n].Message = strings.ReplaceAll(result.Warnings[n].Message, prefix, "")
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("marshal validation result: %w", err)
+	}
+
+	v := &models.BundleValidation{
+		Status:       models.ValidationPassed,
+		Valid:        len(result.Errors) == 0,
+		ErrorCount:   len(result.Errors),
+		WarningCount: len(result.Warnings),
+		Result:       data,
+	}
+	if v.Valid {
+		return v, nil
+	}
+
+	switch i.policy {
+	case ValidationPolicyReject:
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeValidationFailed,
+			Message: fmt.Sprintf("bundle failed validation with %d error(s): %s", len(result.Errors), result.Errors[0].Message),
+		}).WithDetails("errors", result.Errors)
+	case ValidationPolicyQuarantine:
+		v.Status = models.ValidationQuarantined
+	default:
+		v.Status = models.ValidationFailed
+	}
+	return v, nil
+}
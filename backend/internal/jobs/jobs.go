@@ -0,0 +1,266 @@
+// Package jobs runs repro bundle ingestion asynchronously on a bounded
+// worker pool. Jobs are persisted in SQLite and their staged uploads in
+// queue/, so queued work survives a restart.
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/ids"
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// Defaults for the ingest queue.
+const (
+	DefaultWorkers   = 2
+	DefaultQueueSize = 100
+
+	// How long finished jobs stay queryable
+	jobRetention = 7 * 24 * time.Hour
+
+	// Fallback poll interval in case a wakeup is missed
+	pollInterval = 5 * time.Second
+)
+
+// Queue is a persistent ingestion queue served by a fixed number of workers.
+type Queue struct {
+	db        *db.DB
+	storage   *storage.Storage
+	ingester  *ingest.Ingester
+	workers   int
+	queueSize int
+	logger    *slog.Logger
+
+	submitMu sync.Mutex
+	wake     chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a queue. workers and queueSize fall back to the defaults
+// when not positive.
+func New(database *db.DB, store *storage.Storage, ingester *ingest.Ingester, workers, queueSize int) *Queue {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Queue{
+		db:        database,
+		storage:   store,
+		ingester:  ingester,
+		workers:   workers,
+		queueSize: queueSize,
+		logger:    slog.Default(),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start requeues jobs interrupted by a previous shutdown, removes staged
+// uploads that no longer belong to a job and starts the workers.
+func (q *Queue) Start() error {
+	requeued, err := q.db.RequeueRunningIngestJobs()
+	if err != nil {
+		return fmt.Errorf("requeue jobs: %w", err)
+	}
+	if requeued > 0 {
+		q.logger.Info("requeued interrupted ingest jobs", "count", requeued)
+	}
+
+	if pruned, err := q.db.PruneIngestJobs(time.Now().Add(-jobRetention)); err != nil {
+		q.logger.Warn("failed to prune ingest jobs", "error", err)
+	} else if pruned > 0 {
+		q.logger.Info("pruned finished ingest jobs", "count", pruned)
+	}
+
+	active, err := q.db.ListActiveIngestJobIDs()
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	dirs, err := q.storage.ListQueueDirs()
+	if err != nil {
+		return fmt.Errorf("list queue: %w", err)
+	}
+	for _, jobID := range dirs {
+		if !active[jobID] {
+			q.logger.Info("removing orphaned queue directory", "job_id", jobID)
+			q.storage.RemoveTempDir(q.storage.QueueDirPath(jobID))
+		}
+	}
+
+	for n := 0; n < q.workers; n++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return nil
+}
+
+// Stop stops the workers, waiting for jobs in progress to finish.
+// Queued jobs stay in the database and resume on the next Start.
+func (q *Queue) Stop() {
+	close(q.done)
+	q.wg.Wait()
+}
+
+// Submit queues a staged upload for ingestion. The queue takes ownership of
+// the staging directory, removing it if the job cannot be queued.
+func (q *Queue) Submit(staged *ingest.StagedUpload) (*models.IngestJob, error) {
+	q.submitMu.Lock()
+	defer q.submitMu.Unlock()
+
+	queued, err := q.db.CountIngestJobs(models.JobStatusQueued)
+	if err != nil {
+		q.storage.RemoveTempDir(staged.Dir)
+		return nil, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: err.Error(),
+		}
+	}
+	if queued >= q.queueSize {
+		q.storage.RemoveTempDir(staged.Dir)
+		return nil, (&models.APIError{
+			Code:    models.ErrCodeQueueFull,
+			Message: fmt.Sprintf("ingest queue is full (%d jobs queued)", queued),
+		}).WithDetails("queue_size", q.queueSize)
+	}
+
+	jobID := "job_" + ids.Generate(16)
+
+	// The directory is not persisted; it is derived from the job ID so
+	// the data dir can move between restarts
+	stagedJSON, err := json.Marshal(&ingest.StagedUpload{
+		Kind:        staged.Kind,
+		ContentHash: staged.ContentHash,
+		Size:        staged.Size,
+		Headers:     staged.Headers,
+	})
+	if err != nil {
+		q.storage.RemoveTempDir(staged.Dir)
+		return nil, fmt.Errorf("marshal staged upload: %w", err)
+	}
+
+	queueDir, err := q.storage.MoveToQueue(staged.Dir, jobID)
+	if err != nil {
+		q.storage.RemoveTempDir(staged.Dir)
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: err.Error(),
+		}
+	}
+
+	job := &models.IngestJob{
+		JobID:  jobID,
+		Status: models.JobStatusQueued,
+		Stage:  models.JobStatusQueued,
+		Staged: stagedJSON,
+	}
+	if err := q.db.InsertIngestJob(job); err != nil {
+		q.storage.RemoveTempDir(queueDir)
+		return nil, &models.APIError{
+			Code:    models.ErrCodeDatabaseError,
+			Message: fmt.Sprintf("failed to queue job: %v", err),
+		}
+	}
+
+	// Wake an idle worker without blocking
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return q.db.GetIngestJob(jobID)
+}
+
+// work claims and runs jobs until Stop is called.
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+
+		job, err := q.db.ClaimIngestJob()
+		if err != nil {
+			q.logger.Error("failed to claim ingest job", "error", err)
+		}
+		if job != nil {
+			q.run(job)
+			continue
+		}
+
+		select {
+		case <-q.done:
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run ingests a claimed job and records the outcome.
+func (q *Queue) run(job *models.IngestJob) {
+	logger := q.logger.With("job_id", job.JobID)
+	start := time.Now()
+
+	result, err := q.ingest(job)
+
+	var apiErr *models.APIError
+	if err != nil {
+		var ok bool
+		if apiErr, ok = err.(*models.APIError); !ok {
+			apiErr = &models.APIError{
+				Code:    models.ErrCodeStorageError,
+				Message: err.Error(),
+			}
+		}
+		logger.Warn("ingest job failed", "code", apiErr.Code, "error", apiErr.Message)
+	} else {
+		logger.Info("ingest job finished",
+			"bundle_id", result.BundleID,
+			"status", result.Status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+
+	if err := q.db.FinishIngestJob(job.JobID, result, apiErr); err != nil {
+		logger.Error("failed to record ingest job result", "error", err)
+	}
+}
+
+func (q *Queue) ingest(job *models.IngestJob) (*ingest.IngestResult, error) {
+	var staged ingest.StagedUpload
+	if err := json.Unmarshal(job.Staged, &staged); err != nil {
+		q.storage.RemoveTempDir(q.storage.QueueDirPath(job.JobID))
+		return nil, fmt.Errorf("parse staged upload: %w", err)
+	}
+	staged.Dir = q.storage.QueueDirPath(job.JobID)
+
+	if _, err := os.Stat(staged.Dir); err != nil {
+		return nil, &models.APIError{
+			Code:    models.ErrCodeStorageError,
+			Message: "staged upload is missing; please upload the bundle again",
+		}
+	}
+
+	return q.ingester.IngestStaged(&staged, func(stage string, progress float64) {
+		if err := q.db.UpdateIngestJobProgress(job.JobID, stage, progress); err != nil {
+			q.logger.Warn("failed to update ingest job progress", "job_id", job.JobID, "error", err)
+		}
+	})
+}
@@ -0,0 +1,525 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Manifest is a bundle's manifest.json normalized into one shape, whatever
+// schema version and field style it was written in. Decode it with
+// ParseManifest; json.Unmarshal into a Manifest does the same.
+type Manifest struct {
+	SchemaVersion string // As written, e.g. "1.0.0"
+	MajorVersion  int    // Selects the decoder
+	BundleID      string
+	Timestamp     time.Time
+	Notes         string
+	BuildInfo     *ManifestBuildInfo
+	SessionInfo   *ManifestSession
+	HardwareInfo  *ManifestHardware
+	Artifacts     []ManifestArtifact
+	Metadata      json.RawMessage
+
+	// Recording length, written by the RVR SDK in camelCase v1 manifests
+	// only; nil when the manifest does not give it
+	DurationSeconds *float64
+	TotalFrames     *int
+
+	// Derived fields for DB storage (populated by the decoder)
+	BuildID    string
+	MapName    string
+	Platform   string
+	RVRVersion string
+}
+
+// ManifestBuildInfo contains build information
+type ManifestBuildInfo struct {
+	BuildID        string `json:"buildId"`
+	CommitHash     string `json:"commitHash,omitempty"`
+	Branch         string `json:"branch,omitempty"`
+	BuildConfig    string `json:"buildConfig,omitempty"`
+	EngineVersion  string `json:"engineVersion,omitempty"`
+	ProjectName    string `json:"projectName,omitempty"`
+	ProjectVersion string `json:"projectVersion,omitempty"`
+	RVRVersion     string `json:"rvrVersion,omitempty"`
+}
+
+// ManifestSession contains session information
+type ManifestSession struct {
+	SessionID    string `json:"sessionId,omitempty"`
+	MapName      string `json:"mapName,omitempty"`
+	GameModeName string `json:"gameModeName,omitempty"`
+	TesterName   string `json:"testerName,omitempty"`
+	TestCaseName string `json:"testCaseName,omitempty"`
+}
+
+// ManifestHardware contains hardware information
+type ManifestHardware struct {
+	Platform  string `json:"platform"`
+	OSVersion string `json:"osVersion,omitempty"`
+	CPUBrand  string `json:"cpuBrand,omitempty"`
+	GPUBrand  string `json:"gpuBrand,omitempty"`
+	RHIName   string `json:"rhiName,omitempty"`
+	DeviceID  string `json:"deviceId,omitempty"`
+}
+
+// ManifestArtifact describes an artifact in the manifest.
+type ManifestArtifact struct {
+	Filename string `json:"filename"`
+	Type     string `json:"type"`
+	MimeType string `json:"mime_type,omitempty"`
+	Checksum string `json:"checksum,omitempty"` // Expected SHA-256, "sha256:<hex>" or bare hex
+
+	// Declared by v2 manifests only
+	Role string `json:"-"`
+	Size *int64 `json:"-"`
+}
+
+// Artifact roles a v2 manifest may declare, with the artifact type each is
+// stored as.
+var artifactRoles = map[string]string{
+	"video":         "video",
+	"thumbnail":     "thumbnail",
+	"screenshot":    "screenshot",
+	"log":           "log",
+	"crash_dump":    "crash_dump",
+	"crash_context": "other",
+	"timing":        "other",
+	"inputs":        "other",
+	"hardware":      "other",
+	"other":         "other",
+}
+
+// ArtifactRoles returns the artifact roles a v2 manifest may declare.
+func ArtifactRoles() []string {
+	roles := make([]string, 0, len(artifactRoles))
+	for role := range artifactRoles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// manifestHeader is what ParseManifest reads before choosing a decoder.
+type manifestHeader struct {
+	Version   string
+	Major     int
+	SnakeCase bool // Version was given as schema_version
+}
+
+// manifestDecoder decodes one major schema version into a Manifest.
+type manifestDecoder func(data []byte, header manifestHeader) (*Manifest, error)
+
+// manifestDecoders holds a decoder per supported major schema version.
+// Minor versions are backward compatible and share their major's decoder.
+var manifestDecoders = map[int]manifestDecoder{
+	1: decodeManifestV1,
+	2: decodeManifestV2,
+}
+
+// SupportedManifestVersions lists the accepted schema versions, e.g. "1.x".
+func SupportedManifestVersions() []string {
+	majors := make([]int, 0, len(manifestDecoders))
+	for major := range manifestDecoders {
+		majors = append(majors, major)
+	}
+	sort.Ints(majors)
+
+	versions := make([]string, len(majors))
+	for i, major := range majors {
+		versions[i] = strconv.Itoa(major) + ".x"
+	}
+	return versions
+}
+
+// UnsupportedSchemaError is returned by ParseManifest for a schema version
+// whose major version has no decoder.
+type UnsupportedSchemaError struct {
+	Version   string
+	Supported []string
+}
+
+func (e *UnsupportedSchemaError) Error() string {
+	return fmt.Sprintf("unsupported schemaVersion %q; supported versions: %s",
+		e.Version, strings.Join(e.Supported, ", "))
+}
+
+// ParseManifest decodes manifest.json with the decoder for its major schema
+// version. Malformed JSON is returned as the encoding/json error, a missing or
+// invalid field as a *ValidationError, and an unknown major version as an
+// *UnsupportedSchemaError.
+func ParseManifest(data []byte) (*Manifest, error) {
+	header, err := readManifestHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decode, ok := manifestDecoders[header.Major]
+	if !ok {
+		return nil, &UnsupportedSchemaError{
+			Version:   header.Version,
+			Supported: SupportedManifestVersions(),
+		}
+	}
+
+	m, err := decode(data, header)
+	if err != nil {
+		return nil, err
+	}
+	m.SchemaVersion = header.Version
+	m.MajorVersion = header.Major
+	return m, nil
+}
+
+// UnmarshalJSON decodes a manifest with ParseManifest.
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	*m = *parsed
+	return nil
+}
+
+// readManifestHeader reads the schema version, given as schemaVersion or
+// schema_version, as a string or a number.
+func readManifestHeader(data []byte) (manifestHeader, error) {
+	var probe struct {
+		Camel json.RawMessage `json:"schemaVersion"`
+		Snake json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return manifestHeader{}, err
+	}
+
+	header := manifestHeader{}
+	field := "schemaVersion"
+	raw := probe.Camel
+	if isJSONNull(raw) {
+		raw = probe.Snake
+		field = "schema_version"
+		header.SnakeCase = true
+	}
+	if isJSONNull(raw) {
+		return header, &ValidationError{Field: "schemaVersion", Message: "required"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return header, err
+	}
+	switch v := value.(type) {
+	case string:
+		header.Version = strings.TrimSpace(v)
+	case json.Number:
+		header.Version = v.String()
+	default:
+		return header, &ValidationError{Field: field, Message: "must be a string such as \"1.0\""}
+	}
+
+	majorPart, _, _ := strings.Cut(header.Version, ".")
+	major, err := strconv.Atoi(majorPart)
+	if err != nil || major < 0 {
+		return header, &ValidationError{Field: field, Message: "invalid version: " + header.Version}
+	}
+	header.Major = major
+	return header, nil
+}
+
+// manifestV1 is the camelCase v1 layout written by the RVR BugIt SDK.
+type manifestV1 struct {
+	BundleID           string             `json:"bundleId"`
+	ReportTimestampUtc int64              `json:"reportTimestampUtc"` // Unix ms
+	Notes              string             `json:"notes"`
+	MapName            string             `json:"mapName"`
+	Platform           string             `json:"platform"`
+	BuildInfo          *ManifestBuildInfo `json:"buildInfo"`
+	SessionInfo        *ManifestSession   `json:"sessionInfo"`
+	HardwareInfo       *ManifestHardware  `json:"hardwareInfo"`
+	Artifacts          []json.RawMessage  `json:"artifacts"`
+	Metadata           json.RawMessage    `json:"metadata"`
+	DurationSeconds    *float64           `json:"durationSeconds"`
+	TotalFrames        *int               `json:"totalFrames"`
+}
+
+// manifestV1Snake is the snake_case v1 layout ("standard format").
+type manifestV1Snake struct {
+	BundleID   string            `json:"bundle_id"`
+	BuildID    string            `json:"build_id"`
+	MapName    string            `json:"map_name"`
+	Platform   string            `json:"platform"`
+	Timestamp  string            `json:"timestamp"` // RFC 3339
+	RVRVersion string            `json:"rvr_version"`
+	Notes      string            `json:"notes"`
+	Artifacts  []json.RawMessage `json:"artifacts"`
+	Metadata   json.RawMessage   `json:"metadata"`
+}
+
+// decodeManifestV1 decodes either v1 layout. The field style is taken from
+// how the schema version was spelled.
+func decodeManifestV1(data []byte, header manifestHeader) (*Manifest, error) {
+	if header.SnakeCase {
+		return decodeManifestV1Snake(data)
+	}
+
+	var raw manifestV1
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		BundleID:        raw.BundleID,
+		Notes:           raw.Notes,
+		BuildInfo:       raw.BuildInfo,
+		SessionInfo:     raw.SessionInfo,
+		HardwareInfo:    raw.HardwareInfo,
+		Metadata:        raw.Metadata,
+		MapName:         raw.MapName,
+		Platform:        raw.Platform,
+		DurationSeconds: raw.DurationSeconds,
+		TotalFrames:     raw.TotalFrames,
+	}
+
+	// Convert Unix milliseconds to time.Time
+	if raw.ReportTimestampUtc > 0 {
+		m.Timestamp = time.UnixMilli(raw.ReportTimestampUtc)
+	}
+
+	// Nested structures take precedence over the top-level fields
+	if raw.BuildInfo != nil {
+		m.BuildID = raw.BuildInfo.BuildID
+		m.RVRVersion = raw.BuildInfo.RVRVersion
+	}
+	if raw.SessionInfo != nil && raw.SessionInfo.MapName != "" {
+		m.MapName = raw.SessionInfo.MapName
+	}
+	if raw.HardwareInfo != nil && raw.HardwareInfo.Platform != "" {
+		m.Platform = raw.HardwareInfo.Platform
+	}
+
+	artifacts, err := decodeV1Artifacts(raw.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+	m.Artifacts = artifacts
+	return m, nil
+}
+
+func decodeManifestV1Snake(data []byte) (*Manifest, error) {
+	var raw manifestV1Snake
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		BundleID:   raw.BundleID,
+		Notes:      raw.Notes,
+		Metadata:   raw.Metadata,
+		BuildID:    raw.BuildID,
+		MapName:    raw.MapName,
+		Platform:   raw.Platform,
+		RVRVersion: raw.RVRVersion,
+	}
+
+	if raw.Timestamp != "" {
+		ts, err := time.Parse(time.RFC3339, raw.Timestamp)
+		if err != nil {
+			return nil, &ValidationError{Field: "timestamp", Message: "must be an RFC 3339 time: " + raw.Timestamp}
+		}
+		m.Timestamp = ts
+	}
+
+	artifacts, err := decodeV1Artifacts(raw.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+	m.Artifacts = artifacts
+	return m, nil
+}
+
+// decodeV1Artifacts decodes v1 artifact entries. Each is either a filename,
+// as written by Unreal, or an artifact object.
+func decodeV1Artifacts(entries []json.RawMessage) ([]ManifestArtifact, error) {
+	artifacts := make([]ManifestArtifact, 0, len(entries))
+	for i, entry := range entries {
+		var filename string
+		if err := json.Unmarshal(entry, &filename); err == nil {
+			artifacts = append(artifacts, ManifestArtifact{
+				Filename: filename,
+				Type:     GuessArtifactType(filename),
+				MimeType: GuessMimeType(filename),
+			})
+			continue
+		}
+
+		var artifact ManifestArtifact
+		if err := json.Unmarshal(entry, &artifact); err != nil || artifact.Filename == "" {
+			return nil, &ValidationError{
+				Field:   fmt.Sprintf("artifacts[%d]", i),
+				Message: "must be a filename or an object with a filename",
+			}
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// manifestV2 is the v2 layout. It keeps the v1 camelCase structure but
+// requires a build ID and platform, takes an RFC 3339 creation time, and
+// declares every artifact with a typed role and its own hashes.
+type manifestV2 struct {
+	BundleID     string               `json:"bundleId"`
+	CreatedAt    string               `json:"createdAt"` // RFC 3339
+	Notes        string               `json:"notes"`
+	BuildInfo    *ManifestBuildInfo   `json:"buildInfo"`
+	SessionInfo  *ManifestSession     `json:"sessionInfo"`
+	HardwareInfo *ManifestHardware    `json:"hardwareInfo"`
+	Artifacts    []manifestV2Artifact `json:"artifacts"`
+	Metadata     json.RawMessage      `json:"metadata"`
+}
+
+// manifestV2Artifact is an artifact entry of a v2 manifest.
+type manifestV2Artifact struct {
+	Path      string            `json:"path"`
+	Role      string            `json:"role"`
+	MediaType string            `json:"mediaType"`
+	Size      *int64            `json:"size"`
+	Hashes    map[string]string `json:"hashes"` // Algorithm to hex digest; sha256 is required
+}
+
+func decodeManifestV2(data []byte, header manifestHeader) (*Manifest, error) {
+	if header.SnakeCase {
+		return nil, &ValidationError{Field: "schema_version", Message: "v2 manifests use camelCase fields (schemaVersion)"}
+	}
+
+	var raw manifestV2
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if raw.BuildInfo == nil || raw.BuildInfo.BuildID == "" {
+		return nil, &ValidationError{Field: "buildInfo.buildId", Message: "required"}
+	}
+	if raw.HardwareInfo == nil || raw.HardwareInfo.Platform == "" {
+		return nil, &ValidationError{Field: "hardwareInfo.platform", Message: "required"}
+	}
+
+	m := &Manifest{
+		BundleID:     raw.BundleID,
+		Notes:        raw.Notes,
+		BuildInfo:    raw.BuildInfo,
+		SessionInfo:  raw.SessionInfo,
+		HardwareInfo: raw.HardwareInfo,
+		Metadata:     raw.Metadata,
+		BuildID:      raw.BuildInfo.BuildID,
+		RVRVersion:   raw.BuildInfo.RVRVersion,
+		Platform:     raw.HardwareInfo.Platform,
+	}
+	if raw.SessionInfo != nil {
+		m.MapName = raw.SessionInfo.MapName
+	}
+
+	if raw.CreatedAt != "" {
+		ts, err := time.Parse(time.RFC3339, raw.CreatedAt)
+		if err != nil {
+			return nil, &ValidationError{Field: "createdAt", Message: "must be an RFC 3339 time: " + raw.CreatedAt}
+		}
+		m.Timestamp = ts
+	}
+
+	m.Artifacts = make([]ManifestArtifact, 0, len(raw.Artifacts))
+	for i, a := range raw.Artifacts {
+		field := fmt.Sprintf("artifacts[%d]", i)
+		if a.Path == "" {
+			return nil, &ValidationError{Field: field + ".path", Message: "required"}
+		}
+		artifactType, ok := artifactRoles[a.Role]
+		if !ok {
+			return nil, &ValidationError{
+				Field:   field + ".role",
+				Message: fmt.Sprintf("unknown role %q; expected one of %s", a.Role, strings.Join(ArtifactRoles(), ", ")),
+			}
+		}
+		sha256 := strings.ToLower(a.Hashes["sha256"])
+		if !isSHA256Hex(sha256) {
+			return nil, &ValidationError{Field: field + ".hashes.sha256", Message: "required: 64 hex characters"}
+		}
+		if a.Size != nil && *a.Size < 0 {
+			return nil, &ValidationError{Field: field + ".size", Message: "must not be negative"}
+		}
+
+		mimeType := a.MediaType
+		if mimeType == "" {
+			mimeType = GuessMimeType(a.Path)
+		}
+		m.Artifacts = append(m.Artifacts, ManifestArtifact{
+			Filename: a.Path,
+			Type:     artifactType,
+			MimeType: mimeType,
+			Checksum: "sha256:" + sha256,
+			Role:     a.Role,
+			Size:     a.Size,
+		})
+	}
+
+	return m, nil
+}
+
+// Validate checks the manifest for required fields and fills in defaults.
+// The schema version is checked by ParseManifest.
+func (m *Manifest) Validate() error {
+	if m.SchemaVersion == "" {
+		return &ValidationError{Field: "schemaVersion", Message: "required"}
+	}
+	// BuildID can come from nested buildInfo or direct field
+	if m.BuildID == "" {
+		// Try to use bundleId as fallback
+		if m.BundleID != "" {
+			m.BuildID = m.BundleID
+		} else {
+			return &ValidationError{Field: "buildId", Message: "required (in buildInfo or as bundleId)"}
+		}
+	}
+	// Platform can come from nested hardwareInfo - default to "Other" if missing
+	if m.Platform == "" {
+		m.Platform = "Other"
+	}
+	// Timestamp is optional - use current time if not provided
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	return nil
+}
+
+// ValidationError represents a manifest validation failure.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return "validation error: " + e.Field + ": " + e.Message
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
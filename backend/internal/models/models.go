@@ -12,6 +12,7 @@ type ReproBundle struct {
 	ID              int64           `json:"-"`
 	BundleID        string          `json:"bundle_id"`
 	ContentHash     string          `json:"content_hash"`
+	CanonicalHash   string          `json:"canonical_hash,omitempty"`
 	SchemaVersion   string          `json:"schema_version"`
 	BuildID         string          `json:"build_id"`
 	MapName         string          `json:"map_name,omitempty"`
@@ -24,12 +25,52 @@ type ReproBundle struct {
 	StoragePath     string          `json:"-"`
 	CreatedAt       time.Time       `json:"created_at"`
 
+	// Validation status at ingest: passed, failed, quarantined, or empty
+	// for bundles ingested before validation was recorded
+	ValidationStatus string `json:"validation_status,omitempty"`
+
+	// HasCrash is set when a crash context was parsed from the bundle
+	HasCrash bool `json:"has_crash"`
+
+	// IssueID is the issue the bundle's crash signature groups it into
+	IssueID string `json:"issue_id,omitempty"`
+
 	// Populated on detail queries
 	Artifacts []Artifact `json:"artifacts,omitempty"`
 	Tags      []string   `json:"tags,omitempty"`
 	Notes     []QANote   `json:"qa_notes,omitempty"`
+	Crashes   []Crash    `json:"crashes,omitempty"`
+
+	// Crash signature; also written by InsertBundle when set
+	Signature *CrashSignature `json:"signature,omitempty"`
+
+	// Set on detail queries when fsck found the bundle's files damaged
+	Damage *BundleDamage `json:"damage,omitempty"`
+
+	// Written by InsertBundle when set
+	Validation *BundleValidation `json:"-"`
+	Minidumps  []Minidump        `json:"-"`
+}
+
+// BundleValidation is the stored result of validating a bundle at ingest.
+type BundleValidation struct {
+	BundleID     string          `json:"bundle_id"`
+	Status       string          `json:"status"`
+	Valid        bool            `json:"valid"`
+	ErrorCount   int             `json:"error_count"`
+	WarningCount int             `json:"warning_count"`
+	Result       json.RawMessage `json:"result"`
+	ValidatedAt  time.Time       `json:"validated_at"`
 }
 
+// Bundle validation statuses
+const (
+	ValidationPassed      = "passed"
+	ValidationFailed      = "failed"
+	ValidationQuarantined = "quarantined"
+	ValidationUnvalidated = "unvalidated" // List filter only: no stored result
+)
+
 // Artifact represents a file within a repro bundle.
 type Artifact struct {
 	ID           int64     `json:"-"`
@@ -42,6 +83,159 @@ type Artifact struct {
 	StoragePath  string    `json:"-"`
 	Checksum     string    `json:"checksum,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	// Undeclared is set for files present in the bundle but not listed in
+	// the manifest
+	Undeclared bool `json:"undeclared,omitempty"`
+
+	// Role is the artifact's role as declared by a v2 manifest
+	Role string `json:"role,omitempty"`
+}
+
+// Crash is a crash record parsed from a CrashContext.runtime-xml file in a
+// bundle.
+type Crash struct {
+	ID            int64        `json:"-"`
+	CrashID       string       `json:"crash_id"`
+	BundleID      string       `json:"-"`
+	Source        string       `json:"source"`     // Path of the crash context file within the bundle
+	CrashType     string       `json:"crash_type"` // Crash, Assert, Ensure, Hang, GPUCrash, ...
+	ErrorMessage  string       `json:"error_message,omitempty"`
+	CrashGUID     string       `json:"crash_guid,omitempty"`
+	ExecutionGUID string       `json:"execution_guid,omitempty"`
+	EngineVersion string       `json:"engine_version,omitempty"`
+	BuildVersion  string       `json:"build_version,omitempty"`
+	BuildConfig   string       `json:"build_config,omitempty"`
+	Platform      string       `json:"platform,omitempty"`
+	GameName      string       `json:"game_name,omitempty"`
+	Callstack     []CrashFrame `json:"callstack"`
+	Modules       []string     `json:"modules"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// CrashFrame is one frame of a crash callstack, innermost first.
+type CrashFrame struct {
+	Module   string `json:"module,omitempty"`
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+// Minidump is the analysis of a crash_dump artifact. Addresses are hex
+// strings because 64-bit values do not survive JSON numbers.
+type Minidump struct {
+	ID               int64              `json:"-"`
+	ArtifactID       string             `json:"artifact_id"`
+	BundleID         string             `json:"bundle_id"`
+	Source           string             `json:"source"` // Filename of the artifact within the bundle
+	DumpTime         *time.Time         `json:"dump_time,omitempty"`
+	System           MinidumpSystem     `json:"system"`
+	Exception        *MinidumpException `json:"exception,omitempty"`
+	CrashingThreadID *uint32            `json:"crashing_thread_id,omitempty"`
+	Modules          []MinidumpModule   `json:"modules"`
+	Threads          []MinidumpThread   `json:"threads"`
+	AnalyzedAt       time.Time          `json:"analyzed_at"`
+}
+
+// MinidumpSystem is the system info stream of a minidump.
+type MinidumpSystem struct {
+	CPUArch        string `json:"cpu_arch"` // x86, amd64, arm, arm64 or unknown(<n>)
+	ProcessorCount int    `json:"processor_count,omitempty"`
+	OS             string `json:"os"` // windows, linux, android, macos, ios or unknown(<n>)
+	OSVersion      string `json:"os_version,omitempty"`
+	ServicePack    string `json:"service_pack,omitempty"`
+}
+
+// MinidumpException is the exception record of a minidump.
+type MinidumpException struct {
+	Code         string   `json:"code"`           // e.g. 0xC0000005
+	Name         string   `json:"name,omitempty"` // e.g. EXCEPTION_ACCESS_VIOLATION or SIGSEGV
+	Flags        uint32   `json:"flags"`
+	Address      string   `json:"address"`
+	Module       string   `json:"module,omitempty"` // Module containing Address
+	ModuleOffset string   `json:"module_offset,omitempty"`
+	Parameters   []string `json:"parameters,omitempty"`
+
+	// Access violations only: read, write or execute, and the address
+	AccessType    string `json:"access_type,omitempty"`
+	AccessAddress string `json:"access_address,omitempty"`
+}
+
+// MinidumpModule is a module loaded in the crashed process.
+type MinidumpModule struct {
+	Name        string     `json:"name"` // Base name of Path
+	Path        string     `json:"path"`
+	BaseAddress string     `json:"base_address"`
+	Size        uint32     `json:"size"`
+	Version     string     `json:"version,omitempty"`   // File version, e.g. 5.3.2.0
+	Timestamp   *time.Time `json:"timestamp,omitempty"` // Link time of PE images
+	DebugFile   string     `json:"debug_file,omitempty"`
+	DebugID     string     `json:"debug_id,omitempty"` // PDB GUID and age, as symbol servers expect
+}
+
+// MinidumpThread is a thread of the crashed process with the frames found
+// on its raw stack.
+type MinidumpThread struct {
+	ThreadID           uint32          `json:"thread_id"`
+	Crashed            bool            `json:"crashed,omitempty"`
+	StackStart         string          `json:"stack_start"`
+	StackSize          uint32          `json:"stack_size"`
+	InstructionPointer string          `json:"instruction_pointer,omitempty"`
+	StackPointer       string          `json:"stack_pointer,omitempty"`
+	Frames             []MinidumpFrame `json:"frames"`
+}
+
+// MinidumpFrame is a code address attributed to a module. Frames are not
+// unwound: the first comes from the thread context, the rest from scanning
+// the stack for values that point into a module, so some may be stale.
+type MinidumpFrame struct {
+	Address string `json:"address"`
+	Module  string `json:"module"`
+	Offset  string `json:"offset"`
+	Trust   string `json:"trust"` // context or scan
+}
+
+// CrashSignature is the fingerprint of a bundle's crash. Bundles with the
+// same signature are grouped into one issue.
+type CrashSignature struct {
+	Signature string `json:"signature"` // 16 hex characters
+	Source    string `json:"source"`    // crash_context, minidump or log
+	Text      string `json:"text"`      // Normalized description that was hashed
+	Title     string `json:"title"`
+}
+
+// Issue is a group of bundles showing the same crash.
+type Issue struct {
+	ID             int64            `json:"-"`
+	IssueID        string           `json:"issue_id"`
+	Title          string           `json:"title"`
+	BundleCount    int              `json:"bundle_count"`
+	FirstSeen      *time.Time       `json:"first_seen,omitempty"`
+	LastSeen       *time.Time       `json:"last_seen,omitempty"`
+	FirstSeenBuild string           `json:"first_seen_build,omitempty"`
+	LastSeenBuild  string           `json:"last_seen_build,omitempty"`
+	Platforms      []string         `json:"platforms"`
+	Maps           []string         `json:"maps"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Signatures     []CrashSignature `json:"signatures,omitempty"` // Detail queries only
+}
+
+// IssueListQuery defines parameters for listing issues.
+type IssueListQuery struct {
+	BuildID  string // Issues seen in this build
+	Platform string // Issues seen on this platform
+	Sort     string // last_seen (default), first_seen or count
+	Limit    int
+	Offset   int
+}
+
+// IssueListResult contains paginated issue results.
+type IssueListResult struct {
+	Issues []Issue `json:"issues"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
 }
 
 // Tag represents a label on a bundle.
@@ -62,134 +256,8 @@ type QANote struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// Manifest represents the manifest.json structure in a repro bundle.
-// Matches Unreal's nested structure with camelCase field names.
-type Manifest struct {
-	SchemaVersion      string             `json:"schemaVersion"`
-	BundleID           string             `json:"bundleId,omitempty"`
-	ContentHash        string             `json:"contentHash,omitempty"`
-	ReportTimestampUtc int64              `json:"reportTimestampUtc,omitempty"` // Unix ms from Unreal
-	Timestamp          time.Time          `json:"-"`                            // Derived from ReportTimestampUtc
-	Notes              string             `json:"notes,omitempty"`
-	BuildInfo          *ManifestBuildInfo `json:"buildInfo,omitempty"`
-	SessionInfo        *ManifestSession   `json:"sessionInfo,omitempty"`
-	HardwareInfo       *ManifestHardware  `json:"hardwareInfo,omitempty"`
-	Artifacts          []ManifestArtifact `json:"-"` // Custom unmarshal
-	RawArtifacts       json.RawMessage    `json:"artifacts"`
-	Metadata           json.RawMessage    `json:"metadata,omitempty"`
-
-	// Derived fields for DB storage (populated after parsing)
-	BuildID    string `json:"-"`
-	MapName    string `json:"-"`
-	Platform   string `json:"-"`
-	RVRVersion string `json:"-"`
-}
-
-// ManifestBuildInfo contains build information
-type ManifestBuildInfo struct {
-	BuildID        string `json:"buildId"`
-	CommitHash     string `json:"commitHash,omitempty"`
-	Branch         string `json:"branch,omitempty"`
-	BuildConfig    string `json:"buildConfig,omitempty"`
-	EngineVersion  string `json:"engineVersion,omitempty"`
-	ProjectName    string `json:"projectName,omitempty"`
-	ProjectVersion string `json:"projectVersion,omitempty"`
-	RVRVersion     string `json:"rvrVersion,omitempty"`
-}
-
-// ManifestSession contains session information
-type ManifestSession struct {
-	SessionID    string `json:"sessionId,omitempty"`
-	MapName      string `json:"mapName,omitempty"`
-	GameModeName string `json:"gameModeName,omitempty"`
-	TesterName   string `json:"testerName,omitempty"`
-	TestCaseName string `json:"testCaseName,omitempty"`
-}
-
-// ManifestHardware contains hardware information
-type ManifestHardware struct {
-	Platform  string `json:"platform"`
-	OSVersion string `json:"osVersion,omitempty"`
-	CPUBrand  string `json:"cpuBrand,omitempty"`
-	GPUBrand  string `json:"gpuBrand,omitempty"`
-	RHIName   string `json:"rhiName,omitempty"`
-	DeviceID  string `json:"deviceId,omitempty"`
-}
-
-// UnmarshalJSON implements custom unmarshaling to handle Unreal's manifest format
-func (m *Manifest) UnmarshalJSON(data []byte) error {
-	// Use an alias to avoid infinite recursion
-	type ManifestAlias Manifest
-	aux := &struct {
-		*ManifestAlias
-	}{
-		ManifestAlias: (*ManifestAlias)(m),
-	}
-
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
-	}
-
-	// Convert Unix milliseconds to time.Time
-	if m.ReportTimestampUtc > 0 {
-		m.Timestamp = time.UnixMilli(m.ReportTimestampUtc)
-	}
-
-	// Populate derived fields from nested structures
-	if m.BuildInfo != nil {
-		if m.BuildInfo.BuildID != "" {
-			m.BuildID = m.BuildInfo.BuildID
-		}
-		m.RVRVersion = m.BuildInfo.RVRVersion
-	}
-	if m.SessionInfo != nil {
-		m.MapName = m.SessionInfo.MapName
-	}
-	if m.HardwareInfo != nil {
-		m.Platform = m.HardwareInfo.Platform
-	}
-
-	// Fallback: use bundleId as buildId if buildInfo.buildId is empty
-	if m.BuildID == "" && m.BundleID != "" {
-		m.BuildID = m.BundleID
-	}
-
-	// Now parse artifacts - can be string[] or ManifestArtifact[]
-	if len(m.RawArtifacts) > 0 {
-		// Try array of objects first
-		var artifacts []ManifestArtifact
-		if err := json.Unmarshal(m.RawArtifacts, &artifacts); err == nil {
-			m.Artifacts = artifacts
-			return nil
-		}
-
-		// Try array of strings (Unreal's format)
-		var filenames []string
-		if err := json.Unmarshal(m.RawArtifacts, &filenames); err == nil {
-			m.Artifacts = make([]ManifestArtifact, len(filenames))
-			for i, filename := range filenames {
-				m.Artifacts[i] = ManifestArtifact{
-					Filename: filename,
-					Type:     guessArtifactType(filename),
-					MimeType: guessMimeType(filename),
-				}
-			}
-			return nil
-		}
-	}
-
-	return nil
-}
-
-// ManifestArtifact describes an artifact in the manifest.
-type ManifestArtifact struct {
-	Filename string `json:"filename"`
-	Type     string `json:"type"`
-	MimeType string `json:"mime_type,omitempty"`
-}
-
-// guessArtifactType infers artifact type from filename
-func guessArtifactType(filename string) string {
+// GuessArtifactType infers artifact type from filename
+func GuessArtifactType(filename string) string {
 	switch {
 	case strings.HasSuffix(filename, ".mp4"), strings.HasSuffix(filename, ".webm"):
 		return "video"
@@ -209,8 +277,8 @@ func guessArtifactType(filename string) string {
 	}
 }
 
-// guessMimeType infers MIME type from filename
-func guessMimeType(filename string) string {
+// GuessMimeType infers MIME type from filename
+func GuessMimeType(filename string) string {
 	switch {
 	case strings.HasSuffix(filename, ".mp4"):
 		return "video/mp4"
@@ -229,45 +297,6 @@ func guessMimeType(filename string) string {
 	}
 }
 
-// Validate checks the manifest for required fields.
-func (m *Manifest) Validate() error {
-	if m.SchemaVersion == "" {
-		return &ValidationError{Field: "schemaVersion", Message: "required"}
-	}
-	// Accept 1.0, 1.0.0, or any 1.x version
-	if !strings.HasPrefix(m.SchemaVersion, "1.") && m.SchemaVersion != "1" {
-		return &ValidationError{Field: "schemaVersion", Message: "unsupported version: " + m.SchemaVersion}
-	}
-	// BuildID can come from nested buildInfo or direct field
-	if m.BuildID == "" {
-		// Try to use bundleId as fallback
-		if m.BundleID != "" {
-			m.BuildID = m.BundleID
-		} else {
-			return &ValidationError{Field: "buildId", Message: "required (in buildInfo or as bundleId)"}
-		}
-	}
-	// Platform can come from nested hardwareInfo - default to "Other" if missing
-	if m.Platform == "" {
-		m.Platform = "Other"
-	}
-	// Timestamp is optional - use current time if not provided
-	if m.Timestamp.IsZero() {
-		m.Timestamp = time.Now()
-	}
-	return nil
-}
-
-// ValidationError represents a manifest validation failure.
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
-}
-
-func (e *ValidationError) Error() string {
-	return "validation error: " + e.Field + ": " + e.Message
-}
-
 // BundleListQuery defines parameters for listing bundles.
 type BundleListQuery struct {
 	BuildID  string
@@ -276,6 +305,21 @@ type BundleListQuery struct {
 	Since    *time.Time
 	Limit    int
 	Offset   int
+
+	// Validity filters. Quarantined bundles are excluded unless asked for.
+	Valid            *bool
+	ValidationStatus string
+
+	// Crash filter: bundles with (true) or without (false) a parsed crash
+	HasCrash *bool
+
+	// Minidump filters: exception code (e.g. 0xC0000005) and the module the
+	// exception address falls in, matched case-insensitively
+	ExceptionCode string
+	CrashModule   string
+
+	// Bundles grouped into this issue
+	IssueID string
 }
 
 // BundleListResult contains paginated bundle results.
@@ -294,12 +338,153 @@ type IngestResult struct {
 	CreatedAt     string `json:"created_at"`
 }
 
+// UploadSession tracks a resumable chunked upload staged in tmp/upload_<id>.
+type UploadSession struct {
+	UploadID  string    `json:"upload_id"`
+	Offset    int64     `json:"offset"`
+	Size      int64     `json:"size,omitempty"` // Declared total size, 0 if unknown
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// QuarantineEntry describes a rejected upload kept in quarantine/ so it can
+// be inspected, downloaded or ingested again.
+type QuarantineEntry struct {
+	QuarantineID string            `json:"quarantine_id"`
+	Kind         string            `json:"kind"`             // zip or files, as staged
+	Format       string            `json:"format,omitempty"` // Archive format detected for zip uploads
+	ContentHash  string            `json:"content_hash"`
+	SizeBytes    int64             `json:"size_bytes"`
+	Error        *APIError         `json:"error"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Attempts     int               `json:"attempts"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+
+	// Filled in by storage.LoadQuarantine
+	Files []QuarantineFile `json:"files,omitempty"`
+}
+
+// QuarantineFile is one file of a quarantined upload.
+type QuarantineFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// IngestJob tracks an asynchronous ingestion.
+type IngestJob struct {
+	ID        int64           `json:"-"`
+	JobID     string          `json:"job_id"`
+	Status    string          `json:"status"`
+	Stage     string          `json:"stage"`
+	Progress  float64         `json:"progress"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *APIError       `json:"error,omitempty"`
+	Staged    json.RawMessage `json:"-"` // What to ingest, owned by the job queue
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Ingest job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// RetentionAuditEntry records a bundle deleted by retention garbage
+// collection.
+type RetentionAuditEntry struct {
+	BundleID        string     `json:"bundle_id"`
+	BuildID         string     `json:"build_id"`
+	Platform        string     `json:"platform"`
+	SizeBytes       int64      `json:"size_bytes"`
+	StoragePath     string     `json:"-"`
+	Reason          string     `json:"reason"`
+	BundleCreatedAt time.Time  `json:"bundle_created_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"` // Unset in dry runs
+}
+
+// Problems bugit fsck finds between the database and stored files.
+const (
+	ProblemMissingBundle    = "missing_bundle"    // Bundle row without any stored files
+	ProblemMissingFile      = "missing_file"      // Artifact row without its file
+	ProblemSizeMismatch     = "size_mismatch"     // File size differs from the artifact row
+	ProblemChecksumMismatch = "checksum_mismatch" // File hash differs from the artifact row
+	ProblemUnlistedFile     = "unlisted_file"     // File in a bundle without an artifact row
+	ProblemOrphanDir        = "orphan_dir"        // Bundle directory without a bundle row
+	ProblemOrphanUpload     = "orphan_upload"     // Staging directory of an abandoned upload
+)
+
+// IntegrityProblem is a mismatch between the database and stored files.
+type IntegrityProblem struct {
+	Kind       string `json:"kind"`
+	BundleID   string `json:"bundle_id,omitempty"`
+	ArtifactID string `json:"artifact_id,omitempty"`
+	Path       string `json:"path"` // Relative to the data directory or bucket prefix
+	Expected   string `json:"expected,omitempty"`
+	Actual     string `json:"actual,omitempty"`
+
+	// What fsck --repair did about it: registered, marked_damaged or removed
+	Repair string `json:"repair,omitempty"`
+}
+
+// BundleDamage records the problems fsck found with a bundle's files.
+type BundleDamage struct {
+	Problems   []IntegrityProblem `json:"problems"`
+	DetectedAt time.Time          `json:"detected_at"`
+}
+
+// AnnotationsVersion is the format version of an annotations export.
+const AnnotationsVersion = 1
+
+// Annotations is an export of the tags and notes QA added to bundles, which
+// are not stored with the bundle files and so cannot be reindexed.
+type Annotations struct {
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Bundles    []BundleAnnotations `json:"bundles"`
+}
+
+// BundleAnnotations holds the tags and notes of one bundle.
+type BundleAnnotations struct {
+	BundleID string   `json:"bundle_id"`
+	Tags     []string `json:"tags,omitempty"`
+	Notes    []QANote `json:"notes,omitempty"`
+}
+
+// AnnotationsImport summarizes an annotations import.
+type AnnotationsImport struct {
+	Tags    int      `json:"tags"`              // Tags added
+	Notes   int      `json:"notes"`             // Notes added
+	Missing []string `json:"missing,omitempty"` // Bundles not in the database
+}
+
 // HealthStatus represents the health check response.
 type HealthStatus struct {
 	Status   string `json:"status"`
 	Version  string `json:"version"`
 	Database string `json:"database"`
 	Storage  string `json:"storage"`
+
+	// Where committed bundles are stored: local or s3
+	StorageBackend string `json:"storage_backend,omitempty"`
+
+	// Space on the volume holding the data directory
+	Disk *DiskUsage `json:"disk,omitempty"`
+}
+
+// DiskUsage describes the volume holding the data directory.
+type DiskUsage struct {
+	TotalBytes   int64 `json:"total_bytes"`
+	FreeBytes    int64 `json:"free_bytes"`
+	UsedBytes    int64 `json:"used_bytes"`
+	ReserveBytes int64 `json:"reserve_bytes"` // Kept free for the database and logs
+
+	// Free space uploads may still use: free minus the reserve
+	HeadroomBytes int64 `json:"headroom_bytes"`
 }
 
 // APIError represents an error response.
@@ -336,4 +521,53 @@ const (
 	ErrCodeArtifactNotFound  = "ARTIFACT_NOT_FOUND"
 	ErrCodeStorageError      = "STORAGE_ERROR"
 	ErrCodeDatabaseError     = "DATABASE_ERROR"
+	ErrCodeChecksumMismatch  = "CHECKSUM_MISMATCH"
+
+	// Archive format errors (INVALID_ZIP above covers ZIP)
+	ErrCodeInvalidTar         = "INVALID_TAR"
+	ErrCodeInvalidTarGz       = "INVALID_TAR_GZ"
+	ErrCodeInvalidTarZst      = "INVALID_TAR_ZST"
+	ErrCodeUnsupportedArchive = "UNSUPPORTED_ARCHIVE"
+
+	// Hostile archive errors
+	ErrCodeArchiveLimitExceeded = "ARCHIVE_LIMIT_EXCEEDED"
+	ErrCodeUnsafeArchiveEntry   = "UNSAFE_ARCHIVE_ENTRY"
+
+	// Resumable upload errors
+	ErrCodeUploadNotFound       = "UPLOAD_NOT_FOUND"
+	ErrCodeUploadOffsetMismatch = "UPLOAD_OFFSET_MISMATCH"
+	ErrCodeUploadIncomplete     = "UPLOAD_INCOMPLETE"
+	ErrCodeUploadTooLarge       = "UPLOAD_TOO_LARGE"
+
+	// Validation errors
+	ErrCodeValidationFailed   = "VALIDATION_FAILED"
+	ErrCodeValidationNotFound = "VALIDATION_NOT_FOUND"
+
+	// Ingest job errors
+	ErrCodeJobNotFound = "JOB_NOT_FOUND"
+	ErrCodeQueueFull   = "QUEUE_FULL"
+
+	// Client-supplied bundle ID already used by different content
+	ErrCodeBundleIDConflict = "BUNDLE_ID_CONFLICT"
+)
+
+// Quarantine errors
+const ErrCodeQuarantineNotFound = "QUARANTINE_NOT_FOUND"
+
+// Issue errors
+const ErrCodeIssueNotFound = "ISSUE_NOT_FOUND"
+
+// Artifact analysis errors
+const (
+	ErrCodeAnalysisUnsupported = "ANALYSIS_UNSUPPORTED"
+	ErrCodeInvalidMinidump     = "INVALID_MINIDUMP"
+)
+
+// Artifact download errors
+const ErrCodeInvalidRange = "INVALID_RANGE"
+
+// Disk space errors
+const (
+	ErrCodeInsufficientStorage = "INSUFFICIENT_STORAGE"
+	ErrCodeQuotaExceeded       = "QUOTA_EXCEEDED"
 )
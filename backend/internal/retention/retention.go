@@ -0,0 +1,249 @@
+// Package retention deletes old bundles according to declarative rules.
+//
+// Rules are evaluated against every bundle, newest first:
+//
+//   - KeepPerBuild: bundles beyond the N most recent of their build_id are
+//     deleted.
+//   - MaxAge: bundles ingested longer ago than MaxAge are deleted.
+//   - MaxTotalBytes: while the bundles left exceed the budget, the oldest
+//     are deleted.
+//
+// Bundles carrying a pinned tag are never deleted and do not count towards
+// KeepPerBuild; they do count towards MaxTotalBytes. The tags are checked
+// again when a bundle is deleted, so one pinned during a run is kept. A zero
+// rule is off.
+//
+// Each deletion removes the repro_bundles row and everything hanging off it
+// in one transaction that also writes a retention_audit record, and then
+// the bundle directory.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/db"
+	"github.com/unrealsolutions/bugit/internal/models"
+	"github.com/unrealsolutions/bugit/internal/storage"
+)
+
+// DefaultInterval is how often serve applies the rules.
+const DefaultInterval = time.Hour
+
+// DefaultPinnedTags protect a bundle from deletion.
+var DefaultPinnedTags = []string{"keep", "legal-hold"}
+
+// Reasons recorded for a deletion.
+const (
+	ReasonKeepPerBuild  = "keep_per_build"
+	ReasonMaxAge        = "max_age"
+	ReasonMaxTotalBytes = "max_total_bytes"
+)
+
+// Policy holds the retention rules. A zero value deletes nothing.
+type Policy struct {
+	MaxAge        time.Duration
+	MaxTotalBytes int64
+	KeepPerBuild  int
+	PinnedTags    []string
+}
+
+// Enabled reports whether any rule can delete a bundle.
+func (p Policy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxTotalBytes > 0 || p.KeepPerBuild > 0
+}
+
+// Failure is a bundle that could not be deleted.
+type Failure struct {
+	BundleID string `json:"bundle_id"`
+	Error    string `json:"error"`
+}
+
+// Report summarizes a collection run.
+type Report struct {
+	DryRun     bool                         `json:"dry_run"`
+	Deleted    []models.RetentionAuditEntry `json:"deleted"`
+	Failed     []Failure                    `json:"failed,omitempty"`
+	FreedBytes int64                        `json:"freed_bytes"`
+	Kept       int                          `json:"kept"`
+	Pinned     int                          `json:"pinned"`
+	TotalBytes int64                        `json:"total_bytes"` // Size of the bundles kept
+}
+
+// Collector applies a Policy to the bundles in a database and storage.
+type Collector struct {
+	db      *db.DB
+	storage *storage.Storage
+	policy  Policy
+	logger  *slog.Logger
+}
+
+// New creates a collector.
+func New(database *db.DB, store *storage.Storage, policy Policy) *Collector {
+	return &Collector{
+		db:      database,
+		storage: store,
+		policy:  policy,
+		logger:  slog.Default(),
+	}
+}
+
+// Collect deletes every bundle the policy selects. With dryRun, it only
+// reports what would be deleted.
+func (c *Collector) Collect(dryRun bool) (*Report, error) {
+	bundles, err := c.db.ListRetentionCandidates()
+	if err != nil {
+		return nil, fmt.Errorf("list bundles: %w", err)
+	}
+
+	report := Plan(bundles, c.policy, time.Now())
+	report.DryRun = dryRun
+	if dryRun {
+		return report, nil
+	}
+
+	deleted := report.Deleted[:0]
+	for _, entry := range report.Deleted {
+		ok, err := c.db.DeleteBundle(&entry, c.policy.PinnedTags)
+		if errors.Is(err, db.ErrBundlePinned) {
+			// Tagged since the plan was made
+			report.Pinned++
+			report.FreedBytes -= entry.SizeBytes
+			report.TotalBytes += entry.SizeBytes
+			continue
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, Failure{BundleID: entry.BundleID, Error: err.Error()})
+			report.FreedBytes -= entry.SizeBytes
+			report.TotalBytes += entry.SizeBytes
+			continue
+		}
+		if !ok {
+			// Deleted by someone else in the meantime
+			report.FreedBytes -= entry.SizeBytes
+			continue
+		}
+
+		// The row is gone; a directory that cannot be removed is only
+		// reported, and Recover lists it as unregistered from then on
+		if entry.StoragePath != "" {
+			if err := c.storage.RemoveBundleDir(entry.StoragePath); err != nil {
+				c.logger.Warn("failed to remove bundle dir", "bundle_id", entry.BundleID, "path", entry.StoragePath, "error", err)
+				report.Failed = append(report.Failed, Failure{BundleID: entry.BundleID, Error: "remove directory: " + err.Error()})
+			}
+		}
+
+		c.logger.Info("bundle deleted by retention",
+			"bundle_id", entry.BundleID,
+			"build_id", entry.BuildID,
+			"reason", entry.Reason,
+			"size_bytes", entry.SizeBytes,
+		)
+		deleted = append(deleted, entry)
+	}
+	report.Deleted = deleted
+
+	return report, nil
+}
+
+// Run collects every interval until ctx is done.
+func (c *Collector) Run(ctx context.Context, interval time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report, err := c.Collect(dryRun)
+			if err != nil {
+				c.logger.Error("retention run failed", "error", err)
+				continue
+			}
+			if len(report.Deleted) > 0 || len(report.Failed) > 0 {
+				c.logger.Info("retention run finished",
+					"dry_run", dryRun,
+					"deleted", len(report.Deleted),
+					"failed", len(report.Failed),
+					"freed_bytes", report.FreedBytes,
+				)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Plan decides which bundles the policy deletes. bundles must be sorted
+// newest first, as ListRetentionCandidates returns them. The returned
+// report lists the deletions without having made them.
+func Plan(bundles []models.ReproBundle, policy Policy, now time.Time) *Report {
+	report := &Report{Deleted: []models.RetentionAuditEntry{}}
+	reasons := make([]string, len(bundles))
+	perBuild := make(map[string]int)
+
+	for n, b := range bundles {
+		report.TotalBytes += b.SizeBytes
+		if isPinned(b.Tags, policy.PinnedTags) {
+			report.Pinned++
+			continue
+		}
+
+		perBuild[b.BuildID]++
+		switch {
+		case policy.KeepPerBuild > 0 && perBuild[b.BuildID] > policy.KeepPerBuild:
+			reasons[n] = ReasonKeepPerBuild
+		case policy.MaxAge > 0 && now.Sub(b.CreatedAt) > policy.MaxAge:
+			reasons[n] = ReasonMaxAge
+		}
+		if reasons[n] != "" {
+			report.TotalBytes -= b.SizeBytes
+		}
+	}
+
+	// Then trim the oldest survivors to the size budget
+	if policy.MaxTotalBytes > 0 {
+		for n := len(bundles) - 1; n >= 0 && report.TotalBytes > policy.MaxTotalBytes; n-- {
+			if reasons[n] != "" || isPinned(bundles[n].Tags, policy.PinnedTags) {
+				continue
+			}
+			reasons[n] = ReasonMaxTotalBytes
+			report.TotalBytes -= bundles[n].SizeBytes
+		}
+	}
+
+	// Oldest first, the order they are deleted in
+	for n := len(bundles) - 1; n >= 0; n-- {
+		b := bundles[n]
+		if reasons[n] == "" {
+			if !isPinned(b.Tags, policy.PinnedTags) {
+				report.Kept++
+			}
+			continue
+		}
+		report.Deleted = append(report.Deleted, models.RetentionAuditEntry{
+			BundleID:        b.BundleID,
+			BuildID:         b.BuildID,
+			Platform:        b.Platform,
+			SizeBytes:       b.SizeBytes,
+			StoragePath:     b.StoragePath,
+			Reason:          reasons[n],
+			BundleCreatedAt: b.CreatedAt,
+		})
+		report.FreedBytes += b.SizeBytes
+	}
+
+	return report
+}
+
+func isPinned(tags, pinned []string) bool {
+	for _, tag := range tags {
+		if slices.Contains(pinned, tag) {
+			return true
+		}
+	}
+	return false
+}
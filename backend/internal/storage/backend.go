@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Backend stores committed bundles. Names are slash-separated paths relative
+// to the backend root, such as "bundles/rb_a1b2c3d4/game.log".
+//
+// Staging always happens on local disk under tmp/ and queue/, because
+// archives are extracted and hashed there; a backend only receives a bundle
+// once it is complete. The database, queue and quarantine stay local too.
+type Backend interface {
+	// Name identifies the backend in logs and health output.
+	Name() string
+
+	// Commit places the files of a staged local directory under dir. It
+	// fails if dir already holds files. srcDir may be moved or left in
+	// place; the caller removes whatever remains.
+	Commit(srcDir, dir string) error
+
+	// Open opens a file for reading, starting at offset. A negative length
+	// reads to the end of the file.
+	Open(name string, offset, length int64) (io.ReadCloser, error)
+
+	// Stat returns the size and modification time of a file. A missing
+	// file yields an error matching fs.ErrNotExist.
+	Stat(name string) (*FileInfo, error)
+
+	// List returns every file below dir, recursively.
+	List(dir string) ([]FileInfo, error)
+
+	// ListDirs returns the immediate subdirectories of dir.
+	ListDirs(dir string) ([]string, error)
+
+	// Delete removes a file, or a directory and everything below it.
+	// Deleting something that does not exist is not an error.
+	Delete(name string) error
+
+	// Check verifies the backend is reachable.
+	Check() error
+}
+
+// FileInfo describes a file held by a Backend.
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// LocalBackend stores bundles in a directory on local disk, normally the
+// data directory itself. Commit is an atomic rename, so staging must live
+// on the same filesystem.
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates a backend rooted at dir.
+func NewLocalBackend(dir string) *LocalBackend {
+	return &LocalBackend{root: dir}
+}
+
+// Name returns "local".
+func (b *LocalBackend) Name() string {
+	return "local"
+}
+
+// path maps a name to a filesystem path that cannot escape the root.
+func (b *LocalBackend) path(name string) string {
+	return filepath.Join(b.root, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// Commit renames srcDir to dir.
+func (b *LocalBackend) Commit(srcDir, dir string) error {
+	destDir := b.path(dir)
+
+	// Ensure the parent exists (bundles/ may have been deleted)
+	if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
+		return fmt.Errorf("ensure parent dir: %w", err)
+	}
+
+	if _, err := os.Stat(destDir); err == nil {
+		return fmt.Errorf("destination already exists: %s", dir)
+	}
+
+	// Atomic rename (works on same filesystem)
+	if err := os.Rename(srcDir, destDir); err != nil {
+		return fmt.Errorf("rename to %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Open opens a file and seeks to offset.
+func (b *LocalBackend) Open(name string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(b.path(name))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+// Stat stats a file.
+func (b *LocalBackend) Stat(name string) (*FileInfo, error) {
+	info, err := os.Stat(b.path(name))
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List walks dir for regular files.
+func (b *LocalBackend) List(dir string) ([]FileInfo, error) {
+	root := b.path(dir)
+	var files []FileInfo
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, FileInfo{
+			Name:    path.Join(dir, filepath.ToSlash(rel)),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ListDirs reads the subdirectories of dir.
+func (b *LocalBackend) ListDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(b.path(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, path.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// Delete removes name and anything below it.
+func (b *LocalBackend) Delete(name string) error {
+	return os.RemoveAll(b.path(name))
+}
+
+// Check verifies the root directory exists.
+func (b *LocalBackend) Check() error {
+	info, err := os.Stat(b.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", b.root)
+	}
+	return nil
+}
+
+// backendReaderAt reads a backend file at arbitrary offsets, one ranged
+// read per call. It suits parsers such as the minidump reader that only
+// touch a small part of a large file.
+type backendReaderAt struct {
+	backend Backend
+	name    string
+}
+
+func (r *backendReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	rc, err := r.backend.Open(r.name, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	n, err := io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// DefaultDiskReserve is the free space on the data volume that uploads are
+// never allowed to eat into, leaving room for the database and logs.
+const DefaultDiskReserve = 1 << 30
+
+// SetDiskReserve sets the free space uploads must leave on the data volume.
+func (s *Storage) SetDiskReserve(bytes int64) {
+	s.diskReserve = bytes
+}
+
+// DiskUsage reports the size and free space of the volume holding the data
+// directory. Staging, extraction and the database always live there, even
+// when committed bundles go to another backend.
+func (s *Storage) DiskUsage() (*models.DiskUsage, error) {
+	total, free, used, err := diskSpace(s.dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("disk usage of %s: %w", s.dataDir, err)
+	}
+
+	usage := &models.DiskUsage{
+		TotalBytes:   int64(total),
+		FreeBytes:    int64(free),
+		UsedBytes:    int64(used),
+		ReserveBytes: s.diskReserve,
+	}
+	if usage.FreeBytes > usage.ReserveBytes {
+		usage.HeadroomBytes = usage.FreeBytes - usage.ReserveBytes
+	}
+	return usage, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package storage
+
+import "errors"
+
+// diskSpace is not implemented on this platform; uploads are then not
+// checked against free space.
+func diskSpace(dir string) (total, free, used uint64, err error) {
+	return 0, 0, 0, errors.ErrUnsupported
+}
+
+// IsNoSpace always reports false on this platform.
+func IsNoSpace(err error) bool {
+	return false
+}
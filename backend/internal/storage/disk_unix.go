@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd
+
+package storage
+
+import (
+	"errors"
+	"syscall"
+)
+
+// diskSpace returns the total size of the volume holding dir, the bytes
+// available to this process and the bytes in use.
+func diskSpace(dir string) (total, free, used uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	total = uint64(st.Blocks) * bsize
+	free = uint64(st.Bavail) * bsize
+	used = (uint64(st.Blocks) - uint64(st.Bfree)) * bsize
+	return total, free, used, nil
+}
+
+// IsNoSpace reports whether err is a write that failed because the volume
+// or the user's disk quota is full.
+func IsNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// Win32 error codes for a full disk
+const (
+	errorHandleDiskFull syscall.Errno = 39
+	errorDiskFull       syscall.Errno = 112
+)
+
+// diskSpace returns the total size of the volume holding dir, the bytes
+// available to this process and the bytes in use.
+func diskSpace(dir string) (total, free, used uint64, err error) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var avail, totalFree uint64
+	r, _, callErr := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&avail)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if r == 0 {
+		return 0, 0, 0, callErr
+	}
+	return total, avail, total - totalFree, nil
+}
+
+// IsNoSpace reports whether err is a write that failed because the volume
+// or the user's disk quota is full.
+func IsNoSpace(err error) bool {
+	return errors.Is(err, errorDiskFull) || errors.Is(err, errorHandleDiskFull)
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// QuarantineFile holds the metadata of a quarantined upload inside its
+// directory in quarantine/.
+const QuarantineFile = "quarantine.json"
+
+// Default quarantine budget.
+const (
+	DefaultQuarantineMaxBytes int64 = 1 << 30 // 1 GB
+	DefaultQuarantineMaxAge         = 7 * 24 * time.Hour
+)
+
+// QuarantineLimits bounds how much rejected upload data is kept.
+// A zero value disables the corresponding limit.
+type QuarantineLimits struct {
+	MaxBytes int64         // Total size of quarantine/
+	MaxAge   time.Duration // Entries older than this are removed
+}
+
+// SetQuarantineLimits sets the budget enforced by PruneQuarantine.
+func (s *Storage) SetQuarantineLimits(limits QuarantineLimits) {
+	s.quarantine = limits
+}
+
+// IsValidQuarantineID reports whether id has the form "q_" plus 16 lowercase
+// hex characters. This keeps client-supplied IDs from escaping quarantine/.
+func IsValidQuarantineID(id string) bool {
+	if len(id) != 18 || !strings.HasPrefix(id, "q_") {
+		return false
+	}
+	for _, c := range id[2:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// QuarantinePath returns the directory of a quarantined upload.
+func (s *Storage) QuarantinePath(id string) string {
+	return filepath.Join(s.quarantineDir, id)
+}
+
+// Quarantine moves a failed staging directory into quarantine/ together with
+// its metadata, replacing any earlier entry with the same ID, then prunes
+// quarantine/ to its budget. An upload larger than the whole size budget is
+// not kept; false is returned in that case and srcDir is left to the caller.
+func (s *Storage) Quarantine(srcDir string, entry *models.QuarantineEntry) (bool, error) {
+	size, err := DirSize(srcDir)
+	if err != nil {
+		return false, fmt.Errorf("size quarantined upload: %w", err)
+	}
+	if s.quarantine.MaxBytes > 0 && size > s.quarantine.MaxBytes {
+		return false, nil
+	}
+	entry.SizeBytes = size
+
+	if err := writeQuarantineEntry(srcDir, entry); err != nil {
+		return false, err
+	}
+
+	destDir := s.QuarantinePath(entry.QuarantineID)
+	if err := os.RemoveAll(destDir); err != nil {
+		return false, fmt.Errorf("replace quarantine entry: %w", err)
+	}
+	if err := os.MkdirAll(s.quarantineDir, 0755); err != nil {
+		return false, fmt.Errorf("ensure quarantine dir: %w", err)
+	}
+	if err := os.Rename(srcDir, destDir); err != nil {
+		return false, fmt.Errorf("move to quarantine: %w", err)
+	}
+
+	if _, err := s.PruneQuarantine(); err != nil {
+		return true, fmt.Errorf("prune quarantine: %w", err)
+	}
+
+	// Pruning only ever removes older entries, but keep the answer honest
+	if _, err := os.Stat(destDir); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// LoadQuarantine returns a quarantined upload's metadata with the files it
+// holds, or nil if there is no such entry.
+func (s *Storage) LoadQuarantine(id string) (*models.QuarantineEntry, error) {
+	if !IsValidQuarantineID(id) {
+		return nil, nil
+	}
+
+	dir := s.QuarantinePath(id)
+	entry, err := readQuarantineEntry(dir)
+	if entry == nil || err != nil {
+		return entry, err
+	}
+
+	err = walkQuarantineFiles(dir, func(path, rel string, info fs.FileInfo) error {
+		entry.Files = append(entry.Files, models.QuarantineFile{Path: rel, SizeBytes: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list quarantined files: %w", err)
+	}
+	return entry, nil
+}
+
+// ListQuarantine returns every quarantined upload, newest first.
+func (s *Storage) ListQuarantine() ([]*models.QuarantineEntry, error) {
+	dirs, err := os.ReadDir(s.quarantineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*models.QuarantineEntry, 0, len(dirs))
+	for _, d := range dirs {
+		if !d.IsDir() || !IsValidQuarantineID(d.Name()) {
+			continue
+		}
+		entry, err := readQuarantineEntry(s.QuarantinePath(d.Name()))
+		if err != nil || entry == nil {
+			// Half-written entries are removed by PruneQuarantine
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].UpdatedAt.After(entries[b].UpdatedAt)
+	})
+	return entries, nil
+}
+
+// TakeFromQuarantine moves a quarantined upload into a new staging directory
+// under tmp/ and returns its metadata and the directory. The metadata file is
+// removed so the directory is a plain staging directory again.
+func (s *Storage) TakeFromQuarantine(id, uploadID string) (*models.QuarantineEntry, string, error) {
+	entry, err := s.LoadQuarantine(id)
+	if err != nil || entry == nil {
+		return entry, "", err
+	}
+	entry.Files = nil
+
+	dir := s.TempDirPath(uploadID)
+	if err := os.Rename(s.QuarantinePath(id), dir); err != nil {
+		return nil, "", fmt.Errorf("move out of quarantine: %w", err)
+	}
+	if err := os.Remove(filepath.Join(dir, QuarantineFile)); err != nil {
+		return nil, "", fmt.Errorf("remove quarantine metadata: %w", err)
+	}
+	return entry, dir, nil
+}
+
+// RemoveQuarantine deletes a quarantined upload.
+func (s *Storage) RemoveQuarantine(id string) error {
+	if !IsValidQuarantineID(id) {
+		return fmt.Errorf("invalid quarantine id: %s", id)
+	}
+	return os.RemoveAll(s.QuarantinePath(id))
+}
+
+// PruneQuarantine removes entries past the age limit, then the oldest entries
+// until quarantine/ fits the size limit. Directories without readable
+// metadata are removed as well. Returns the number of entries removed.
+func (s *Storage) PruneQuarantine() (int, error) {
+	dirs, err := os.ReadDir(s.quarantineDir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var kept []*models.QuarantineEntry
+	var total int64
+	cutoff := time.Now().Add(-s.quarantine.MaxAge)
+
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.quarantineDir, d.Name())
+		entry, err := readQuarantineEntry(path)
+		expired := s.quarantine.MaxAge > 0 && entry != nil && entry.UpdatedAt.Before(cutoff)
+		if err != nil || entry == nil || expired {
+			if err := os.RemoveAll(path); err != nil {
+				return removed, err
+			}
+			removed++
+			continue
+		}
+		kept = append(kept, entry)
+		total += entry.SizeBytes
+	}
+
+	if s.quarantine.MaxBytes <= 0 {
+		return removed, nil
+	}
+
+	// Oldest first
+	sort.Slice(kept, func(a, b int) bool {
+		return kept[a].UpdatedAt.Before(kept[b].UpdatedAt)
+	})
+	for _, entry := range kept {
+		if total <= s.quarantine.MaxBytes {
+			break
+		}
+		if err := os.RemoveAll(s.QuarantinePath(entry.QuarantineID)); err != nil {
+			return removed, err
+		}
+		total -= entry.SizeBytes
+		removed++
+	}
+
+	return removed, nil
+}
+
+// WriteQuarantineArchive writes a quarantined upload to w as it was received.
+// Uploads of individual files are packed into a ZIP.
+func (s *Storage) WriteQuarantineArchive(entry *models.QuarantineEntry, w io.Writer) error {
+	dir := s.QuarantinePath(entry.QuarantineID)
+
+	// "zip" is ingest.StagedZip: the directory holds the archive as uploaded
+	if entry.Kind == "zip" {
+		f, err := os.Open(filepath.Join(dir, "upload.zip"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	err := walkQuarantineFiles(dir, func(path, rel string, info fs.FileInfo) error {
+		fw, err := zw.Create(rel)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(fw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// walkQuarantineFiles calls fn for every regular file of a quarantined
+// upload except its metadata, with slash-separated relative paths.
+func walkQuarantineFiles(dir string, fn func(path, rel string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == QuarantineFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, rel, info)
+	})
+}
+
+// writeQuarantineEntry writes metadata into dir atomically.
+func writeQuarantineEntry(dir string, entry *models.QuarantineEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal quarantine entry: %w", err)
+	}
+
+	tmpFile := filepath.Join(dir, QuarantineFile+".tmp")
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("write quarantine entry: %w", err)
+	}
+	if err := os.Rename(tmpFile, filepath.Join(dir, QuarantineFile)); err != nil {
+		return fmt.Errorf("rename quarantine entry: %w", err)
+	}
+	return nil
+}
+
+// readQuarantineEntry reads the metadata in dir, or returns nil if there
+// is none.
+func readQuarantineEntry(dir string) (*models.QuarantineEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, QuarantineFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read quarantine entry: %w", err)
+	}
+
+	var entry models.QuarantineEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("parse quarantine entry: %w", err)
+	}
+	return &entry, nil
+}
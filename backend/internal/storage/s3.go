@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultS3PartSize is the part size for multipart uploads. Files up to
+// this size are uploaded with a single PUT.
+const DefaultS3PartSize int64 = 64 << 20
+
+// Payload hashes used when signing requests.
+const (
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config configures an S3-compatible backend such as MinIO or AWS S3.
+type S3Config struct {
+	Endpoint  string // Base URL, e.g. http://minio:9000 or https://s3.eu-west-1.amazonaws.com
+	Region    string // Signing region; MinIO accepts the default us-east-1
+	Bucket    string
+	Prefix    string // Optional key prefix inside the bucket
+	AccessKey string
+	SecretKey string
+	PathStyle bool  // Address the bucket in the URL path, as MinIO expects
+	PartSize  int64 // Multipart part size (0 = DefaultS3PartSize)
+}
+
+// S3Backend stores bundles as objects in an S3-compatible bucket. Requests
+// are signed with AWS Signature Version 4. S3 has no directories: a bundle
+// directory is the set of objects sharing its key prefix.
+type S3Backend struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Backend creates an S3 backend. The bucket must already exist.
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 backend needs an endpoint and a bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 backend needs an access key and a secret key")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid s3 endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	return &S3Backend{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{},
+	}, nil
+}
+
+// Name returns "s3".
+func (b *S3Backend) Name() string {
+	return "s3"
+}
+
+// key maps a name to an object key.
+func (b *S3Backend) key(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if b.cfg.Prefix == "" {
+		return name
+	}
+	if name == "" {
+		return b.cfg.Prefix
+	}
+	return b.cfg.Prefix + "/" + name
+}
+
+// name maps an object key back to a name.
+func (b *S3Backend) name(key string) string {
+	if b.cfg.Prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, b.cfg.Prefix+"/")
+}
+
+// Commit uploads the files of srcDir under dir. The pending marker goes
+// first, so an upload cut short leaves objects Recover knows to remove.
+func (b *S3Backend) Commit(srcDir, dir string) error {
+	existing, _, err := b.list(b.key(dir)+"/", "", 1)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("destination already exists: %s", dir)
+	}
+
+	var files []string
+	err = filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan staged dir: %w", err)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i] == PendingMarker && files[j] != PendingMarker
+	})
+
+	for _, rel := range files {
+		if err := b.upload(path.Join(dir, rel), filepath.Join(srcDir, filepath.FromSlash(rel))); err != nil {
+			b.Delete(dir)
+			return fmt.Errorf("upload %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// upload stores a local file, in parts if it is larger than the part size.
+func (b *S3Backend) upload(name, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= b.cfg.PartSize {
+		resp, err := b.do(http.MethodPut, b.key(name), nil, nil, f, info.Size(), unsignedPayload)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return b.uploadMultipart(b.key(name), f, info.Size())
+}
+
+// uploadMultipart uploads a large file in PartSize pieces, aborting the
+// multipart upload if any part fails.
+func (b *S3Backend) uploadMultipart(key string, f *os.File, size int64) error {
+	resp, err := b.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = decodeXML(resp, &initiated)
+	if err != nil {
+		return fmt.Errorf("initiate multipart upload: %w", err)
+	}
+	uploadID := initiated.UploadID
+
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []part
+	for offset, n := int64(0), 1; offset < size; offset, n = offset+b.cfg.PartSize, n+1 {
+		length := min(b.cfg.PartSize, size-offset)
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := b.do(http.MethodPut, key, query, nil, io.NewSectionReader(f, offset, length), length, unsignedPayload)
+		if err != nil {
+			b.abortMultipart(key, uploadID)
+			return fmt.Errorf("upload part %d: %w", n, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, part{PartNumber: n, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		b.abortMultipart(key, uploadID)
+		return err
+	}
+	sum := sha256.Sum256(body)
+	resp, err = b.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil,
+		bytes.NewReader(body), int64(len(body)), hex.EncodeToString(sum[:]))
+	if err != nil {
+		b.abortMultipart(key, uploadID)
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+
+	// S3 can report a failed completion in a 200 response
+	var completed struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := decodeXML(resp, &completed); err != nil {
+		b.abortMultipart(key, uploadID)
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+	if completed.XMLName.Local == "Error" {
+		b.abortMultipart(key, uploadID)
+		return &S3Error{StatusCode: http.StatusOK, Code: completed.Code, Message: completed.Message}
+	}
+	return nil
+}
+
+func (b *S3Backend) abortMultipart(key, uploadID string) {
+	resp, err := b.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, 0, emptyPayloadHash)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Open fetches an object, using a Range request when only part is wanted.
+func (b *S3Backend) Open(name string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	switch {
+	case length == 0:
+		return io.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := b.do(http.MethodGet, b.key(name), nil, header, nil, 0, emptyPayloadHash)
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// Reading past the end, as a file would
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		return nil, pathError("open", name, err)
+	}
+	return resp.Body, nil
+}
+
+// Stat issues a HEAD request for an object.
+func (b *S3Backend) Stat(name string) (*FileInfo, error) {
+	resp, err := b.do(http.MethodHead, b.key(name), nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// List lists every object below dir.
+func (b *S3Backend) List(dir string) ([]FileInfo, error) {
+	files, _, err := b.list(b.key(dir)+"/", "", 0)
+	return files, err
+}
+
+// ListDirs lists the common prefixes one level below dir.
+func (b *S3Backend) ListDirs(dir string) ([]string, error) {
+	_, dirs, err := b.list(b.key(dir)+"/", "/", 0)
+	return dirs, err
+}
+
+// list runs ListObjectsV2 until exhausted or until limit objects were
+// returned (0 = no limit).
+func (b *S3Backend) list(prefix, delimiter string, limit int) ([]FileInfo, []string, error) {
+	var files []FileInfo
+	var dirs []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if limit > 0 {
+			query.Set("max-keys", strconv.Itoa(limit))
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := b.do(http.MethodGet, "", query, nil, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		var result struct {
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+			Contents              []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+		}
+		if err := decodeXML(resp, &result); err != nil {
+			return nil, nil, fmt.Errorf("list objects: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			files = append(files, FileInfo{Name: b.name(c.Key), Size: c.Size, ModTime: c.LastModified})
+		}
+		for _, p := range result.CommonPrefixes {
+			dirs = append(dirs, b.name(strings.TrimSuffix(p.Prefix, "/")))
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" || (limit > 0 && len(files) >= limit) {
+			return files, dirs, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes the object called name and every object below it.
+func (b *S3Backend) Delete(name string) error {
+	files, err := b.List(name)
+	if err != nil {
+		return err
+	}
+	keys := []string{b.key(name)}
+	for _, f := range files {
+		keys = append(keys, b.key(f.Name))
+	}
+
+	for _, key := range keys {
+		resp, err := b.do(http.MethodDelete, key, nil, nil, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// Check issues a HEAD request for the bucket.
+func (b *S3Backend) Check() error {
+	resp, err := b.do(http.MethodHead, "", nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("bucket %s: %w", b.cfg.Bucket, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// S3Error is an error response from the object store.
+type S3Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// pathError wraps a not-found response so callers can test for
+// fs.ErrNotExist as with local files.
+func pathError(op, name string, err error) error {
+	var s3Err *S3Error
+	if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// do sends a signed request for key (the bucket itself if key is empty).
+// Responses outside 2xx are returned as *S3Error with the body closed.
+func (b *S3Backend) do(method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := *b.endpoint
+	objectPath := "/" + key
+	if b.cfg.PathStyle {
+		objectPath = "/" + b.cfg.Bucket + objectPath
+		if key == "" {
+			objectPath = "/" + b.cfg.Bucket
+		}
+	} else {
+		u.Host = b.cfg.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if body == nil || size == 0 {
+		req.Body = http.NoBody
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	b.sign(req, payloadHash, time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		s3Err := &S3Error{StatusCode: resp.StatusCode}
+		if method != http.MethodHead {
+			xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&struct {
+				Code    *string `xml:"Code"`
+				Message *string `xml:"Message"`
+			}{&s3Err.Code, &s3Err.Message})
+		}
+		return nil, s3Err
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+func (b *S3Backend) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + b.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.cfg.SecretKey), day)
+	signingKey = hmacSHA256(signingKey, b.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as both the
+// request and its signature need them.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set, as SigV4 requires.
+func s3Escape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// decodeXML decodes and closes a response body.
+func decodeXML(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,670 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket    = "bugit"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-west-1"
+)
+
+// fakeS3 is an in-process S3 server with path-style addressing. It checks
+// the SigV4 signature of every request against its own canonicalization.
+type fakeS3 struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte // Multipart uploads in progress by ID
+	aborted  []string                  // Aborted upload IDs
+	requests []*http.Request
+
+	pageSize      int  // Objects per list page (0 = all)
+	failPart      int  // Part number answered with 500 (0 = none)
+	completeError bool // Answer completion with an <Error> in a 200
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Backend) {
+	t.Helper()
+	fake := &fakeS3{
+		t:       t,
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	b, err := NewS3Backend(S3Config{
+		Endpoint:  server.URL,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		Prefix:    "data",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, b
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+
+	body, _ := io.ReadAll(r.Body)
+	if err := verifySignature(r, body); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL, err)
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+
+	bucketPath := "/" + testBucket
+	if r.URL.Path == bucketPath {
+		switch r.Method {
+		case http.MethodHead:
+		case http.MethodGet:
+			f.list(w, r.URL.Query())
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, bucketPath+"/")
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "no such bucket")
+		return
+	}
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := fmt.Sprintf("upload-%d", len(f.uploads)+len(f.aborted)+1)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "no such upload")
+			return
+		}
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		if n == f.failPart {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", "part failed")
+			return
+		}
+		parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf("%q", "etag-"+strconv.Itoa(n)))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		id := query.Get("uploadId")
+		parts, ok := f.uploads[id]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "no such upload")
+			return
+		}
+		if f.completeError {
+			// S3 may fail a completion after sending the 200 status
+			fmt.Fprint(w, "<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>")
+			return
+		}
+		var complete struct {
+			Parts []struct {
+				PartNumber int    `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+			return
+		}
+		var object []byte
+		for i, p := range complete.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf("%q", "etag-"+strconv.Itoa(p.PartNumber)) {
+				writeS3Error(w, http.StatusBadRequest, "InvalidPart", "unexpected part "+p.ETag)
+				return
+			}
+			object = append(object, parts[p.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, id)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult><Key>"+key+"</Key></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		f.aborted = append(f.aborted, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		w.Header().Set("Last-Modified", time.Date(2026, 1, 21, 2, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+		start, end, status := int64(0), int64(len(object)), http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			from, to, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+			start, _ = strconv.ParseInt(from, 10, 64)
+			if to != "" {
+				last, _ := strconv.ParseInt(to, 10, 64)
+				end = min(last+1, end)
+			}
+			if start >= int64(len(object)) {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+				return
+			}
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(object[start:end])
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list answers ListObjectsV2, pageSize objects or prefixes at a time. The
+// continuation token is the last key returned.
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	if query.Get("list-type") != "2" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "want list-type=2")
+		return
+	}
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+
+	// Keys and common prefixes in key order, each once
+	var entries []string
+	seen := make(map[string]bool)
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		entry := key
+		if delimiter != "" {
+			if i := strings.Index(rest, delimiter); i >= 0 {
+				entry = prefix + rest[:i+len(delimiter)]
+			}
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	if token := query.Get("continuation-token"); token != "" {
+		i := sort.SearchStrings(entries, token)
+		if i < len(entries) && entries[i] == token {
+			i++
+		}
+		entries = entries[i:]
+	}
+
+	limit := f.pageSize
+	if maxKeys, _ := strconv.Atoi(query.Get("max-keys")); maxKeys > 0 && (limit == 0 || maxKeys < limit) {
+		limit = maxKeys
+	}
+	truncated := limit > 0 && len(entries) > limit
+	if truncated {
+		entries = entries[:limit]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<ListBucketResult>")
+	fmt.Fprintf(&sb, "<IsTruncated>%t</IsTruncated>", truncated)
+	if truncated {
+		fmt.Fprintf(&sb, "<NextContinuationToken>%s</NextContinuationToken>", xmlEscape(entries[len(entries)-1]))
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, delimiter) && delimiter != "" {
+			fmt.Fprintf(&sb, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", xmlEscape(entry))
+			continue
+		}
+		fmt.Fprintf(&sb, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-01-21T02:00:00.000Z</LastModified></Contents>",
+			xmlEscape(entry), len(f.objects[entry]))
+	}
+	sb.WriteString("</ListBucketResult>")
+	io.WriteString(w, sb.String())
+}
+
+func (f *fakeS3) put(key string, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = []byte(data)
+}
+
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, xmlEscape(message))
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// verifySignature recomputes the SigV4 signature of r from the decoded path
+// and query, independently of the client's escaping.
+func verifySignature(r *http.Request, body []byte) error {
+	auth := r.Header.Get("Authorization")
+	credential, rest, ok := strings.Cut(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 Credential="), ", SignedHeaders=")
+	if !ok {
+		return fmt.Errorf("malformed Authorization header %q", auth)
+	}
+	signedHeaders, signature, ok := strings.Cut(rest, ", Signature=")
+	if !ok {
+		return fmt.Errorf("malformed Authorization header %q", auth)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if len(amzDate) != len("20060102T150405Z") {
+		return fmt.Errorf("bad x-amz-date %q", amzDate)
+	}
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	if credential != testAccessKey+"/"+scope {
+		return fmt.Errorf("credential %q, want %q", credential, testAccessKey+"/"+scope)
+	}
+	if payloadHash != unsignedPayload {
+		sum := sha256.Sum256(body)
+		if payloadHash != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("payload hash %s does not match the body", payloadHash)
+		}
+	}
+
+	segments := strings.Split(r.URL.Path, "/")
+	for i, s := range segments {
+		segments[i] = awsEscape(s)
+	}
+
+	query := r.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			params = append(params, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		strings.Join(segments, "/"),
+		strings.Join(params, "&"),
+		"host:" + r.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{amzDate[:8], testRegion, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if want := hex.EncodeToString(key); signature != want {
+		return fmt.Errorf("signature %s, want %s for canonical request:\n%s", signature, want, canonicalRequest)
+	}
+	return nil
+}
+
+// awsEscape is URI encoding as the SigV4 documentation specifies it.
+func awsEscape(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
+
+// stageFiles writes files into a new directory for Commit.
+func stageFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readObject(t *testing.T, b *S3Backend, name string, offset, length int64) string {
+	t.Helper()
+	rc, err := b.Open(name, offset, length)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestS3SigningEscapesPathAndQuery(t *testing.T) {
+	fake, b := newFakeS3(t)
+
+	files := map[string]string{
+		"game log (1).txt":      "spaces and parens",
+		"a+b=c&d~e.log":         "sub-delimiters",
+		"screenshots/ünï.png":   "non-ASCII",
+		"screenshots/100%*.png": "percent and star",
+	}
+	if err := b.Commit(stageFiles(t, files), "bundles/rb_0000 01"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	for name, want := range files {
+		if got := readObject(t, b, "bundles/rb_0000 01/"+name, 0, -1); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+		if _, ok := fake.object("data/bundles/rb_0000 01/" + name); !ok {
+			t.Errorf("object for %s not stored under the prefix", name)
+		}
+	}
+
+	// The prefix and delimiter go through the query string
+	listed, err := b.List("bundles/rb_0000 01")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(listed) != len(files) {
+		t.Errorf("listed %d objects, want %d", len(listed), len(files))
+	}
+	if err := b.Check(); err != nil {
+		t.Errorf("check: %v", err)
+	}
+}
+
+func TestS3SignKnownRequest(t *testing.T) {
+	b := &S3Backend{cfg: S3Config{Region: testRegion, AccessKey: testAccessKey, SecretKey: testSecretKey}}
+	req, err := http.NewRequest(http.MethodGet, "http://minio:9000/bugit/data/a%20b.log?list-type=2&prefix=data%2Fx%20y%2F", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.sign(req, emptyPayloadHash, time.Date(2026, 1, 21, 2, 0, 0, 0, time.UTC))
+
+	if got := req.Header.Get("x-amz-date"); got != "20260121T020000Z" {
+		t.Errorf("x-amz-date = %q", got)
+	}
+	if err := verifySignature(req, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestS3CanonicalQuery(t *testing.T) {
+	query := url.Values{
+		"prefix":             {"data/a b+c/"},
+		"list-type":          {"2"},
+		"continuation-token": {"1/x=="},
+		"uploads":            {""},
+	}
+	want := "continuation-token=1%2Fx%3D%3D&list-type=2&prefix=data%2Fa%20b%2Bc%2F&uploads="
+	if got := canonicalQuery(query); got != want {
+		t.Errorf("canonicalQuery = %q, want %q", got, want)
+	}
+	if got, want := s3Escape("/a b/ü~*", false), "/a%20b/%C3%BC~%2A"; got != want {
+		t.Errorf("s3Escape = %q, want %q", got, want)
+	}
+}
+
+func TestS3SinglePut(t *testing.T) {
+	fake, b := newFakeS3(t)
+	b.cfg.PartSize = 16
+
+	if err := b.Commit(stageFiles(t, map[string]string{"small.log": "exactly 16 bytes"}), "bundles/rb_1"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	for _, r := range fake.requests {
+		if r.URL.Query().Has("uploads") {
+			t.Errorf("a file of PartSize bytes started a multipart upload")
+		}
+	}
+	if data, _ := fake.object("data/bundles/rb_1/small.log"); string(data) != "exactly 16 bytes" {
+		t.Errorf("stored %q", data)
+	}
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	fake, b := newFakeS3(t)
+	b.cfg.PartSize = 4
+
+	content := "0123456789abcdefXY" // Five parts, the last short
+	if err := b.Commit(stageFiles(t, map[string]string{"video.mp4": content}), "bundles/rb_2"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	data, ok := fake.object("data/bundles/rb_2/video.mp4")
+	if !ok || string(data) != content {
+		t.Fatalf("stored %q, want %q", data, content)
+	}
+	parts := 0
+	for _, r := range fake.requests {
+		if r.Method == http.MethodPut && r.URL.Query().Has("partNumber") {
+			parts++
+		}
+	}
+	if parts != 5 {
+		t.Errorf("uploaded %d parts, want 5", parts)
+	}
+	if len(fake.uploads) != 0 || len(fake.aborted) != 0 {
+		t.Errorf("uploads left %v, aborted %v", fake.uploads, fake.aborted)
+	}
+}
+
+func TestS3MultipartAbortsOnFailedPart(t *testing.T) {
+	fake, b := newFakeS3(t)
+	b.cfg.PartSize = 4
+	fake.failPart = 2
+
+	err := b.Commit(stageFiles(t, map[string]string{"video.mp4": "0123456789"}), "bundles/rb_3")
+	if err == nil {
+		t.Fatal("commit succeeded despite a failed part")
+	}
+	var s3Err *S3Error
+	if !errors.As(err, &s3Err) || s3Err.StatusCode != http.StatusInternalServerError || s3Err.Code != "InternalError" {
+		t.Errorf("error = %v, want the part's S3Error", err)
+	}
+	if len(fake.aborted) != 1 || len(fake.uploads) != 0 {
+		t.Errorf("aborted %v, uploads left %v; want the upload aborted", fake.aborted, fake.uploads)
+	}
+	if _, ok := fake.object("data/bundles/rb_3/video.mp4"); ok {
+		t.Error("object stored despite a failed part")
+	}
+}
+
+func TestS3MultipartErrorInCompletion(t *testing.T) {
+	fake, b := newFakeS3(t)
+	b.cfg.PartSize = 4
+	fake.completeError = true
+
+	err := b.Commit(stageFiles(t, map[string]string{"video.mp4": "0123456789"}), "bundles/rb_4")
+	var s3Err *S3Error
+	if !errors.As(err, &s3Err) || s3Err.StatusCode != http.StatusOK || s3Err.Code != "InternalError" {
+		t.Fatalf("error = %v, want an S3Error from the 200 completion", err)
+	}
+	if len(fake.aborted) != 1 {
+		t.Errorf("aborted %v, want the upload aborted", fake.aborted)
+	}
+}
+
+func TestS3OpenRange(t *testing.T) {
+	fake, b := newFakeS3(t)
+	fake.put("data/bundles/rb_5/game.log", "0123456789")
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{2, 3, "234"},
+		{7, -1, "789"},
+		{8, 10, "89"},
+		{0, 0, ""},
+		{10, -1, ""}, // 416
+		{20, 5, ""},  // 416
+	}
+	for _, tt := range tests {
+		if got := readObject(t, b, "bundles/rb_5/game.log", tt.offset, tt.length); got != tt.want {
+			t.Errorf("Open(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+	}
+
+	for _, r := range fake.requests {
+		if rng := r.Header.Get("Range"); rng == "bytes=2-4" {
+			return
+		}
+	}
+	t.Error("no Range request for bytes 2-4")
+}
+
+func TestS3ListPagination(t *testing.T) {
+	fake, b := newFakeS3(t)
+	fake.pageSize = 2
+
+	for i := 1; i <= 5; i++ {
+		fake.put(fmt.Sprintf("data/bundles/rb_%d/manifest.json", i), "{}")
+		fake.put(fmt.Sprintf("data/bundles/rb_%d/game.log", i), strings.Repeat("x", i))
+	}
+	fake.put("data/tmp/other", "not listed")
+
+	files, err := b.List("bundles")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(files) != 10 {
+		t.Errorf("listed %d files, want 10", len(files))
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name, "bundles/rb_") {
+			t.Errorf("listed %q outside bundles/ or with the key prefix", f.Name)
+		}
+		if f.ModTime.IsZero() {
+			t.Errorf("%s has no modification time", f.Name)
+		}
+	}
+
+	dirs, err := b.ListDirs("bundles")
+	if err != nil {
+		t.Fatalf("list dirs: %v", err)
+	}
+	want := []string{"bundles/rb_1", "bundles/rb_2", "bundles/rb_3", "bundles/rb_4", "bundles/rb_5"}
+	if strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+
+	tokens := 0
+	for _, r := range fake.requests {
+		if r.URL.Query().Get("continuation-token") != "" {
+			tokens++
+		}
+	}
+	if tokens < 4 {
+		t.Errorf("%d requests carried a continuation token, want pages to be followed", tokens)
+	}
+}
+
+func TestS3DeletePrefix(t *testing.T) {
+	fake, b := newFakeS3(t)
+	fake.put("data/bundles/rb_1/manifest.json", "{}")
+	fake.put("data/bundles/rb_1/screenshots/001.png", "png")
+	fake.put("data/bundles/rb_10/manifest.json", "{}")
+
+	if err := b.Delete("bundles/rb_1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	for _, key := range []string{"data/bundles/rb_1/manifest.json", "data/bundles/rb_1/screenshots/001.png"} {
+		if _, ok := fake.object(key); ok {
+			t.Errorf("%s survived the delete", key)
+		}
+	}
+	if _, ok := fake.object("data/bundles/rb_10/manifest.json"); !ok {
+		t.Error("delete of rb_1 removed rb_10, which only shares a name prefix")
+	}
+
+	// Deleting what does not exist is not an error
+	if err := b.Delete("bundles/rb_missing"); err != nil {
+		t.Errorf("delete of a missing directory: %v", err)
+	}
+}
+
+func TestS3NotFoundIsErrNotExist(t *testing.T) {
+	_, b := newFakeS3(t)
+
+	if _, err := b.Stat("bundles/rb_6/missing.log"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := b.Open("bundles/rb_6/missing.log", 0, -1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open error = %v, want fs.ErrNotExist", err)
+	}
+
+	err := pathError("open", "x", &S3Error{StatusCode: http.StatusForbidden, Code: "AccessDenied"})
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Error("403 mapped to fs.ErrNotExist")
+	}
+	var s3Err *S3Error
+	if !errors.As(err, &s3Err) || s3Err.Code != "AccessDenied" {
+		t.Errorf("pathError lost the S3Error: %v", err)
+	}
+}
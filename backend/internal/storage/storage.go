@@ -4,18 +4,46 @@ package storage
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/unrealsolutions/bugit/internal/models"
 )
 
-// Storage manages the filesystem layout for repro bundles.
+// PendingMarker is created in a bundle directory before it is moved into
+// bundles/ and removed once the bundle is committed to the database. A
+// directory still carrying it after a crash was never committed.
+const PendingMarker = ".bugit-pending"
+
+// UploadSessionFile is the name of the session state file inside a
+// resumable upload's staging directory.
+const UploadSessionFile = "session.json"
+
+// bundlesPrefix is the backend directory holding committed bundles.
+const bundlesPrefix = "bundles"
+
+// Storage manages the filesystem layout for repro bundles. Committed bundles
+// live in a Backend, the data directory itself unless SetBackend is called;
+// staging, the queue, quarantine and the database are always local.
 type Storage struct {
 	dataDir    string
 	bundlesDir string
 	tmpDir     string
+	queueDir   string
+	backend    Backend
+
+	diskReserve int64
+
+	quarantineDir string
+	quarantine    QuarantineLimits
 }
 
 // New creates a new Storage instance.
@@ -24,10 +52,20 @@ func New(dataDir string) (*Storage, error) {
 		dataDir:    dataDir,
 		bundlesDir: filepath.Join(dataDir, "bundles"),
 		tmpDir:     filepath.Join(dataDir, "tmp"),
+		queueDir:   filepath.Join(dataDir, "queue"),
+		backend:    NewLocalBackend(dataDir),
+
+		diskReserve: DefaultDiskReserve,
+
+		quarantineDir: filepath.Join(dataDir, "quarantine"),
+		quarantine: QuarantineLimits{
+			MaxBytes: DefaultQuarantineMaxBytes,
+			MaxAge:   DefaultQuarantineMaxAge,
+		},
 	}
 
 	// Create directories
-	for _, dir := range []string{s.dataDir, s.bundlesDir, s.tmpDir} {
+	for _, dir := range []string{s.dataDir, s.bundlesDir, s.tmpDir, s.queueDir, s.quarantineDir} {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, fmt.Errorf("create directory %s: %w", dir, err)
 		}
@@ -41,6 +79,16 @@ func (s *Storage) DataDir() string {
 	return s.dataDir
 }
 
+// SetBackend replaces the backend committed bundles are stored in.
+func (s *Storage) SetBackend(b Backend) {
+	s.backend = b
+}
+
+// Backend returns the backend committed bundles are stored in.
+func (s *Storage) Backend() Backend {
+	return s.backend
+}
+
 // DBPath returns the SQLite database path.
 func (s *Storage) DBPath() string {
 	return filepath.Join(s.dataDir, "bugit.db")
@@ -55,55 +103,252 @@ func (s *Storage) CreateTempDir(uploadID string) (string, error) {
 	return dir, nil
 }
 
+// TempDirPath returns the staging directory path for an upload ID
+// without creating it.
+func (s *Storage) TempDirPath(uploadID string) string {
+	return filepath.Join(s.tmpDir, "upload_"+uploadID)
+}
+
+// SaveUploadSession writes session state into the session's staging directory.
+// The file is replaced atomically so a crash never leaves a torn session.
+func (s *Storage) SaveUploadSession(session *models.UploadSession) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+
+	dir := s.TempDirPath(session.UploadID)
+	tmpFile := filepath.Join(dir, UploadSessionFile+".tmp")
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := os.Rename(tmpFile, filepath.Join(dir, UploadSessionFile)); err != nil {
+		return fmt.Errorf("rename session: %w", err)
+	}
+	return nil
+}
+
+// LoadUploadSession reads session state for an upload ID.
+// Returns nil if no session exists.
+func (s *Storage) LoadUploadSession(uploadID string) (*models.UploadSession, error) {
+	return readUploadSession(s.TempDirPath(uploadID))
+}
+
+func readUploadSession(dir string) (*models.UploadSession, error) {
+	data, err := os.ReadFile(filepath.Join(dir, UploadSessionFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+
+	var session models.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("parse session: %w", err)
+	}
+	return &session, nil
+}
+
 // RemoveTempDir removes a temporary upload directory.
 func (s *Storage) RemoveTempDir(dir string) error {
 	return os.RemoveAll(dir)
 }
 
-// MoveToBundles atomically moves a directory from tmp to bundles.
-func (s *Storage) MoveToBundles(srcDir, bundleID string) (string, error) {
+// MoveToQueue moves a staging directory into queue/ where it waits for an
+// ingest job. Unlike tmp/, queue/ is not swept by CleanupOldTempDirs.
+func (s *Storage) MoveToQueue(srcDir, jobID string) (string, error) {
+	destDir := s.QueueDirPath(jobID)
+	if err := os.Rename(srcDir, destDir); err != nil {
+		return "", fmt.Errorf("move to queue: %w", err)
+	}
+	return destDir, nil
+}
+
+// QueueDirPath returns the staging directory path for an ingest job.
+func (s *Storage) QueueDirPath(jobID string) string {
+	return filepath.Join(s.queueDir, jobID)
+}
+
+// ListQueueDirs returns the job IDs that have a directory in queue/.
+func (s *Storage) ListQueueDirs() ([]string, error) {
+	entries, err := os.ReadDir(s.queueDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobIDs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			jobIDs = append(jobIDs, entry.Name())
+		}
+	}
+	return jobIDs, nil
+}
+
+// BundleDirName returns the directory name used for a bundle ID.
+func BundleDirName(bundleID string) string {
 	// Use first 8 chars of bundle_id for directory name (after "rb_" prefix)
 	dirName := bundleID
 	if len(dirName) > 11 {
 		dirName = dirName[:11] // "rb_" + 8 chars
 	}
+	return dirName
+}
 
-	destDir := filepath.Join(s.bundlesDir, dirName)
+// MoveToBundles commits a staged directory to the bundle backend and returns
+// its storage path. With the local backend this is an atomic rename.
+func (s *Storage) MoveToBundles(srcDir, bundleID string) (string, error) {
+	storagePath := path.Join(bundlesPrefix, BundleDirName(bundleID))
+	if err := s.backend.Commit(srcDir, storagePath); err != nil {
+		return "", err
+	}
+	return storagePath, nil
+}
 
-	// Ensure bundles directory exists (may have been deleted)
-	if err := os.MkdirAll(s.bundlesDir, 0755); err != nil {
-		return "", fmt.Errorf("ensure bundles dir: %w", err)
+// ArtifactName returns the backend name of a file in a bundle.
+func ArtifactName(bundleStoragePath, artifactPath string) string {
+	return path.Join(filepath.ToSlash(bundleStoragePath), artifactPath)
+}
+
+// OpenArtifact opens a bundle file for reading, starting at offset. A
+// negative length reads to the end.
+func (s *Storage) OpenArtifact(bundleStoragePath, artifactPath string, offset, length int64) (io.ReadCloser, error) {
+	return s.backend.Open(ArtifactName(bundleStoragePath, artifactPath), offset, length)
+}
+
+// StatArtifact returns the stored size of a bundle file.
+func (s *Storage) StatArtifact(bundleStoragePath, artifactPath string) (*FileInfo, error) {
+	return s.backend.Stat(ArtifactName(bundleStoragePath, artifactPath))
+}
+
+// ArtifactReaderAt returns random access to a bundle file and its size.
+func (s *Storage) ArtifactReaderAt(bundleStoragePath, artifactPath string) (io.ReaderAt, int64, error) {
+	name := ArtifactName(bundleStoragePath, artifactPath)
+	info, err := s.backend.Stat(name)
+	if err != nil {
+		return nil, 0, err
 	}
+	return &backendReaderAt{backend: s.backend, name: name}, info.Size, nil
+}
 
-	// Check if destination already exists
-	if _, err := os.Stat(destDir); err == nil {
-		return "", fmt.Errorf("destination already exists: %s", destDir)
+// BundleExists reports whether a bundle directory holds any files.
+func (s *Storage) BundleExists(storagePath string) bool {
+	if storagePath == "" {
+		return false
 	}
+	files, err := s.backend.List(filepath.ToSlash(storagePath))
+	return err == nil && len(files) > 0
+}
 
-	// Atomic rename (works on same filesystem)
-	if err := os.Rename(srcDir, destDir); err != nil {
-		return "", fmt.Errorf("rename to bundles: %w", err)
+// ListBundleFiles returns every file of a bundle with its name relative to
+// the bundle directory.
+func (s *Storage) ListBundleFiles(storagePath string) ([]FileInfo, error) {
+	dir := filepath.ToSlash(storagePath)
+	files, err := s.backend.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	for n := range files {
+		files[n].Name = strings.TrimPrefix(files[n].Name, dir+"/")
 	}
+	return files, nil
+}
 
-	// Return relative path from data dir
-	relPath, _ := filepath.Rel(s.dataDir, destDir)
-	return relPath, nil
+// LocalBundleDir returns the directory of a committed bundle on local disk,
+// or false if the backend does not keep bundles locally.
+func (s *Storage) LocalBundleDir(storagePath string) (string, bool) {
+	local, ok := s.backend.(*LocalBackend)
+	if !ok {
+		return "", false
+	}
+	return local.path(filepath.ToSlash(storagePath)), true
 }
 
-// BundlePath returns the absolute path to a bundle directory.
-func (s *Storage) BundlePath(storagePath string) string {
-	return filepath.Join(s.dataDir, storagePath)
+// FetchBundle copies every file of a committed bundle into destDir, except
+// the pending marker.
+func (s *Storage) FetchBundle(storagePath, destDir string) error {
+	files, err := s.ListBundleFiles(storagePath)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.Name == PendingMarker {
+			continue
+		}
+		dest := filepath.Join(destDir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("create dir for %s: %w", f.Name, err)
+		}
+		if err := s.fetchFile(storagePath, f.Name, dest); err != nil {
+			return fmt.Errorf("fetch %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) fetchFile(storagePath, name, dest string) error {
+	rc, err := s.OpenArtifact(storagePath, name, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, rc)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// MarkPending flags a staged directory as not yet committed.
+func MarkPending(dir string) error {
+	f, err := os.Create(filepath.Join(dir, PendingMarker))
+	if err != nil {
+		return fmt.Errorf("create pending marker: %w", err)
+	}
+	return f.Close()
+}
+
+// ClearPending removes the pending marker from a committed bundle directory.
+func (s *Storage) ClearPending(storagePath string) error {
+	return s.backend.Delete(ArtifactName(storagePath, PendingMarker))
+}
+
+// IsPending reports whether a bundle directory still carries the pending marker.
+func (s *Storage) IsPending(storagePath string) bool {
+	_, err := s.backend.Stat(ArtifactName(storagePath, PendingMarker))
+	return err == nil
 }
 
-// ArtifactPath returns the absolute path to an artifact file.
-func (s *Storage) ArtifactPath(bundleStoragePath, artifactPath string) string {
-	return filepath.Join(s.dataDir, bundleStoragePath, artifactPath)
+// RemoveBundleDir removes a bundle directory given its storage path.
+// Paths outside the bundles directory are refused.
+func (s *Storage) RemoveBundleDir(storagePath string) error {
+	dir := path.Clean(filepath.ToSlash(storagePath))
+	if !strings.HasPrefix(dir, bundlesPrefix+"/") {
+		return fmt.Errorf("not a bundle directory: %s", storagePath)
+	}
+	return s.backend.Delete(dir)
+}
+
+// ListBundleDirs returns the storage path of every directory under bundles/.
+func (s *Storage) ListBundleDirs() ([]string, error) {
+	dirs, err := s.backend.ListDirs(bundlesPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list bundle dirs: %w", err)
+	}
+	return dirs, nil
 }
 
 // PurgeAllBundles removes all bundle directories from storage.
 func (s *Storage) PurgeAllBundles() error {
-	if err := os.RemoveAll(s.bundlesDir); err != nil {
-		return fmt.Errorf("remove bundles dir: %w", err)
+	if err := s.backend.Delete(bundlesPrefix); err != nil {
+		return fmt.Errorf("remove bundles: %w", err)
 	}
 	// Recreate the empty directory
 	if err := os.MkdirAll(s.bundlesDir, 0755); err != nil {
@@ -122,17 +367,42 @@ func (s *Storage) CheckHealth() error {
 	}
 	f.Close()
 	os.Remove(testFile)
+
+	if err := s.backend.Check(); err != nil {
+		return fmt.Errorf("%s backend: %w", s.backend.Name(), err)
+	}
 	return nil
 }
 
 // CleanupOldTempDirs removes temp directories older than maxAge.
+// Directories holding a resumable upload session are removed once the
+// session has expired instead, regardless of maxAge.
 func (s *Storage) CleanupOldTempDirs(maxAge time.Duration) (int, error) {
-	entries, err := os.ReadDir(s.tmpDir)
+	dirs, err := s.StaleTempDirs(maxAge)
 	if err != nil {
-		return 0, fmt.Errorf("read tmp dir: %w", err)
+		return 0, err
 	}
 
 	removed := 0
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// StaleTempDirs returns the staging directories CleanupOldTempDirs removes:
+// resumable uploads past their expiry and any other upload untouched for
+// maxAge.
+func (s *Storage) StaleTempDirs(maxAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(s.tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("read tmp dir: %w", err)
+	}
+
+	var stale []string
 	cutoff := time.Now().Add(-maxAge)
 
 	for _, entry := range entries {
@@ -145,15 +415,18 @@ func (s *Storage) CleanupOldTempDirs(maxAge time.Duration) (int, error) {
 			continue
 		}
 
-		if info.ModTime().Before(cutoff) {
-			path := filepath.Join(s.tmpDir, entry.Name())
-			if err := os.RemoveAll(path); err == nil {
-				removed++
-			}
+		path := filepath.Join(s.tmpDir, entry.Name())
+		expired := info.ModTime().Before(cutoff)
+		if session, err := readUploadSession(path); err == nil && session != nil {
+			expired = time.Now().After(session.ExpiresAt)
+		}
+
+		if expired {
+			stale = append(stale, path)
 		}
 	}
 
-	return removed, nil
+	return stale, nil
 }
 
 // HashFile computes SHA256 hash of a file.
@@ -181,6 +454,78 @@ func HashReader(r io.Reader) (string, error) {
 	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// CanonicalHash hashes a bundle independently of how it was packed or
+// uploaded. files maps each file's slash-separated path relative to the
+// bundle root to its HashFile checksum. The result is the SHA-256 of one
+// "<hex>  <path>\n" line per file in path order, as printed by sha256sum.
+func CanonicalHash(files map[string]string) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s  %s\n", strings.TrimPrefix(files[path], "sha256:"), path)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// HashBundleDir computes the CanonicalHash of the files in a bundle
+// directory. The pending marker is not part of the bundle.
+func HashBundleDir(dir string) (string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == PendingMarker {
+			return nil
+		}
+		files[rel], err = HashFile(path)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return CanonicalHash(files), nil
+}
+
+// HashBundle computes the CanonicalHash of a committed bundle from the files
+// its backend holds.
+func (s *Storage) HashBundle(storagePath string) (string, error) {
+	files, err := s.ListBundleFiles(storagePath)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", &fs.PathError{Op: "hash", Path: storagePath, Err: fs.ErrNotExist}
+	}
+
+	hashes := make(map[string]string, len(files))
+	for _, f := range files {
+		if f.Name == PendingMarker {
+			continue
+		}
+		rc, err := s.OpenArtifact(storagePath, f.Name, 0, -1)
+		if err != nil {
+			return "", err
+		}
+		hashes[f.Name], err = HashReader(rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return CanonicalHash(hashes), nil
+}
+
 // FileSize returns the size of a file in bytes.
 func FileSize(path string) (int64, error) {
 	info, err := os.Stat(path)
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/unrealsolutions/bugit/internal/models"
 )
 
 // ValidationResult contains the outcome of bundle validation.
@@ -52,21 +54,6 @@ type BundleStats struct {
 	DurationMismatchMs   float64 `json:"durationMismatchMs"`
 }
 
-// Manifest represents the manifest.json structure for validation.
-type Manifest struct {
-	SchemaVersion    string          `json:"schemaVersion"`
-	BundleID         string          `json:"bundleId"`
-	DurationSeconds  float64         `json:"durationSeconds"`
-	TotalFrames      int             `json:"totalFrames"`
-	SessionInfo      *SessionInfo    `json:"sessionInfo"`
-	Artifacts        json.RawMessage `json:"artifacts"`
-}
-
-type SessionInfo struct {
-	MapName   string `json:"mapName"`
-	TargetFPS int    `json:"targetFps"`
-}
-
 // TimingData represents timing.json structure.
 type TimingData struct {
 	SchemaVersion string       `json:"schemaVersion"`
@@ -95,18 +82,22 @@ type InputEvent struct {
 
 // ValidateBundle validates a bundle directory for internal consistency.
 func ValidateBundle(bundlePath string) *ValidationResult {
-	result := &ValidationResult{
-		Valid: true,
-		Stats: &BundleStats{},
-	}
-	
-	// Load manifest
 	manifest, err := loadManifest(bundlePath)
 	if err != nil {
+		result := &ValidationResult{Stats: &BundleStats{}}
 		result.addError("MANIFEST_LOAD", "", err.Error())
-		result.Valid = false
 		return result
 	}
+	return ValidateManifest(bundlePath, manifest)
+}
+
+// ValidateManifest validates a bundle directory against a manifest that was
+// already parsed, such as the one ingest decoded from it.
+func ValidateManifest(bundlePath string, manifest *models.Manifest) *ValidationResult {
+	result := &ValidationResult{
+		Valid: true,
+		Stats: &BundleStats{},
+	}
 	
 	// Load timing.json
 	timing, err := loadTiming(bundlePath)
@@ -121,8 +112,8 @@ func ValidateBundle(bundlePath string) *ValidationResult {
 	}
 	
 	// Populate stats from manifest
-	result.Stats.ManifestDurationSec = manifest.DurationSeconds
-	result.Stats.ManifestTotalFrames = manifest.TotalFrames
+	result.Stats.ManifestDurationSec = durationOf(manifest)
+	result.Stats.ManifestTotalFrames = framesOf(manifest)
 	
 	// Validate manifest internal consistency
 	validateManifestInternal(result, manifest)
@@ -179,16 +170,34 @@ func (r *ValidationResult) addWarningWithValues(code, field, message, got, want
 	})
 }
 
-func loadManifest(bundlePath string) (*Manifest, error) {
+// loadManifest parses manifest.json with models.ParseManifest, so every
+// schema version and field style ingest accepts is understood.
+func loadManifest(bundlePath string) (*models.Manifest, error) {
 	data, err := os.ReadFile(filepath.Join(bundlePath, "manifest.json"))
 	if err != nil {
 		return nil, fmt.Errorf("read manifest.json: %w", err)
 	}
-	var m Manifest
-	if err := json.Unmarshal(data, &m); err != nil {
+	m, err := models.ParseManifest(data)
+	if err != nil {
 		return nil, fmt.Errorf("parse manifest.json: %w", err)
 	}
-	return &m, nil
+	return m, nil
+}
+
+// durationOf returns the manifest's recording length, or 0 if it has none.
+func durationOf(m *models.Manifest) float64 {
+	if m.DurationSeconds == nil {
+		return 0
+	}
+	return *m.DurationSeconds
+}
+
+// framesOf returns the manifest's video frame count, or 0 if it has none.
+func framesOf(m *models.Manifest) int {
+	if m.TotalFrames == nil {
+		return 0
+	}
+	return *m.TotalFrames
 }
 
 func loadTiming(bundlePath string) (*TimingData, error) {
@@ -215,25 +224,29 @@ func loadInputs(bundlePath string) (*InputData, error) {
 	return &i, nil
 }
 
-func validateManifestInternal(r *ValidationResult, m *Manifest) {
+func validateManifestInternal(r *ValidationResult, m *models.Manifest) {
+	// Only checked when the schema carries them; v2, snake_case and
+	// crash-only manifests have no recording length
+	duration, frames := durationOf(m), framesOf(m)
+
 	// Duration must be positive
-	if m.DurationSeconds <= 0 {
+	if m.DurationSeconds != nil && duration <= 0 {
 		r.addError("MANIFEST_DURATION", "durationSeconds", 
-			fmt.Sprintf("must be positive, got %.3f", m.DurationSeconds))
+			fmt.Sprintf("must be positive, got %.3f", duration))
 	}
 	
 	// TotalFrames must be positive
-	if m.TotalFrames <= 0 {
+	if m.TotalFrames != nil && frames <= 0 {
 		r.addError("MANIFEST_FRAMES", "totalFrames", 
-			fmt.Sprintf("must be positive, got %d", m.TotalFrames))
+			fmt.Sprintf("must be positive, got %d", frames))
 	}
 	
 	// Check reasonable FPS (1-240)
-	if m.DurationSeconds > 0 && m.TotalFrames > 0 {
-		fps := float64(m.TotalFrames) / m.DurationSeconds
+	if duration > 0 && frames > 0 {
+		fps := float64(frames) / duration
 		if fps < 1 || fps > 240 {
 			r.addWarning("MANIFEST_FPS", "", 
-				fmt.Sprintf("unusual FPS: %.1f (frames=%d, duration=%.2fs)", fps, m.TotalFrames, m.DurationSeconds))
+				fmt.Sprintf("unusual FPS: %.1f (frames=%d, duration=%.2fs)", fps, frames, duration))
 		}
 	}
 	
@@ -278,20 +291,24 @@ func validateTiming(r *ValidationResult, t *TimingData) {
 	}
 }
 
-func validateManifestVsTiming(r *ValidationResult, m *Manifest, t *TimingData) {
+func validateManifestVsTiming(r *ValidationResult, m *models.Manifest, t *TimingData) {
 	// Frame contexts should now be 1:1 with video frames
 	// Both should have the same count
 	
-	if m.TotalFrames != len(t.Frames) {
+	if m.TotalFrames != nil && *m.TotalFrames != len(t.Frames) {
 		r.addErrorWithValues("FRAME_COUNT_MISMATCH", "totalFrames",
 			"manifest totalFrames should match timing.json frame count (1:1 with video)",
-			fmt.Sprintf("%d", m.TotalFrames),
+			fmt.Sprintf("%d", *m.TotalFrames),
 			fmt.Sprintf("%d", len(t.Frames)))
 	}
 	
+	if m.DurationSeconds == nil {
+		return
+	}
+	
 	// Calculate video FPS
-	if m.DurationSeconds > 0 {
-		videoFPS := float64(m.TotalFrames) / m.DurationSeconds
+	if m.TotalFrames != nil && *m.DurationSeconds > 0 {
+		videoFPS := float64(*m.TotalFrames) / *m.DurationSeconds
 		r.Stats.VideoFPS = videoFPS
 		
 		// Video FPS should be reasonable (15-60)
@@ -303,19 +320,19 @@ func validateManifestVsTiming(r *ValidationResult, m *Manifest, t *TimingData) {
 	
 	// Duration should approximately match
 	timingDurationSec := r.Stats.TimingDurationMs / 1000.0
-	durationDiff := math.Abs(m.DurationSeconds - timingDurationSec)
+	durationDiff := math.Abs(*m.DurationSeconds - timingDurationSec)
 	r.Stats.DurationMismatchMs = durationDiff * 1000
 	
 	// Allow 100ms tolerance
 	if durationDiff > 0.1 {
 		r.addWarningWithValues("DURATION_MISMATCH", "durationSeconds",
 			fmt.Sprintf("manifest duration differs from timing.json by %.1fms", durationDiff*1000),
-			fmt.Sprintf("%.3fs", m.DurationSeconds),
+			fmt.Sprintf("%.3fs", *m.DurationSeconds),
 			fmt.Sprintf("%.3fs", timingDurationSec))
 	}
 }
 
-func validateInputs(r *ValidationResult, inputs *InputData, m *Manifest) {
+func validateInputs(r *ValidationResult, inputs *InputData, m *models.Manifest) {
 	r.Stats.InputEventCount = len(inputs.Events)
 	
 	if len(inputs.Events) == 0 {
@@ -358,16 +375,18 @@ func validateInputs(r *ValidationResult, inputs *InputData, m *Manifest) {
 	r.Stats.InputLastTimestamp = maxTs
 	
 	// Check all timestamps are within video duration
-	videoDurationMs := m.DurationSeconds * 1000
-	outOfRange := 0
-	for _, event := range inputs.Events {
-		if event.TimestampMs < 0 || event.TimestampMs > videoDurationMs+100 { // 100ms tolerance
-			outOfRange++
+	if m.DurationSeconds != nil {
+		videoDurationMs := *m.DurationSeconds * 1000
+		outOfRange := 0
+		for _, event := range inputs.Events {
+			if event.TimestampMs < 0 || event.TimestampMs > videoDurationMs+100 { // 100ms tolerance
+				outOfRange++
+			}
+		}
+		if outOfRange > 0 {
+			r.addWarning("INPUTS_OUT_OF_RANGE", "timestampMs",
+				fmt.Sprintf("%d input events outside video duration [0, %.1fms]", outOfRange, videoDurationMs))
 		}
-	}
-	if outOfRange > 0 {
-		r.addWarning("INPUTS_OUT_OF_RANGE", "timestampMs",
-			fmt.Sprintf("%d input events outside video duration [0, %.1fms]", outOfRange, videoDurationMs))
 	}
 	
 	// Report unmatched KeyDowns (missing KeyUp)
@@ -417,14 +436,12 @@ func SummarizeBundle(bundlePath string) (*BundleSummary, error) {
 	}
 	
 	summary.BundleID = manifest.BundleID
-	summary.Duration = manifest.DurationSeconds
-	summary.VideoFrames = manifest.TotalFrames
-	if manifest.DurationSeconds > 0 {
-		summary.VideoFPS = float64(manifest.TotalFrames) / manifest.DurationSeconds
-	}
-	if manifest.SessionInfo != nil {
-		summary.MapName = manifest.SessionInfo.MapName
+	summary.Duration = durationOf(manifest)
+	summary.VideoFrames = framesOf(manifest)
+	if summary.Duration > 0 {
+		summary.VideoFPS = float64(summary.VideoFrames) / summary.Duration
 	}
+	summary.MapName = manifest.MapName
 	
 	// Load inputs
 	inputs, err := loadInputs(bundlePath)
@@ -0,0 +1,348 @@
+// Package watch ingests repro bundle archives dropped into a directory.
+//
+// The drop directory is polled rather than watched with inotify so that it
+// works on network mounts. A file is only picked up once its size and
+// modification time have stopped changing, which keeps half-copied files
+// from being ingested.
+//
+// Directory layout:
+//
+//	<dir>/               # Drop archives here
+//	<dir>/.processing/   # Claimed files being ingested
+//	<dir>/processed/     # Ingested (or already known) files + <name>.json
+//	<dir>/failed/        # Rejected files + <name>.json with the error
+//
+// Only files the ingester rejects as bad uploads go to failed/. Files that
+// failed on the server side, such as a locked database or a full disk, stay
+// in .processing/ and are retried with exponential backoff.
+//
+// Only one watcher may use a directory at a time: run either bugit watch or
+// serve --watch-dir on it, not both. Files are claimed by renaming them into
+// .processing/, and every file found there on start is taken to be left by a
+// crash and ingested again; content-hash idempotency makes that safe, but a
+// second live watcher would be ingesting the same files.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/unrealsolutions/bugit/internal/ingest"
+	"github.com/unrealsolutions/bugit/internal/models"
+)
+
+// Defaults for polling.
+const (
+	DefaultInterval = 5 * time.Second
+	DefaultSettle   = 10 * time.Second
+)
+
+// MaxRetryBackoff caps the wait between retries of a file that failed on
+// the server side.
+const MaxRetryBackoff = 10 * time.Minute
+
+// Subdirectories of the drop directory.
+const (
+	ProcessingDir = ".processing"
+	ProcessedDir  = "processed"
+	FailedDir     = "failed"
+)
+
+// Sidecar is written next to each processed or failed file.
+type Sidecar struct {
+	File        string               `json:"file"`
+	Status      string               `json:"status"` // ingested, already_exists, failed
+	Result      *ingest.IngestResult `json:"result,omitempty"`
+	Error       *models.APIError     `json:"error,omitempty"`
+	ProcessedAt time.Time            `json:"processed_at"`
+}
+
+// Config configures a Watcher.
+type Config struct {
+	Dir      string
+	Interval time.Duration // How often to poll
+	Settle   time.Duration // How long a file must stay unchanged
+}
+
+// fileState is the last observed state of a candidate file.
+type fileState struct {
+	size        int64
+	modTime     time.Time
+	stableSince time.Time
+}
+
+// retryState tracks a claimed file whose ingest failed on the server side.
+type retryState struct {
+	attempts int
+	next     time.Time
+}
+
+// Watcher polls a drop directory and ingests stable archives.
+type Watcher struct {
+	ingester *ingest.Ingester
+	cfg      Config
+	logger   *slog.Logger
+	seen     map[string]fileState
+	retries  map[string]retryState // By name in .processing/
+}
+
+// New creates a watcher. Zero durations fall back to the defaults.
+func New(ingester *ingest.Ingester, cfg Config) *Watcher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Settle <= 0 {
+		cfg.Settle = DefaultSettle
+	}
+	return &Watcher{
+		ingester: ingester,
+		cfg:      cfg,
+		logger:   slog.Default().With("watch_dir", cfg.Dir),
+		seen:     make(map[string]fileState),
+		retries:  make(map[string]retryState),
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	for _, sub := range []string{ProcessingDir, ProcessedDir, FailedDir} {
+		if err := os.MkdirAll(filepath.Join(w.cfg.Dir, sub), 0755); err != nil {
+			return fmt.Errorf("create %s: %w", sub, err)
+		}
+	}
+
+	// Finish anything claimed before a crash
+	w.resume()
+
+	w.logger.Info("watching for bundles", "interval", w.cfg.Interval.String(), "settle", w.cfg.Settle.String())
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll scans the drop directory once and ingests every file that has
+// been stable for the settle time.
+func (w *Watcher) poll() {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		w.logger.Error("failed to read watch directory", "error", err)
+		return
+	}
+
+	now := time.Now()
+	present := make(map[string]bool)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !isCandidate(name) {
+			continue
+		}
+		present[name] = true
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		prev, ok := w.seen[name]
+		if !ok || info.Size() != prev.size || !info.ModTime().Equal(prev.modTime) {
+			// New or still changing
+			w.seen[name] = fileState{size: info.Size(), modTime: info.ModTime(), stableSince: now}
+			continue
+		}
+
+		if now.Sub(prev.stableSince) < w.cfg.Settle || now.Sub(info.ModTime()) < w.cfg.Settle {
+			continue
+		}
+
+		delete(w.seen, name)
+		w.claim(name)
+	}
+
+	// Forget files that disappeared
+	for name := range w.seen {
+		if !present[name] {
+			delete(w.seen, name)
+		}
+	}
+
+	w.retryDue(now)
+}
+
+// retryDue processes the files in .processing/ whose retry is due.
+func (w *Watcher) retryDue(now time.Time) {
+	for name, state := range w.retries {
+		if now.Before(state.next) {
+			continue
+		}
+		path := filepath.Join(w.cfg.Dir, ProcessingDir, name)
+		if _, err := os.Stat(path); err != nil {
+			// Moved away by the operator
+			delete(w.retries, name)
+			continue
+		}
+		w.logger.Info("retrying file", "file", name, "attempt", state.attempts+1)
+		w.process(path)
+	}
+}
+
+// claim moves a stable file into .processing/ and ingests it. If the file
+// disappeared in the meantime it is skipped. A file of the same name still
+// waiting there for a retry keeps its name; the new one gets a timestamp.
+func (w *Watcher) claim(name string) {
+	claimed := uniquePath(filepath.Join(w.cfg.Dir, ProcessingDir), name)
+	if err := os.Rename(filepath.Join(w.cfg.Dir, name), claimed); err != nil {
+		if !os.IsNotExist(err) {
+			w.logger.Warn("failed to claim file", "file", name, "error", err)
+		}
+		return
+	}
+	w.process(claimed)
+}
+
+// resume processes files left in .processing/ by an interrupted run.
+func (w *Watcher) resume() {
+	entries, err := os.ReadDir(filepath.Join(w.cfg.Dir, ProcessingDir))
+	if err != nil {
+		w.logger.Error("failed to read processing directory", "error", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isCandidate(entry.Name()) {
+			w.logger.Info("resuming interrupted file", "file", entry.Name())
+			w.process(filepath.Join(w.cfg.Dir, ProcessingDir, entry.Name()))
+		}
+	}
+}
+
+// process ingests a claimed file, writes its sidecar and moves it to
+// processed/ or failed/. A file that failed on the server side is left in
+// .processing/ and scheduled for a retry.
+func (w *Watcher) process(path string) {
+	name := filepath.Base(path)
+	start := time.Now()
+
+	sidecar := &Sidecar{File: name}
+	result, err := w.ingester.IngestZipFile(path)
+	if err != nil && !ingest.IsUploadFault(err) {
+		w.scheduleRetry(name, err)
+		return
+	}
+	delete(w.retries, name)
+	if err != nil {
+		sidecar.Status = "failed"
+		if apiErr, ok := err.(*models.APIError); ok {
+			sidecar.Error = apiErr
+		} else {
+			sidecar.Error = &models.APIError{
+				Code:    models.ErrCodeStorageError,
+				Message: err.Error(),
+			}
+		}
+	} else {
+		sidecar.Status = result.Status
+		sidecar.Result = result
+	}
+	sidecar.ProcessedAt = time.Now().UTC()
+
+	destDir := ProcessedDir
+	if sidecar.Error != nil {
+		destDir = FailedDir
+	}
+
+	dest := uniquePath(filepath.Join(w.cfg.Dir, destDir), name)
+	if err := writeSidecar(dest+".json", sidecar); err != nil {
+		// Leave the file in .processing/ so it is retried on restart
+		w.logger.Error("failed to write sidecar", "file", name, "error", err)
+		return
+	}
+	if err := os.Rename(path, dest); err != nil {
+		w.logger.Error("failed to move processed file", "file", name, "error", err)
+		return
+	}
+
+	if sidecar.Error != nil {
+		w.logger.Warn("bundle rejected", "file", name, "code", sidecar.Error.Code, "error", sidecar.Error.Message)
+		return
+	}
+	w.logger.Info("bundle ingested",
+		"file", name,
+		"bundle_id", result.BundleID,
+		"status", result.Status,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// scheduleRetry leaves name in .processing/ to be ingested again after a
+// backoff that starts at the poll interval and doubles with every failure.
+func (w *Watcher) scheduleRetry(name string, err error) {
+	state := w.retries[name]
+	backoff := w.cfg.Interval << min(state.attempts, 16)
+	if backoff <= 0 || backoff > MaxRetryBackoff {
+		backoff = MaxRetryBackoff
+	}
+	state.attempts++
+	state.next = time.Now().Add(backoff)
+	w.retries[name] = state
+
+	w.logger.Warn("ingest failed, will retry",
+		"file", name,
+		"attempt", state.attempts,
+		"retry_in", backoff.String(),
+		"error", err,
+	)
+}
+
+// isCandidate reports whether a file in the drop directory should be
+// ingested. Hidden files and anything without an archive extension, such
+// as the .part or .tmp names used by copy tools, are skipped.
+func isCandidate(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	return ingest.IsArchiveName(name)
+}
+
+// uniquePath returns dir/name, or dir/name with a timestamp appended to
+// the base name if that already exists.
+func uniquePath(dir, name string) string {
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		return dest
+	}
+	base, ext := name, ""
+	if i := strings.Index(name, "."); i > 0 {
+		base, ext = name[:i], name[i:]
+	}
+	stamp := time.Now().UTC().Format("20060102T150405.000000000")
+	return filepath.Join(dir, base+"_"+stamp+ext)
+}
+
+// writeSidecar writes the sidecar atomically.
+func writeSidecar(path string, sidecar *Sidecar) error {
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -16,7 +16,8 @@ PRAGMA journal_mode = WAL;
 CREATE TABLE IF NOT EXISTS repro_bundles (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,
     bundle_id       TEXT NOT NULL UNIQUE,           -- External ID: rb_<8chars>
-    content_hash    TEXT NOT NULL UNIQUE,           -- sha256:<hex> for idempotency
+    content_hash    TEXT NOT NULL UNIQUE,           -- sha256:<hex> of the uploaded archive (legacy)
+    canonical_hash  TEXT,                           -- sha256:<hex> over sorted path + file hash, for idempotency
     schema_version  TEXT NOT NULL,                  -- Manifest schema version
     build_id        TEXT NOT NULL,                  -- Game build identifier
     map_name        TEXT,                           -- Unreal map path
@@ -41,6 +42,7 @@ CREATE INDEX IF NOT EXISTS idx_bundles_map_name ON repro_bundles(map_name);
 CREATE INDEX IF NOT EXISTS idx_bundles_platform ON repro_bundles(platform);
 CREATE INDEX IF NOT EXISTS idx_bundles_created_at ON repro_bundles(created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_bundles_content_hash ON repro_bundles(content_hash);
+CREATE INDEX IF NOT EXISTS idx_bundles_canonical_hash ON repro_bundles(canonical_hash);
 
 --------------------------------------------------------------------------------
 -- artifacts: Individual files within a bundle
@@ -49,12 +51,14 @@ CREATE TABLE IF NOT EXISTS artifacts (
     id              INTEGER PRIMARY KEY AUTOINCREMENT,
     artifact_id     TEXT NOT NULL UNIQUE,           -- External ID: art_<8chars>
     bundle_id       TEXT NOT NULL,                  -- Parent bundle
-    filename        TEXT NOT NULL,                  -- Original filename
+    filename        TEXT NOT NULL,                  -- Path within the bundle (screenshots/001.png)
     artifact_type   TEXT NOT NULL,                  -- video, log, screenshot, crash_dump, other
     mime_type       TEXT,                           -- MIME type
     size_bytes      INTEGER NOT NULL DEFAULT 0,     -- File size
     storage_path    TEXT NOT NULL,                  -- Relative path within bundle dir
     checksum        TEXT,                           -- Optional SHA256 of artifact
+    undeclared      INTEGER NOT NULL DEFAULT 0,     -- 1 if present but not listed in the manifest
+    role            TEXT,                           -- Manifest v2 artifact role (timing, inputs, ...)
     created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
     
     FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
@@ -105,6 +109,181 @@ CREATE TABLE IF NOT EXISTS qa_notes (
 CREATE INDEX IF NOT EXISTS idx_notes_bundle_id ON qa_notes(bundle_id);
 CREATE INDEX IF NOT EXISTS idx_notes_author ON qa_notes(author);
 
+--------------------------------------------------------------------------------
+-- bundle_validations: Consistency check results recorded at ingest
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS bundle_validations (
+    bundle_id       TEXT PRIMARY KEY,               -- One result per bundle
+    status          TEXT NOT NULL,                  -- passed, failed, quarantined
+    error_count     INTEGER NOT NULL DEFAULT 0,
+    warning_count   INTEGER NOT NULL DEFAULT 0,
+    result_json     TEXT NOT NULL,                  -- validate.ValidationResult
+    validated_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    -- Foreign key
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    
+    -- Constraints
+    CHECK (status IN ('passed', 'failed', 'quarantined'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_validations_status ON bundle_validations(status);
+
+--------------------------------------------------------------------------------
+-- crashes: Crash contexts parsed from CrashContext.runtime-xml at ingest
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS crashes (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    crash_id        TEXT NOT NULL UNIQUE,           -- External ID: crash_<8chars>
+    bundle_id       TEXT NOT NULL,                  -- Parent bundle
+    source          TEXT NOT NULL,                  -- Path of the crash context file in the bundle
+    crash_type      TEXT NOT NULL DEFAULT '',       -- Crash, Assert, Ensure, Hang, ...
+    error_message   TEXT NOT NULL DEFAULT '',
+    crash_guid      TEXT NOT NULL DEFAULT '',       -- UECC-<Platform>-<GUID>
+    execution_guid  TEXT NOT NULL DEFAULT '',       -- Identifies the crashed process run
+    engine_version  TEXT NOT NULL DEFAULT '',
+    build_version   TEXT NOT NULL DEFAULT '',
+    build_config    TEXT NOT NULL DEFAULT '',       -- Development, Shipping, ...
+    platform        TEXT NOT NULL DEFAULT '',
+    game_name       TEXT NOT NULL DEFAULT '',
+    callstack_json  TEXT NOT NULL DEFAULT '[]',     -- Frames: module, function, file, line, address
+    modules_json    TEXT NOT NULL DEFAULT '[]',     -- Loaded module names
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    UNIQUE (bundle_id, source),
+    
+    CHECK (crash_id LIKE 'crash_%')
+);
+
+CREATE INDEX IF NOT EXISTS idx_crashes_bundle_id ON crashes(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_crashes_crash_guid ON crashes(crash_guid);
+
+--------------------------------------------------------------------------------
+-- minidumps: Analyses of crash_dump artifacts
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS minidumps (
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    artifact_id         TEXT NOT NULL UNIQUE,       -- Analyzed crash_dump artifact
+    bundle_id           TEXT NOT NULL,              -- Parent bundle
+    source              TEXT NOT NULL,              -- Filename of the dump in the bundle
+    exception_code      TEXT NOT NULL DEFAULT '',   -- e.g. 0xC0000005; '' without an exception stream
+    exception_name      TEXT NOT NULL DEFAULT '',   -- e.g. EXCEPTION_ACCESS_VIOLATION, SIGSEGV
+    crash_module        TEXT NOT NULL DEFAULT '',   -- Module containing the exception address
+    crashing_thread_id  INTEGER,
+    cpu_arch            TEXT NOT NULL DEFAULT '',   -- x86, amd64, arm, arm64
+    os                  TEXT NOT NULL DEFAULT '',   -- windows, linux, android, macos, ios
+    os_version          TEXT NOT NULL DEFAULT '',
+    module_count        INTEGER NOT NULL DEFAULT 0,
+    thread_count        INTEGER NOT NULL DEFAULT 0,
+    dump_time           TEXT,                       -- When the dump was written
+    analysis_json       TEXT NOT NULL,              -- Full analysis: modules, threads, frames
+    analyzed_at         TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    FOREIGN KEY (artifact_id) REFERENCES artifacts(artifact_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_minidumps_bundle_id ON minidumps(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_minidumps_exception_code ON minidumps(exception_code);
+CREATE INDEX IF NOT EXISTS idx_minidumps_crash_module ON minidumps(crash_module);
+
+--------------------------------------------------------------------------------
+-- issues: Groups of bundles with the same crash signature
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS issues (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    issue_id        TEXT NOT NULL UNIQUE,           -- External ID: iss_<8chars>
+    title           TEXT NOT NULL DEFAULT '',       -- Title of the signature that opened it
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    CHECK (issue_id LIKE 'iss_%')
+);
+
+-- Which issue new bundles with a signature join. Merging issues moves the
+-- signatures of the merged issues here too.
+CREATE TABLE IF NOT EXISTS issue_signatures (
+    signature       TEXT PRIMARY KEY,               -- 16 hex chars of SHA-256(signature_text)
+    issue_id        TEXT NOT NULL,
+    source          TEXT NOT NULL,                  -- crash_context, minidump or log
+    signature_text  TEXT NOT NULL,                  -- Normalized description that was hashed
+    title           TEXT NOT NULL DEFAULT '',
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (issue_id) REFERENCES issues(issue_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_issue_signatures_issue_id ON issue_signatures(issue_id);
+
+-- One row per fingerprinted bundle. issue_id usually follows the signature
+-- but may differ after a manual split.
+CREATE TABLE IF NOT EXISTS bundle_signatures (
+    bundle_id       TEXT PRIMARY KEY,
+    signature       TEXT NOT NULL DEFAULT '',       -- '' when the bundle shows no crash
+    source          TEXT NOT NULL DEFAULT '',
+    signature_text  TEXT NOT NULL DEFAULT '',
+    title           TEXT NOT NULL DEFAULT '',
+    issue_id        TEXT,                           -- NULL when the bundle shows no crash
+    computed_at     TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE,
+    FOREIGN KEY (issue_id) REFERENCES issues(issue_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_signatures_issue_id ON bundle_signatures(issue_id);
+CREATE INDEX IF NOT EXISTS idx_bundle_signatures_signature ON bundle_signatures(signature);
+
+--------------------------------------------------------------------------------
+-- ingest_jobs: Asynchronous ingestion jobs
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS ingest_jobs (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id          TEXT NOT NULL UNIQUE,           -- External ID: job_<16chars>
+    status          TEXT NOT NULL DEFAULT 'queued', -- queued, running, succeeded, failed
+    stage           TEXT NOT NULL DEFAULT 'queued', -- Current pipeline stage
+    progress        REAL NOT NULL DEFAULT 0,        -- Estimated progress 0..1
+    staged_json     TEXT NOT NULL,                  -- Staged upload kind, hash and size
+    result_json     TEXT,                           -- IngestResult on success
+    error_json      TEXT,                           -- APIError on failure
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    -- Constraints
+    CHECK (job_id LIKE 'job_%'),
+    CHECK (status IN ('queued', 'running', 'succeeded', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_jobs_status ON ingest_jobs(status, id);
+
+--------------------------------------------------------------------------------
+-- retention_audit: Bundles deleted by retention garbage collection
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS retention_audit (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    bundle_id       TEXT NOT NULL,                  -- Deleted bundle; no foreign key, the row is gone
+    build_id        TEXT NOT NULL,
+    platform        TEXT NOT NULL,
+    size_bytes      INTEGER NOT NULL DEFAULT 0,
+    storage_path    TEXT NOT NULL DEFAULT '',       -- Directory removed with the row
+    reason          TEXT NOT NULL,                  -- max_age, max_total_bytes or keep_per_build
+    bundle_created_at TEXT NOT NULL,                -- When the bundle was ingested
+    deleted_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_audit_bundle_id ON retention_audit(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_retention_audit_deleted_at ON retention_audit(deleted_at DESC);
+
+--------------------------------------------------------------------------------
+-- bundle_damage: Bundles whose stored files fsck found missing or altered
+--------------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS bundle_damage (
+    bundle_id       TEXT PRIMARY KEY,
+    problems_json   TEXT NOT NULL,                  -- JSON array of the problems found
+    detected_at     TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    
+    FOREIGN KEY (bundle_id) REFERENCES repro_bundles(bundle_id) ON DELETE CASCADE
+);
+
 --------------------------------------------------------------------------------
 -- schema_migrations: Track applied migrations
 --------------------------------------------------------------------------------
@@ -113,7 +292,8 @@ CREATE TABLE IF NOT EXISTS schema_migrations (
     applied_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
 );
 
--- Record this schema version
+-- Record this schema version. Later versions are applied by db.migrate,
+-- which also upgrades databases created before they existed.
 INSERT OR IGNORE INTO schema_migrations (version) VALUES (1);
 
 --------------------------------------------------------------------------------